@@ -30,7 +30,7 @@ Example:
 		defer database.Close()
 
 		ctx := context.Background()
-		results, err := database.StoreEntities(ctx, []db.EntityInput{
+		results, dups, err := database.StoreEntitiesWithDuplicates(ctx, []db.EntityInput{
 			{Name: name, EntityType: entityType, Observations: observations, Tags: addTags},
 		})
 		if err != nil {
@@ -42,6 +42,7 @@ Example:
 				fmt.Printf("  + %s\n", obs)
 			}
 		}
+		printNearDuplicates(dups)
 		return nil
 	},
 }
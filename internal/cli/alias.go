@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Manage alternate names for entities",
+	Long: `Manage alternate names for entities.
+
+An alias resolves to its entity wherever an entity name is accepted (get,
+observe, retract, link, graph, memory_store) and is matched by search.
+
+Examples:
+  aimemo alias add auth-service AuthService "authentication service"
+  aimemo alias list auth-service
+  aimemo alias rm AuthService`,
+}
+
+var aliasAddCmd = &cobra.Command{
+	Use:   "add <entity-name> <alias>...",
+	Short: "Add aliases to an entity",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		ctx := context.Background()
+		for _, alias := range args[1:] {
+			if err := database.AddAlias(ctx, args[0], alias); err != nil {
+				return fmt.Errorf("add alias: %w", err)
+			}
+			fmt.Printf("Alias added: %s -> %s\n", alias, args[0])
+		}
+		return nil
+	},
+}
+
+var aliasRmCmd = &cobra.Command{
+	Use:     "rm <alias>...",
+	Aliases: []string{"remove"},
+	Short:   "Remove aliases",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		ctx := context.Background()
+		for _, alias := range args {
+			if err := database.RemoveAlias(ctx, alias); err != nil {
+				return err
+			}
+			fmt.Printf("Alias removed: %s\n", alias)
+		}
+		return nil
+	},
+}
+
+var aliasListCmd = &cobra.Command{
+	Use:   "list [entity-name]",
+	Short: "List aliases of one entity or all entities",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entity := ""
+		if len(args) > 0 {
+			entity = args[0]
+		}
+
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		aliases, err := database.ListAliases(context.Background(), entity)
+		if err != nil {
+			return fmt.Errorf("list aliases: %w", err)
+		}
+		if outputJSON {
+			if aliases == nil {
+				aliases = []db.Alias{}
+			}
+			return printJSON(aliases)
+		}
+		if len(aliases) == 0 {
+			fmt.Println("No aliases.")
+			return nil
+		}
+		for _, a := range aliases {
+			fmt.Printf("%s -> %s\n", a.Alias, a.Entity)
+		}
+		return nil
+	},
+}
+
+func init() {
+	aliasListCmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+	aliasCmd.AddCommand(aliasAddCmd, aliasRmCmd, aliasListCmd)
+	rootCmd.AddCommand(aliasCmd)
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/MyAgentHubs/aimemo/internal/locate"
+	"github.com/spf13/cobra"
+)
+
+var backupTo string
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Write a consistent copy of the memory database",
+	Long: `Write a consistent copy of the memory database with SQLite's VACUUM INTO.
+Unlike copying the file, this is safe while 'aimemo serve' is running.
+
+Backups go to backups/ next to the database (or [backup] dir in the config)
+unless --to is given. Set [backup] daily = true to also take a snapshot once
+a day, keeping the newest [backup] keep of them. Daily snapshots are named
+after the database and a hash of its path, so projects can share a
+[backup] dir.
+
+Examples:
+  aimemo backup
+  aimemo backup --to ~/memory-before-cleanup.db`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, dbPath, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		dest := backupTo
+		if dest == "" {
+			dest = db.BackupPath(backupDir(dbPath), dbPath, time.Now())
+		}
+		if err := database.Backup(context.Background(), dest); err != nil {
+			return err
+		}
+		fmt.Printf("Backed up %s to %s\n", dbPath, dest)
+		return nil
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Replace the memory database with a backup",
+	Long: `Replace the memory database with a backup made by 'aimemo backup'.
+
+The backup is checked with SQLite's integrity and foreign key checks first,
+and the current database is saved to the backup directory before it is
+replaced. Stop any running 'aimemo serve' first: a running server keeps using
+the replaced file until it restarts.
+
+Example:
+  aimemo restore .aimemo/backups/memory.20260217-150405.db`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dbPath, err := locate.FindProjectDB(contextFlag)
+		if err != nil {
+			return fmt.Errorf("find db: %w", err)
+		}
+
+		safety, err := db.Restore(context.Background(), args[0], dbPath, backupDir(dbPath))
+		if err != nil {
+			return fmt.Errorf("restore: %w", err)
+		}
+		if safety != "" {
+			fmt.Printf("Saved the current database to %s\n", safety)
+		}
+		fmt.Printf("Restored %s to %s\n", args[0], dbPath)
+		return nil
+	},
+}
+
+// backupDir returns the configured backup directory for the database at dbPath.
+func backupDir(dbPath string) string {
+	if cfg.Backup.Dir != "" {
+		return cfg.Backup.Dir
+	}
+	return db.DefaultBackupDir(dbPath)
+}
+
+func init() {
+	backupCmd.Flags().StringVar(&backupTo, "to", "", "Backup file (default: a timestamped file in the backup directory)")
+	rootCmd.AddCommand(backupCmd, restoreCmd)
+}
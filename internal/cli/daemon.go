@@ -0,0 +1,233 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/MyAgentHubs/aimemo/internal/locate"
+	"github.com/MyAgentHubs/aimemo/internal/mcp"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonSocket string
+	daemonIdle   time.Duration
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Run one shared MCP server for all clients on a Unix socket",
+	Long: `Run one long-lived MCP server on a per-user Unix socket
+($XDG_RUNTIME_DIR/aimemo/daemon.sock, or ~/.aimemo/daemon.sock).
+
+Clients connect through 'aimemo serve --connect', which starts the daemon if
+it is not running. The daemon opens each database once and shares it among
+all clients, so their writes no longer contend for the file. It exits after
+[server] daemon_idle_minutes (default 10) without clients.
+
+The daemon opens databases with its own configuration, so it only serves
+clients using the same config file, unchanged since the daemon started.
+Others are refused until it exits.
+
+Examples:
+  aimemo daemon
+  aimemo daemon --idle 0`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		socket, err := daemonSocketPath()
+		if err != nil {
+			return err
+		}
+		idle := time.Duration(cfg.Server.DaemonIdleMin) * time.Minute
+		if cmd.Flags().Changed("idle") {
+			idle = daemonIdle
+		}
+
+		ln, err := listenDaemon(socket)
+		if err != nil {
+			return err
+		}
+		config := configKey()
+		opts := dbOptions()
+		opts.Source = db.SourceMCP
+		server := mcp.NewServer(nil, "")
+		server.SetDBOptions(opts)
+		defer server.Close()
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		slog.Info("aimemo daemon starting", "socket", socket, "idle", idle, "config", config)
+		fmt.Fprintf(os.Stderr, "aimemo daemon listening on %s\n", socket)
+		return server.ServeDaemon(ctx, ln, idle, config)
+	},
+}
+
+// daemonSocketPath returns the --socket flag or the per-user default.
+func daemonSocketPath() (string, error) {
+	if daemonSocket != "" {
+		return daemonSocket, nil
+	}
+	return locate.DaemonSocket()
+}
+
+// configKey identifies the configuration this process runs with: the
+// config file's absolute path and a hash of its contents, so a daemon can
+// tell clients whose settings differ from its own.
+func configKey() string {
+	path := configPath()
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return path + " (missing)"
+	}
+	sum := sha256.Sum256(data)
+	return path + " (" + hex.EncodeToString(sum[:4]) + ")"
+}
+
+// socketListener removes its socket file on Close. The socket is created
+// under a temporary name, which net.UnixListener would remove instead.
+type socketListener struct {
+	net.Listener
+	path string
+	file os.FileInfo // the socket file as created, to tell it from a successor's
+}
+
+func (l socketListener) Close() error {
+	// Remove first: closing ends Accept, after which the daemon may exit.
+	// A newer daemon may have replaced a socket it took to be stale; leave
+	// that one in place.
+	if cur, err := os.Stat(l.path); err == nil && os.SameFile(cur, l.file) {
+		os.Remove(l.path)
+	}
+	return l.Listener.Close()
+}
+
+// listenDaemon listens on socket, replacing a stale socket file left by a
+// daemon that did not shut down cleanly. The socket is made private under a
+// temporary name and then renamed into place, so no other user can connect
+// before its permissions are set.
+func listenDaemon(socket string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return nil, err
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", socket)
+	}
+	if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	tmp := fmt.Sprintf("%s.%d", socket, os.Getpid())
+	os.Remove(tmp)
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, socket); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	file, err := os.Stat(socket)
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return socketListener{Listener: ln, path: socket, file: file}, nil
+}
+
+// proxyToDaemon connects stdin and stdout to the daemon, starting it if it
+// is not running, as the client's own database would be served on stdio.
+func proxyToDaemon() error {
+	socket, err := daemonSocketPath()
+	if err != nil {
+		return err
+	}
+	dbPath, err := locate.FindProjectDB(contextFlag)
+	if err != nil {
+		return fmt.Errorf("find db: %w", err)
+	}
+
+	config := configKey()
+	conn, err := mcp.DialDaemon(socket, dbPath, config)
+	if errors.Is(err, mcp.ErrDaemonRefused) {
+		return err
+	}
+	if err != nil {
+		if err := startDaemon(socket); err != nil {
+			return fmt.Errorf("start daemon: %w", err)
+		}
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+			if conn, err = mcp.DialDaemon(socket, dbPath, config); err == nil || errors.Is(err, mcp.ErrDaemonRefused) {
+				break
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("connect to daemon on %s: %w", socket, err)
+		}
+	}
+	defer conn.Close()
+
+	go func() {
+		io.Copy(conn, os.Stdin)
+		// Let the daemon finish the requests in flight before it closes.
+		if uc, ok := conn.(*net.UnixConn); ok {
+			uc.CloseWrite()
+		}
+	}()
+	_, err = io.Copy(os.Stdout, conn)
+	return err
+}
+
+// startDaemon launches 'aimemo daemon' in the background, logging to
+// daemon.log next to the socket.
+func startDaemon(socket string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := []string{"daemon", "--socket", socket}
+	if cfgFile != "" {
+		args = append(args, "--config", cfgFile)
+	}
+	if err := os.MkdirAll(filepath.Dir(socket), 0o700); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(filepath.Join(filepath.Dir(socket), "daemon.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stderr = logFile
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+func init() {
+	daemonCmd.Flags().StringVar(&daemonSocket, "socket", "", "Unix socket path (default: per-user socket)")
+	daemonCmd.Flags().DurationVar(&daemonIdle, "idle", 0, "Exit after this long without clients; 0 = never (default: [server] daemon_idle_minutes)")
+	rootCmd.AddCommand(daemonCmd)
+}
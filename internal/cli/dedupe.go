@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dedupeAuto      bool
+	dedupeDryRun    bool
+	dedupeKeep      string
+	dedupeThreshold float64
+)
+
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe [entity-name]",
+	Short: "Find and merge near-duplicate observations",
+	Long: `Find and merge near-duplicate observations.
+
+Observations on the same entity that restate each other (same words after
+normalization, ignoring case, punctuation, stopwords and word endings) are
+grouped into clusters. For each cluster you choose the observation to keep
+and the rest are deleted. With --auto the --keep rule chooses instead, and
+only clusters whose every observation is at least --threshold similar to the
+kept one are merged; clusters linked through a chain of similar pairs are
+left for interactive review.
+
+Examples:
+  aimemo dedupe --dry-run
+  aimemo dedupe auth-service
+  aimemo dedupe --auto --keep newest`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entity := ""
+		if len(args) > 0 {
+			entity = args[0]
+		}
+		switch dedupeKeep {
+		case db.KeepLongest, db.KeepNewest, db.KeepOldest:
+		default:
+			return fmt.Errorf("unknown --keep %q: use longest, newest or oldest", dedupeKeep)
+		}
+
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		ctx := context.Background()
+		clusters, err := database.FindDuplicateClusters(ctx, entity, dedupeThreshold)
+		if err != nil {
+			return fmt.Errorf("dedupe: %w", err)
+		}
+		if len(clusters) == 0 {
+			fmt.Println("No near-duplicate observations found.")
+			return nil
+		}
+
+		in := bufio.NewScanner(os.Stdin)
+		merged, deleted, review := 0, 0, 0
+		for n, c := range clusters {
+			keep, _ := c.Canonical(dedupeKeep)
+			direct := c.Direct(keep, dedupeThreshold)
+			fmt.Printf("\n[%d/%d] %s\n", n+1, len(clusters), c.Entity)
+			for i, o := range c.Observations {
+				mark := " "
+				if i == keep {
+					mark = "*"
+				}
+				t := time.UnixMilli(o.CreatedAt).Format("2006-01-02")
+				fmt.Printf(" %s %d. [%s] %s\n", mark, i+1, t, o.Content)
+			}
+			if !direct {
+				fmt.Println("   (not every observation is similar to the kept one)")
+			}
+			if dedupeDryRun {
+				continue
+			}
+			if dedupeAuto && !direct {
+				review++
+				continue
+			}
+			if !dedupeAuto {
+				choice, ok := promptKeep(in, len(c.Observations), keep)
+				if !ok {
+					break
+				}
+				if choice < 0 {
+					continue
+				}
+				keep = choice
+			}
+			d, err := database.MergeDuplicates(ctx, c, keep)
+			if err != nil {
+				return fmt.Errorf("merge: %w", err)
+			}
+			merged++
+			deleted += d
+		}
+
+		fmt.Println()
+		if dedupeDryRun {
+			fmt.Printf("%d clusters found (* = kept by --keep %s). Run without --dry-run to merge.\n", len(clusters), dedupeKeep)
+			return nil
+		}
+		fmt.Printf("Merged %d of %d clusters, deleted %d observations.\n", merged, len(clusters), deleted)
+		if review > 0 {
+			fmt.Printf("%d clusters need review: run without --auto to merge them.\n", review)
+		}
+		return nil
+	},
+}
+
+// promptKeep asks which observation of a cluster to keep. It returns the
+// chosen index, -1 to skip the cluster, or ok=false to stop.
+func promptKeep(in *bufio.Scanner, n, def int) (choice int, ok bool) {
+	for {
+		fmt.Printf("Keep which? [1-%d, Enter=%d, s=skip, q=quit]: ", n, def+1)
+		if !in.Scan() {
+			fmt.Println()
+			return 0, false
+		}
+		answer := strings.TrimSpace(in.Text())
+		switch answer {
+		case "":
+			return def, true
+		case "s":
+			return -1, true
+		case "q":
+			return 0, false
+		}
+		if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= n {
+			return i - 1, true
+		}
+	}
+}
+
+func init() {
+	dedupeCmd.Flags().BoolVar(&dedupeAuto, "auto", false, "Merge clusters without prompting, keeping the --keep choice; clusters needing review are skipped")
+	dedupeCmd.Flags().BoolVar(&dedupeDryRun, "dry-run", false, "List clusters without changing anything")
+	dedupeCmd.Flags().StringVar(&dedupeKeep, "keep", db.KeepLongest, "Observation to keep: longest|newest|oldest")
+	dedupeCmd.Flags().Float64Var(&dedupeThreshold, "threshold", db.NearDuplicateThreshold, "Similarity (0-1) at which observations count as duplicates")
+	rootCmd.AddCommand(dedupeCmd)
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package cli
+
+import "os/exec"
+
+// detach is a no-op where background processes already outlive their parent.
+func detach(cmd *exec.Cmd) {}
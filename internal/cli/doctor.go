@@ -31,7 +31,7 @@ var doctorCmd = &cobra.Command{
 		check("Storage path: "+dbPath, err == nil, fmt.Sprintf("%v", err))
 
 		// 2. Database writable
-		database, dbErr := db.Open(dbPath)
+		database, dbErr := db.OpenWithOptions(dbPath, dbOptions())
 		check("Database writable", dbErr == nil, fmt.Sprintf("%v", dbErr))
 		if dbErr != nil {
 			printDoctorResult(allOK)
@@ -49,7 +49,12 @@ var doctorCmd = &cobra.Command{
 		_ = database.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode)
 		check("WAL mode enabled", journalMode == "wal", "journal_mode="+journalMode)
 
-		// 5. MCP empty-query latency
+		// 5. Schema version
+		version, verErr := database.SchemaVersion(ctx)
+		check(fmt.Sprintf("Schema version %d (latest %d)", version, db.LatestSchemaVersion()),
+			verErr == nil && version == db.LatestSchemaVersion(), fmt.Sprintf("run 'aimemo migrate status' (%v)", verErr))
+
+		// 6. MCP empty-query latency
 		start := time.Now()
 		_, err = database.Search(ctx, "", "", nil, "", 1)
 		elapsed := time.Since(start)
@@ -1,20 +1,37 @@
 package cli
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/MyAgentHubs/aimemo/internal/db"
 	"github.com/spf13/cobra"
 )
 
-var exportFormat string
+var (
+	exportFormat string
+	exportType   string
+	exportTags   []string
+	exportRoot   string
+	exportDepth  int
+)
 
 var exportCmd = &cobra.Command{
 	Use:   "export",
-	Short: "Export memory to JSON or Markdown",
+	Short: "Export memory to JSON, Markdown, or a graph diagram",
+	Long: `Export memory to JSON, Markdown, or a graph diagram.
+
+Graph formats (dot, mermaid, graphml) render entities as nodes styled by
+entity type and labeled with tags, and relations as labeled edges.
+
+Examples:
+  aimemo export --format json > memory.json
+  aimemo export --format mermaid --type module
+  aimemo export --format dot --root api --depth 2 | dot -Tsvg > api.svg`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		database, _, err := openDB()
 		if err != nil {
@@ -23,24 +40,116 @@ var exportCmd = &cobra.Command{
 		defer database.Close()
 
 		ctx := context.Background()
-		// Export all entities by using the max limit (50) and paginating if needed.
-		// For simplicity use ListEntities directly with no limit cap.
-		entities, err := database.Search(ctx, "", "", nil, "name", 1000)
+		// Index the exported entities by ID first so relations and type colors
+		// are known up front; the entities themselves are then streamed page
+		// by page, so memory use does not grow with their observations.
+		types, err := database.EntityTypes(ctx, exportType, exportTags)
 		if err != nil {
 			return fmt.Errorf("export: %w", err)
 		}
+		if exportRoot != "" {
+			types, err = restrictToNeighborhood(ctx, database, types, exportRoot, exportDepth)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+		}
+		rels, err := relationsWithin(ctx, database, types)
+		if err != nil {
+			return fmt.Errorf("export relations: %w", err)
+		}
 
+		out := bufio.NewWriter(os.Stdout)
+		var w exportWriter
 		switch exportFormat {
 		case "json":
-			return exportJSON(ctx, database, entities)
+			w = newJSONExport(out, rels)
 		case "markdown", "md":
-			return exportMarkdown(entities)
+			w = newMarkdownExport(out, rels)
+		case "dot":
+			w = newDOTExport(out, types, rels)
+		case "mermaid":
+			w = newMermaidExport(out, types, rels)
+		case "graphml":
+			w = newGraphMLExport(out, types, rels)
 		default:
-			return fmt.Errorf("unknown format %q: use json or markdown", exportFormat)
+			return fmt.Errorf("unknown format %q: use json, markdown, dot, mermaid or graphml", exportFormat)
 		}
+
+		w.begin()
+		cursor := ""
+		for {
+			entities, next, err := database.SearchPage(ctx, "", exportType, exportTags, "name", pageSize, cursor)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+			for _, e := range entities {
+				// Skip entities outside the neighborhood, or created mid-export.
+				if _, ok := types[e.ID]; ok {
+					w.entity(e)
+				}
+			}
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		w.end()
+		return out.Flush()
 	},
 }
 
+// exportWriter writes one export format incrementally: begin once, entity
+// for each exported entity in order, then end.
+type exportWriter interface {
+	begin()
+	entity(e db.SearchResult)
+	end()
+}
+
+// restrictToNeighborhood keeps only the entities within depth hops of root.
+func restrictToNeighborhood(ctx context.Context, database *db.DB, types map[int64]string, root string, depth int) (map[int64]string, error) {
+	g, err := database.Neighbors(ctx, root, db.GraphOptions{Depth: depth, MaxNodes: db.MaxGraphNodes})
+	if err != nil {
+		return nil, err
+	}
+	if g.Truncated {
+		fmt.Fprintf(os.Stderr, "Warning: neighborhood of %q truncated at %d entities\n", root, len(g.Nodes))
+	}
+	out := make(map[int64]string, len(g.Nodes))
+	for _, n := range g.Nodes {
+		if t, ok := types[n.ID]; ok {
+			out[n.ID] = t
+		}
+	}
+	return out, nil
+}
+
+// relationsWithin returns the relations whose endpoints are both exported entities.
+func relationsWithin(ctx context.Context, database *db.DB, types map[int64]string) ([]db.Relation, error) {
+	all, err := database.ListRelations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rels []db.Relation
+	for _, r := range all {
+		_, from := types[r.FromID]
+		_, to := types[r.ToID]
+		if from && to {
+			rels = append(rels, r)
+		}
+	}
+	return rels, nil
+}
+
+// outgoingRelations groups rels by source entity.
+func outgoingRelations(rels []db.Relation) map[int64][]db.Relation {
+	outgoing := map[int64][]db.Relation{}
+	for _, rel := range rels {
+		outgoing[rel.FromID] = append(outgoing[rel.FromID], rel)
+	}
+	return outgoing
+}
+
 // exportEntry is the mcp-knowledge-graph compatible JSONL format.
 type exportEntry struct {
 	Type         string   `json:"type"`
@@ -53,63 +162,105 @@ type exportEntry struct {
 	RelationType string   `json:"relationType,omitempty"`
 }
 
-func exportJSON(ctx context.Context, database *db.DB, entities []db.SearchResult) error {
-	var entries []exportEntry
-	for _, r := range entities {
-		entry := exportEntry{
-			Type:         "entity",
-			Name:         r.Name,
-			EntityType:   r.EntityType,
-			Observations: r.Observations,
-			Tags:         r.Tags,
-		}
-		if entry.Observations == nil {
-			entry.Observations = []string{}
-		}
-		if entry.Tags == nil {
-			entry.Tags = []string{}
-		}
-		entries = append(entries, entry)
+// jsonExport writes an indented JSON array of entries, each entity followed
+// by its outgoing relations.
+type jsonExport struct {
+	w        *bufio.Writer
+	outgoing map[int64][]db.Relation
+	n        int
+}
 
-		rels, err := database.ListRelationsByEntity(ctx, r.Name)
-		if err != nil {
-			continue
-		}
-		for _, rel := range rels {
-			if rel.FromName == r.Name {
-				entries = append(entries, exportEntry{
-					Type:         "relation",
-					From:         rel.FromName,
-					To:           rel.ToName,
-					RelationType: rel.Relation,
-				})
-			}
-		}
+func newJSONExport(w *bufio.Writer, rels []db.Relation) *jsonExport {
+	return &jsonExport{w: w, outgoing: outgoingRelations(rels)}
+}
+
+func (x *jsonExport) begin() {}
+
+func (x *jsonExport) entity(r db.SearchResult) {
+	entry := exportEntry{
+		Type:         "entity",
+		Name:         r.Name,
+		EntityType:   r.EntityType,
+		Observations: r.Observations,
+		Tags:         r.Tags,
+	}
+	if entry.Observations == nil {
+		entry.Observations = []string{}
+	}
+	if entry.Tags == nil {
+		entry.Tags = []string{}
 	}
+	x.write(entry)
 
-	enc := json.NewEncoder(stdoutWriter{})
-	enc.SetIndent("", "  ")
-	return enc.Encode(entries)
+	for _, rel := range x.outgoing[r.ID] {
+		x.write(exportEntry{
+			Type:         "relation",
+			From:         rel.FromName,
+			To:           rel.ToName,
+			RelationType: rel.Relation,
+		})
+	}
 }
 
-func exportMarkdown(entities []db.SearchResult) error {
-	fmt.Println("# Memory Export")
-	fmt.Println()
-	for _, r := range entities {
-		tags := ""
-		if len(r.Tags) > 0 {
-			tags = " `" + strings.Join(r.Tags, "` `") + "`"
-		}
-		fmt.Printf("## %s (%s)%s\n\n", r.Name, r.EntityType, tags)
-		for _, obs := range r.Observations {
-			fmt.Printf("- %s\n", obs)
+func (x *jsonExport) write(entry exportEntry) {
+	if x.n == 0 {
+		x.w.WriteString("[\n  ")
+	} else {
+		x.w.WriteString(",\n  ")
+	}
+	b, _ := json.MarshalIndent(entry, "  ", "  ")
+	x.w.Write(b)
+	x.n++
+}
+
+func (x *jsonExport) end() {
+	if x.n == 0 {
+		x.w.WriteString("[]\n")
+		return
+	}
+	x.w.WriteString("\n]\n")
+}
+
+// markdownExport writes a heading per entity with its observations and
+// outgoing relations.
+type markdownExport struct {
+	w        *bufio.Writer
+	outgoing map[int64][]db.Relation
+}
+
+func newMarkdownExport(w *bufio.Writer, rels []db.Relation) *markdownExport {
+	return &markdownExport{w: w, outgoing: outgoingRelations(rels)}
+}
+
+func (x *markdownExport) begin() {
+	x.w.WriteString("# Memory Export\n\n")
+}
+
+func (x *markdownExport) entity(r db.SearchResult) {
+	tags := ""
+	if len(r.Tags) > 0 {
+		tags = " `" + strings.Join(r.Tags, "` `") + "`"
+	}
+	fmt.Fprintf(x.w, "## %s (%s)%s\n\n", r.Name, r.EntityType, tags)
+	for _, obs := range r.Observations {
+		fmt.Fprintf(x.w, "- %s\n", obs)
+	}
+	if out := x.outgoing[r.ID]; len(out) > 0 {
+		x.w.WriteString("\nRelations:\n")
+		for _, rel := range out {
+			fmt.Fprintf(x.w, "- %s → %s\n", rel.Relation, rel.ToName)
 		}
-		fmt.Println()
 	}
-	return nil
+	x.w.WriteString("\n")
 }
 
+func (x *markdownExport) end() {}
+
 func init() {
-	exportCmd.Flags().StringVar(&exportFormat, "format", "json", "Output format: json|markdown")
+	exportCmd.Flags().StringVar(&exportFormat, "format", "json", "Output format: json|markdown|dot|mermaid|graphml")
+	exportCmd.Flags().StringVar(&exportType, "type", "", "Filter by entity type")
+	exportCmd.Flags().StringArrayVar(&exportTags, "tag", nil, "Filter by tag (AND); can be repeated")
+	exportCmd.Flags().StringVar(&exportRoot, "root", "", "Only export the neighborhood of this entity")
+	exportCmd.Flags().IntVar(&exportDepth, "depth", db.DefaultGraphDepth, "Hops from --root to include")
 	rootCmd.AddCommand(exportCmd)
 }
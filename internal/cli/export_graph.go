@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+)
+
+// typePalette assigns fill colors to entity types in sorted order, cycling when exhausted.
+var typePalette = []string{
+	"#dbeafe", "#dcfce7", "#fef3c7", "#fce7f3", "#ede9fe",
+	"#cffafe", "#fee2e2", "#e0e7ff", "#f3f4f6", "#ecfccb",
+}
+
+// typeColors maps each entity type in types (keyed by entity ID) to a palette color.
+func typeColors(types map[int64]string) map[string]string {
+	seen := map[string]bool{}
+	var sorted []string
+	for _, t := range types {
+		if !seen[t] {
+			seen[t] = true
+			sorted = append(sorted, t)
+		}
+	}
+	sort.Strings(sorted)
+	colors := make(map[string]string, len(sorted))
+	for i, t := range sorted {
+		colors[t] = typePalette[i%len(typePalette)]
+	}
+	return colors
+}
+
+// graphNodeID is the stable node identifier used by all graph formats.
+func graphNodeID(id int64) string {
+	return fmt.Sprintf("n%d", id)
+}
+
+// dotExport writes a Graphviz digraph.
+type dotExport struct {
+	w      *bufio.Writer
+	colors map[string]string
+	rels   []db.Relation
+}
+
+func newDOTExport(w *bufio.Writer, types map[int64]string, rels []db.Relation) *dotExport {
+	return &dotExport{w: w, colors: typeColors(types), rels: rels}
+}
+
+func (x *dotExport) begin() {
+	x.w.WriteString("digraph memory {\n")
+	x.w.WriteString("  rankdir=LR;\n")
+	x.w.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=\"Helvetica\"];\n")
+	x.w.WriteString("  edge [fontname=\"Helvetica\", fontsize=10];\n\n")
+}
+
+func (x *dotExport) entity(e db.SearchResult) {
+	label := e.Name + "\n«" + e.EntityType + "»"
+	if len(e.Tags) > 0 {
+		label += "\n[" + strings.Join(e.Tags, ", ") + "]"
+	}
+	fmt.Fprintf(x.w, "  %s [label=%s, fillcolor=%q];\n", graphNodeID(e.ID), dotQuote(label), x.colors[e.EntityType])
+}
+
+func (x *dotExport) end() {
+	if len(x.rels) > 0 {
+		x.w.WriteString("\n")
+	}
+	for _, r := range x.rels {
+		fmt.Fprintf(x.w, "  %s -> %s [label=%s];\n", graphNodeID(r.FromID), graphNodeID(r.ToID), dotQuote(r.Relation))
+	}
+	x.w.WriteString("}\n")
+}
+
+// dotQuote quotes s as a DOT string, preserving newlines as line breaks.
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+// mermaidExport writes a Mermaid flowchart. Class assignments come last, so
+// it collects the node IDs of each type while streaming.
+type mermaidExport struct {
+	w      *bufio.Writer
+	colors map[string]string
+	rels   []db.Relation
+	nodes  map[string][]string // entity type -> node IDs
+}
+
+func newMermaidExport(w *bufio.Writer, types map[int64]string, rels []db.Relation) *mermaidExport {
+	return &mermaidExport{w: w, colors: typeColors(types), rels: rels, nodes: map[string][]string{}}
+}
+
+func (x *mermaidExport) begin() {
+	x.w.WriteString("flowchart LR\n")
+}
+
+func (x *mermaidExport) entity(e db.SearchResult) {
+	label := mermaidEscape(e.Name)
+	if len(e.Tags) > 0 {
+		label += "<br/><small>" + mermaidEscape(strings.Join(e.Tags, ", ")) + "</small>"
+	}
+	fmt.Fprintf(x.w, "  %s[\"%s\"]\n", graphNodeID(e.ID), label)
+	x.nodes[e.EntityType] = append(x.nodes[e.EntityType], graphNodeID(e.ID))
+}
+
+func (x *mermaidExport) end() {
+	for _, r := range x.rels {
+		fmt.Fprintf(x.w, "  %s -->|\"%s\"| %s\n", graphNodeID(r.FromID), mermaidEscape(r.Relation), graphNodeID(r.ToID))
+	}
+
+	types := make([]string, 0, len(x.nodes))
+	for t := range x.nodes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Fprintf(x.w, "  classDef %s fill:%s,stroke:#555\n", mermaidClass(t), x.colors[t])
+	}
+	for _, t := range types {
+		fmt.Fprintf(x.w, "  class %s %s\n", strings.Join(x.nodes[t], ","), mermaidClass(t))
+	}
+}
+
+// mermaidEscape replaces characters that break Mermaid quoted labels.
+func mermaidEscape(s string) string {
+	r := strings.NewReplacer(`"`, "#quot;", "\n", " ", "<", "#lt;", ">", "#gt;")
+	return r.Replace(s)
+}
+
+// mermaidClass derives a valid Mermaid class name from an entity type.
+func mermaidClass(entityType string) string {
+	var b strings.Builder
+	b.WriteString("type_")
+	for _, r := range strings.ToLower(entityType) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// graphMLExport writes a GraphML document with entity type, tags and relation as data keys.
+type graphMLExport struct {
+	w      *bufio.Writer
+	colors map[string]string
+	rels   []db.Relation
+}
+
+func newGraphMLExport(w *bufio.Writer, types map[int64]string, rels []db.Relation) *graphMLExport {
+	return &graphMLExport{w: w, colors: typeColors(types), rels: rels}
+}
+
+func (x *graphMLExport) begin() {
+	x.w.WriteString(xml.Header)
+	x.w.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	x.w.WriteString(`  <key id="name" for="node" attr.name="name" attr.type="string"/>` + "\n")
+	x.w.WriteString(`  <key id="entity_type" for="node" attr.name="entity_type" attr.type="string"/>` + "\n")
+	x.w.WriteString(`  <key id="tags" for="node" attr.name="tags" attr.type="string"/>` + "\n")
+	x.w.WriteString(`  <key id="color" for="node" attr.name="color" attr.type="string"/>` + "\n")
+	x.w.WriteString(`  <key id="relation" for="edge" attr.name="relation" attr.type="string"/>` + "\n")
+	x.w.WriteString(`  <graph id="memory" edgedefault="directed">` + "\n")
+}
+
+func (x *graphMLExport) entity(e db.SearchResult) {
+	fmt.Fprintf(x.w, "    <node id=\"%s\">\n", graphNodeID(e.ID))
+	writeGraphMLData(x.w, "name", e.Name)
+	writeGraphMLData(x.w, "entity_type", e.EntityType)
+	writeGraphMLData(x.w, "tags", strings.Join(e.Tags, ", "))
+	writeGraphMLData(x.w, "color", x.colors[e.EntityType])
+	x.w.WriteString("    </node>\n")
+}
+
+func (x *graphMLExport) end() {
+	for _, r := range x.rels {
+		fmt.Fprintf(x.w, "    <edge id=\"e%d\" source=\"%s\" target=\"%s\">\n", r.ID, graphNodeID(r.FromID), graphNodeID(r.ToID))
+		writeGraphMLData(x.w, "relation", r.Relation)
+		x.w.WriteString("    </edge>\n")
+	}
+	x.w.WriteString("  </graph>\n</graphml>\n")
+}
+
+func writeGraphMLData(w *bufio.Writer, key, value string) {
+	fmt.Fprintf(w, "      <data key=\"%s\">", key)
+	_ = xml.EscapeText(w, []byte(value))
+	w.WriteString("</data>\n")
+}
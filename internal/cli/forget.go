@@ -32,7 +32,7 @@ var forgetCmd = &cobra.Command{
 			if err := database.SoftDeleteEntity(ctx, name); err != nil {
 				return fmt.Errorf("soft delete: %w", err)
 			}
-			fmt.Printf("Soft-deleted entity: %s (recoverable)\n", name)
+			fmt.Printf("Soft-deleted entity: %s (restore with 'aimemo trash restore %s')\n", name, name)
 		}
 		return nil
 	},
@@ -34,7 +34,7 @@ var getCmd = &cobra.Command{
 				name,
 			).Scan(&count)
 			if count > 0 {
-				return fmt.Errorf("entity %q is soft-deleted; re-add it to restore, or run 'aimemo forget %s --permanent' to hard-delete", name, name)
+				return fmt.Errorf("entity %q is in the trash; run 'aimemo trash restore %s' to restore it", name, name)
 			}
 			return fmt.Errorf("entity %q not found", name)
 		}
@@ -47,20 +47,23 @@ var getCmd = &cobra.Command{
 		fmt.Printf("Name:         %s\n", e.Name)
 		fmt.Printf("Type:         %s\n", e.EntityType)
 		fmt.Printf("Tags:         %s\n", tags)
+		if len(e.Aliases) > 0 {
+			fmt.Printf("Aliases:      %s\n", strings.Join(e.Aliases, ", "))
+		}
 		fmt.Printf("Access count: %d\n", e.AccessCount)
 		fmt.Printf("Observations (%d):\n", len(e.Observations))
 		for _, obs := range e.Observations {
 			fmt.Printf("  - %s\n", obs)
 		}
 
-		rels, err := database.ListRelationsByEntity(ctx, name)
+		rels, err := database.ListRelationsByEntity(ctx, e.Name)
 		if err != nil {
 			return err
 		}
 		if len(rels) > 0 {
 			fmt.Printf("Relations (%d):\n", len(rels))
 			for _, r := range rels {
-				fmt.Printf("  %s -[%s]-> %s\n", r.FromName, r.Relation, r.ToName)
+				fmt.Printf("  %s -[%s]-> %s  (#%d)\n", r.FromName, r.Relation, r.ToName, r.ID)
 			}
 		}
 		return nil
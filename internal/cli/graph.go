@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	graphDepth     int
+	graphRelations []string
+	graphDirection string
+	graphMaxNodes  int
+	graphTo        string
+)
+
+var graphCmd = &cobra.Command{
+	Use:   "graph <entity>",
+	Short: "Show the relation graph around an entity, or a path to another",
+	Long: `Show every entity within --depth hops of <entity>, or with --to, the
+shortest chain of relations between two entities.
+
+Examples:
+  aimemo graph Redis --direction in --depth 2     # what depends on Redis
+  aimemo graph api --relation depends_on          # only follow depends_on edges
+  aimemo graph checkout --to Redis                # how checkout reaches Redis`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		ctx := context.Background()
+		opts := db.GraphOptions{Depth: graphDepth, Relations: graphRelations, Direction: graphDirection, MaxNodes: graphMaxNodes}
+
+		if graphTo != "" {
+			path, err := database.ShortestPath(ctx, args[0], graphTo, opts)
+			if err != nil {
+				return fmt.Errorf("graph: %w", err)
+			}
+			if outputJSON {
+				return printJSON(path)
+			}
+			if path == nil {
+				fmt.Printf("No path from %s to %s.\n", args[0], graphTo)
+				return nil
+			}
+			fmt.Printf("%d hop(s):\n", path.Hops)
+			for _, r := range path.Edges {
+				fmt.Printf("  %s -[%s]-> %s\n", r.FromName, r.Relation, r.ToName)
+			}
+			return nil
+		}
+
+		g, err := database.Neighbors(ctx, args[0], opts)
+		if err != nil {
+			return fmt.Errorf("graph: %w", err)
+		}
+		if outputJSON {
+			return printJSON(g)
+		}
+
+		byDepth := map[int][]string{}
+		maxDepth := 0
+		for _, n := range g.Nodes {
+			byDepth[n.Depth] = append(byDepth[n.Depth], fmt.Sprintf("%s (%s)", n.Name, n.EntityType))
+			if n.Depth > maxDepth {
+				maxDepth = n.Depth
+			}
+		}
+		fmt.Printf("• %s\n", g.Root)
+		for d := 1; d <= maxDepth; d++ {
+			fmt.Printf("  %d hop(s): %s\n", d, strings.Join(byDepth[d], ", "))
+		}
+		if len(g.Edges) > 0 {
+			fmt.Printf("Relations (%d):\n", len(g.Edges))
+			for _, r := range g.Edges {
+				fmt.Printf("  %s -[%s]-> %s\n", r.FromName, r.Relation, r.ToName)
+			}
+		}
+		if g.Truncated {
+			fmt.Printf("(truncated at %d nodes; raise --max-nodes to see more)\n", len(g.Nodes))
+		}
+		return nil
+	},
+}
+
+func init() {
+	graphCmd.Flags().IntVar(&graphDepth, "depth", 0, "Max hops (default 2, or 6 with --to)")
+	graphCmd.Flags().StringArrayVar(&graphRelations, "relation", nil, "Only follow this relation type; can be repeated")
+	graphCmd.Flags().StringVar(&graphDirection, "direction", "both", "Edge direction: out|in|both")
+	graphCmd.Flags().IntVar(&graphMaxNodes, "max-nodes", db.DefaultGraphMaxNodes, "Node cap")
+	graphCmd.Flags().StringVar(&graphTo, "to", "", "Find the shortest path to this entity")
+	graphCmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+	rootCmd.AddCommand(graphCmd)
+}
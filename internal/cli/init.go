@@ -18,6 +18,7 @@ var initCmd = &cobra.Command{
 		gitignore := `# aimemo memory database (binary, not diff-friendly)
 memory.db
 memory-*.db
+backups/
 # Export files are gitignore-exempt so you can commit them
 !memory-export.json
 !memory-export.md
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/MyAgentHubs/aimemo/internal/db"
 	"github.com/spf13/cobra"
 )
 
@@ -48,7 +49,9 @@ var journalCmd = &cobra.Command{
 		defer database.Close()
 
 		ctx := context.Background()
-		entries, err := database.ListJournal(ctx, journalSince, journalLimit)
+		entries, err := fetchPages(journalLimit, func(n int, cursor string) ([]db.JournalEntry, string, error) {
+			return database.ListJournalPage(ctx, journalSince, n, cursor)
+		})
 		if err != nil {
 			return fmt.Errorf("journal: %w", err)
 		}
@@ -73,7 +76,7 @@ var journalCmd = &cobra.Command{
 
 func init() {
 	journalCmd.Flags().StringVar(&journalSince, "since", "24h", "Time window: 2h|24h|7d|ISO date")
-	journalCmd.Flags().IntVar(&journalLimit, "limit", 50, "Max entries")
+	journalCmd.Flags().IntVar(&journalLimit, "limit", 50, "Max entries (0 = all)")
 	rootCmd.AddCommand(appendCmd)
 	rootCmd.AddCommand(journalCmd)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/MyAgentHubs/aimemo/internal/db"
 	"github.com/spf13/cobra"
 )
 
@@ -25,7 +26,9 @@ var listCmd = &cobra.Command{
 		defer database.Close()
 
 		ctx := context.Background()
-		results, err := database.Search(ctx, "", listType, listTags, listSort, listLimit)
+		results, err := fetchPages(listLimit, func(n int, cursor string) ([]db.SearchResult, string, error) {
+			return database.SearchPage(ctx, "", listType, listTags, listSort, n, cursor)
+		})
 		if err != nil {
 			return fmt.Errorf("list: %w", err)
 		}
@@ -43,7 +46,7 @@ var listCmd = &cobra.Command{
 func init() {
 	listCmd.Flags().StringVar(&listType, "type", "", "Filter by entity type")
 	listCmd.Flags().StringArrayVar(&listTags, "tag", nil, "Filter by tag (AND); can be repeated")
-	listCmd.Flags().IntVar(&listLimit, "limit", 50, "Max results")
+	listCmd.Flags().IntVar(&listLimit, "limit", 50, "Max results (0 = all)")
 	listCmd.Flags().StringVar(&listSort, "sort", "recent", "Sort: recent|accessed|name")
 	rootCmd.AddCommand(listCmd)
 }
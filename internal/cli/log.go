@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var logSince string
+var logLimit int
+var logPruneBefore string
+
+var logCmd = &cobra.Command{
+	Use:   "log [entity-name]",
+	Short: "Show the history of changes to memory",
+	Long: `Show the change log, newest first: every write to entities, observations,
+relations, aliases, tasks and the journal, with where it came from (cli or
+mcp). Naming an entity shows its history, including changes made under
+former names and after it was deleted.
+
+Use --json to see the full before/after rows of each change.
+
+--prune-before permanently deletes older entries instead of listing. Pruned
+changes can no longer be undone. Set [log] retain_days to prune on every
+open.
+
+Examples:
+  aimemo log
+  aimemo log AuthService --since 7d
+  aimemo log --prune-before 90d`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if logPruneBefore != "" {
+			if len(args) > 0 || logSince != "" {
+				return fmt.Errorf("--prune-before cannot be combined with an entity or --since")
+			}
+			return pruneLog(logPruneBefore)
+		}
+		f := db.ChangeFilter{Limit: logLimit}
+		if len(args) == 1 {
+			f.Entity = args[0]
+		}
+		if logSince != "" {
+			var err error
+			if f.Since, err = db.ParseSince(logSince); err != nil {
+				return err
+			}
+		}
+
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		changes, err := database.ListChanges(context.Background(), f)
+		if err != nil {
+			return fmt.Errorf("log: %w", err)
+		}
+		if outputJSON {
+			if changes == nil {
+				changes = []db.Change{}
+			}
+			return printJSON(changes)
+		}
+		if len(changes) == 0 {
+			fmt.Println("No changes found.")
+			return nil
+		}
+		for _, c := range changes {
+			t := time.UnixMilli(c.CreatedAt).Format("2006-01-02 15:04:05")
+			source := c.Source
+			if source == "" {
+				source = "-"
+			}
+			fmt.Printf("#%d [%s] %-3s %-19s %s", c.ID, t, source, c.Op, c.Entity)
+			if s := c.Summary(); s != "" {
+				fmt.Printf(" — %s", s)
+			}
+			if c.Undoes != nil {
+				fmt.Printf(" (undoes #%d)", *c.Undoes)
+			}
+			if c.UndoneBy != nil {
+				fmt.Printf(" (undone by #%d)", *c.UndoneBy)
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+// pruneLog deletes change log entries older than the --prune-before value.
+func pruneLog(before string) error {
+	cutoff, err := db.ParseSince(before)
+	if err != nil {
+		return err
+	}
+	database, _, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	n, err := database.PruneChanges(context.Background(), cutoff)
+	if err != nil {
+		return err
+	}
+	if outputJSON {
+		return printJSON(map[string]any{"pruned": n, "before": cutoff})
+	}
+	fmt.Printf("Pruned %d changes logged before %s.\n", n, time.UnixMilli(cutoff).Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+func init() {
+	logCmd.Flags().StringVar(&logSince, "since", "", "Only changes in this window: 2h|24h|7d|ISO date")
+	logCmd.Flags().StringVar(&logPruneBefore, "prune-before", "", "Delete changes older than this instead of listing: 90d|ISO date")
+	logCmd.Flags().IntVar(&logLimit, "limit", 50, "Max changes (0 = all)")
+	logCmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+	rootCmd.AddCommand(logCmd)
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:   "rename <entity-name> <new-name>",
+	Short: "Rename an entity, keeping the old name as an alias",
+	Long: `Rename an entity, keeping its observations and relations.
+
+The old name stays behind as an alias, so existing references still resolve.
+
+Example:
+  aimemo rename AuthService auth-service`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		e, err := database.RenameEntity(context.Background(), args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("rename: %w", err)
+		}
+		fmt.Printf("Renamed %s -> %s\n", args[0], e.Name)
+		return nil
+	},
+}
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge <source>... <target>",
+	Short: "Merge entities into one, moving observations and relations",
+	Long: `Merge one or more entities into a target entity.
+
+Observations and relations move to the target; duplicates and relations that
+would point from the target to itself are dropped. Tags are combined, access
+counts summed, and each source name stays behind as an alias of the target.
+
+Example:
+  aimemo merge AuthService "authentication service" auth-service`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := args[len(args)-1]
+
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		ctx := context.Background()
+		for _, source := range args[:len(args)-1] {
+			r, err := database.MergeEntities(ctx, source, target)
+			if err != nil {
+				return fmt.Errorf("merge %q: %w", source, err)
+			}
+			fmt.Printf("Merged %s into %s: %d observations, %d relations moved", r.Merged, r.Entity, r.Observations, r.Relations)
+			if dropped := r.DroppedObservations + r.DroppedRelations; dropped > 0 {
+				fmt.Printf(" (%d duplicate observations, %d redundant relations dropped)", r.DroppedObservations, r.DroppedRelations)
+			}
+			fmt.Println()
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renameCmd, mergeCmd)
+}
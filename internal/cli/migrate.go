@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var migrateTo int
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Inspect or change the database schema version",
+	Long: `Inspect or change the database schema version.
+
+Databases are migrated to the latest version automatically when opened, so
+'migrate up' is rarely needed. Use 'migrate down --to N' before switching back
+to an older aimemo binary that only understands schema version N.`,
+}
+
+var migrateStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show applied and pending migrations",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, dbPath, err := openDBWithOptions(rawDBOptions())
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		ctx := context.Background()
+		current, err := database.SchemaVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("schema version: %w", err)
+		}
+		statuses, err := database.MigrationStatus(ctx)
+		if err != nil {
+			return fmt.Errorf("migration status: %w", err)
+		}
+
+		fmt.Printf("Database: %s\n", dbPath)
+		fmt.Printf("Schema version: %d (latest: %d)\n", current, db.LatestSchemaVersion())
+		if current > db.LatestSchemaVersion() {
+			fmt.Println("This database was migrated by a newer aimemo; upgrade aimemo to use it.")
+		}
+		fmt.Println()
+		for _, st := range statuses {
+			state := "pending"
+			if st.Applied {
+				state = "applied " + time.UnixMilli(*st.AppliedAt).Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("  %3d  %-32s %s\n", st.Version, st.Name, state)
+		}
+		return nil
+	},
+}
+
+var migrateUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply pending migrations (to the latest version, or --to N)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := db.LatestSchemaVersion()
+		if cmd.Flags().Changed("to") {
+			target = migrateTo
+		}
+		return runMigrate(target, true)
+	},
+}
+
+var migrateDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Revert the last migration (or down to --to N)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		target := -1 // one step below current, resolved after opening
+		if cmd.Flags().Changed("to") {
+			target = migrateTo
+		}
+		return runMigrate(target, false)
+	},
+}
+
+// runMigrate moves the schema to target. A negative target with up=false means one step down.
+func runMigrate(target int, up bool) error {
+	database, _, err := openDBWithOptions(rawDBOptions())
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	ctx := context.Background()
+	current, err := database.SchemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("schema version: %w", err)
+	}
+	if target < 0 {
+		target = current - 1
+	}
+	if up && target < current {
+		return fmt.Errorf("target version %d is below current version %d; use 'aimemo migrate down'", target, current)
+	}
+	if !up && target > current {
+		return fmt.Errorf("target version %d is above current version %d; use 'aimemo migrate up'", target, current)
+	}
+	if target == current {
+		fmt.Printf("Already at schema version %d.\n", current)
+		return nil
+	}
+
+	if err := database.MigrateTo(ctx, target); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+	fmt.Printf("Migrated schema from version %d to %d.\n", current, target)
+	return nil
+}
+
+// rawDBOptions opens the database without applying pending migrations.
+func rawDBOptions() db.Options {
+	opts := dbOptions()
+	opts.SkipMigrate = true
+	return opts
+}
+
+func init() {
+	migrateUpCmd.Flags().IntVar(&migrateTo, "to", 0, "Target schema version (default: latest)")
+	migrateDownCmd.Flags().IntVar(&migrateTo, "to", 0, "Target schema version (default: one step down)")
+	migrateCmd.AddCommand(migrateStatusCmd, migrateUpCmd, migrateDownCmd)
+	rootCmd.AddCommand(migrateCmd)
+}
@@ -3,7 +3,9 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
 
+	"github.com/MyAgentHubs/aimemo/internal/db"
 	"github.com/spf13/cobra"
 )
 
@@ -30,14 +32,27 @@ var observeCmd = &cobra.Command{
 			return fmt.Errorf("entity %q not found — use 'aimemo add' to create it", name)
 		}
 
-		if err := database.AddObservation(ctx, e.ID, content); err != nil {
+		dups, err := database.AddObservationWithDuplicates(ctx, e.ID, content)
+		if err != nil {
 			return fmt.Errorf("add observation: %w", err)
 		}
 		fmt.Printf("Observation added to %q:\n  + %s\n", name, content)
+		printNearDuplicates(dups)
 		return nil
 	},
 }
 
+// printNearDuplicates warns about stored observations that new ones restate.
+func printNearDuplicates(dups []db.NearDuplicate) {
+	for _, d := range dups {
+		fmt.Fprintf(os.Stderr, "Possible duplicate (%.0f%% similar) of existing observation on %q:\n  = %s\n",
+			d.Similarity*100, d.Entity, d.Existing)
+	}
+	if len(dups) > 0 {
+		fmt.Fprintln(os.Stderr, "Run 'aimemo dedupe' to merge near-duplicate observations.")
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(observeCmd)
 }
@@ -0,0 +1,27 @@
+package cli
+
+// pageSize is how many rows CLI commands fetch per request when following
+// pagination cursors.
+const pageSize = 200
+
+// fetchPages calls fetch with successive cursors until limit rows are
+// collected or the results run out. limit <= 0 collects everything.
+func fetchPages[T any](limit int, fetch func(n int, cursor string) ([]T, string, error)) ([]T, error) {
+	var all []T
+	cursor := ""
+	for {
+		n := pageSize
+		if limit > 0 {
+			n = min(n, limit-len(all))
+		}
+		items, next, err := fetch(n, cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if next == "" || (limit > 0 && len(all) >= limit) {
+			return all, nil
+		}
+		cursor = next
+	}
+}
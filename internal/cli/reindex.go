@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var reindexForce bool
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Compute observation embeddings for semantic search",
+	Long: `Compute observation embeddings for semantic search.
+
+Semantic and hybrid searches embed new observations on demand; reindex does
+it ahead of time, and replaces vectors left by a previously configured
+embedder. Use --force after changing the embedding model behind the same
+command or URL.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		ctx := context.Background()
+		stats, err := database.Reindex(ctx, reindexForce, func(done, todo int) {
+			fmt.Fprintf(os.Stderr, "\rEmbedded %d/%d observations", done, todo)
+		})
+		if stats.Embedded > 0 {
+			fmt.Fprintln(os.Stderr)
+		}
+		if err != nil {
+			return fmt.Errorf("reindex: %w", err)
+		}
+
+		fmt.Printf("Embedder:  %s\n", stats.Model)
+		fmt.Printf("Embedded:  %d observations\n", stats.Embedded)
+		fmt.Printf("Indexed:   %d observations\n", stats.Total)
+		return nil
+	},
+}
+
+func init() {
+	reindexCmd.Flags().BoolVar(&reindexForce, "force", false, "Recompute every embedding, not just missing or stale ones")
+	rootCmd.AddCommand(reindexCmd)
+}
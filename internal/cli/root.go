@@ -3,9 +3,11 @@ package cli
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/MyAgentHubs/aimemo/internal/config"
 	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/MyAgentHubs/aimemo/internal/embed"
 	"github.com/MyAgentHubs/aimemo/internal/locate"
 	"github.com/spf13/cobra"
 )
@@ -42,14 +44,18 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default: ~/.aimemo/config.toml)")
 }
 
-func initConfig() {
-	path := cfgFile
-	if path == "" {
-		p, _ := locate.ConfigPath()
-		path = p
+// configPath returns the --config flag or the default config file path.
+func configPath() string {
+	if cfgFile != "" {
+		return cfgFile
 	}
+	p, _ := locate.ConfigPath()
+	return p
+}
+
+func initConfig() {
 	var err error
-	cfg, err = config.Load(path)
+	cfg, err = config.Load(configPath())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: config load error: %v\n", err)
 		cfg = config.Default()
@@ -58,11 +64,67 @@ func initConfig() {
 
 // openDB opens the database for the current context.
 func openDB() (*db.DB, string, error) {
+	return openDBWithOptions(dbOptions())
+}
+
+// dbOptions builds database options from the loaded config.
+func dbOptions() db.Options {
+	return db.Options{
+		Ranking:           rankingModel(cfg.Scoring),
+		PlainSnippets:     !cfg.Search.Highlight,
+		DisableFuzzy:      !cfg.Search.FuzzyEnabled,
+		Embeddings:        embedOptions(cfg.Embeddings),
+		PurgeTrashAfter:   time.Duration(cfg.Trash.PurgeAfterDays * float64(24*time.Hour)),
+		PruneChangesAfter: time.Duration(cfg.Log.RetainDays * float64(24*time.Hour)),
+		DailyBackups:      dailyBackups(cfg.Backup),
+		BackupDir:         cfg.Backup.Dir,
+		Source:            db.SourceCLI,
+	}
+}
+
+// dailyBackups returns how many daily snapshots the [backup] config section
+// keeps, or 0 if they are off.
+func dailyBackups(bc config.BackupConfig) int {
+	if !bc.Daily {
+		return 0
+	}
+	return max(bc.Keep, 1)
+}
+
+// embedOptions converts the [embeddings] config section to embed.Options.
+func embedOptions(ec config.EmbeddingsConfig) embed.Options {
+	return embed.Options{
+		Provider:   ec.Provider,
+		Dimensions: ec.Dimensions,
+		Command:    ec.Command,
+		URL:        ec.URL,
+		Model:      ec.Model,
+		Timeout:    time.Duration(ec.TimeoutSeconds) * time.Second,
+	}
+}
+
+// rankingModel converts the [scoring] config section to a db.RankingModel.
+func rankingModel(sc config.ScoringConfig) db.RankingModel {
+	m := db.DefaultRankingModel()
+	m.RecencyWeight = sc.RecencyWeight
+	m.AccessWeight = sc.AccessWeight
+	m.RelevanceWeight = sc.RelevanceWeight
+	if sc.Decay != "" {
+		m.Decay = sc.Decay
+	}
+	if sc.HalfLifeDays > 0 {
+		m.HalfLife = time.Duration(sc.HalfLifeDays * float64(24*time.Hour))
+	}
+	return m
+}
+
+// openDBWithOptions opens the database for the current context with explicit options.
+func openDBWithOptions(opts db.Options) (*db.DB, string, error) {
 	dbPath, err := locate.FindProjectDB(contextFlag)
 	if err != nil {
 		return nil, "", fmt.Errorf("find db: %w", err)
 	}
-	database, err := db.Open(dbPath)
+	database, err := db.OpenWithOptions(dbPath, opts)
 	if err != nil {
 		return nil, "", fmt.Errorf("open db %s: %w", dbPath, err)
 	}
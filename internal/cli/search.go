@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/MyAgentHubs/aimemo/internal/db"
@@ -16,6 +17,9 @@ var (
 	searchLimit  int
 	searchSort   string
 	outputJSON   bool
+
+	searchMatchedOnly bool
+	searchMode        string
 )
 
 var searchCmd = &cobra.Command{
@@ -35,42 +39,124 @@ var searchCmd = &cobra.Command{
 		defer database.Close()
 
 		ctx := context.Background()
-		results, err := database.Search(ctx, query, searchType, searchTags, searchSort, searchLimit)
+		results, err := fetchPages(searchLimit, func(n int, cursor string) ([]db.SearchResult, string, error) {
+			return database.SearchModePage(ctx, searchMode, query, searchType, searchTags, searchSort, n, cursor)
+		})
 		if err != nil {
 			return fmt.Errorf("search: %w", err)
 		}
+		var suggestion string
+		if len(results) == 0 && searchMode == db.ModeKeyword && query != "" && database.FuzzyEnabled() {
+			results, suggestion, err = database.FuzzySearch(ctx, query, searchType, searchTags, searchLimit)
+			if err != nil {
+				return fmt.Errorf("fuzzy search: %w", err)
+			}
+		}
 
 		var journalResults []db.JournalEntry
 		if query != "" {
-			journalResults, err = database.SearchJournal(ctx, query, searchLimit)
+			journalResults, err = fetchPages(searchLimit, func(n int, cursor string) ([]db.JournalEntry, string, error) {
+				return database.SearchJournalPage(ctx, query, n, cursor)
+			})
 			if err != nil {
 				return fmt.Errorf("journal search: %w", err)
 			}
 		}
 
+		if searchMatchedOnly && query != "" {
+			for i := range results {
+				results[i].Observations = nil
+				for _, m := range results[i].Matches {
+					results[i].Observations = append(results[i].Observations, m.Content)
+				}
+			}
+		}
+
+		fuzzy := len(results) > 0 && results[0].Fuzzy
 		if outputJSON {
-			return printJSON(map[string]any{
+			out := map[string]any{
 				"entities": results,
 				"journal":  journalResults,
-			})
+			}
+			if fuzzy {
+				out["fuzzy"] = true
+			}
+			if suggestion != "" {
+				out["did_you_mean"] = suggestion
+			}
+			return printJSON(out)
+		}
+		if suggestion != "" {
+			fmt.Printf("Did you mean: %s?\n", suggestion)
 		}
 		if len(results) == 0 && len(journalResults) == 0 {
 			fmt.Println("No results found.")
 			return nil
 		}
+		if fuzzy {
+			fmt.Println("No exact matches; showing close matches.")
+		}
 		printSearchResults(results)
 		printJournalResults(journalResults)
 		return nil
 	},
 }
 
+// printSearchResults prints each hit, showing matched observations with
+// their highlighted text.
 func printSearchResults(results []db.SearchResult) {
 	if len(results) == 0 {
 		return
 	}
 	for _, r := range results {
-		printEntity(&r.Entity)
+		highlighted := make(map[string]string, len(r.Matches))
+		for _, m := range r.Matches {
+			highlighted[m.Content] = m.Highlight
+		}
+		e := r.Entity
+		e.Observations = make([]string, len(r.Observations))
+		for i, obs := range r.Observations {
+			if h, ok := highlighted[obs]; ok {
+				obs = colorHighlights(h)
+			}
+			e.Observations[i] = obs
+		}
+		printEntity(&e)
+	}
+}
+
+// ANSI escapes used to render highlighted terms.
+const (
+	ansiHighlight = "\x1b[1;33m"
+	ansiReset     = "\x1b[0m"
+)
+
+// colorHighlights renders highlight markers as terminal color, or strips them
+// when stdout is not a terminal or NO_COLOR is set.
+func colorHighlights(s string) string {
+	spans := db.HighlightSpans(s)
+	if len(spans) == 1 {
+		return s
+	}
+	color := useColor()
+	var b strings.Builder
+	for i, span := range spans {
+		if i%2 == 1 && color {
+			b.WriteString(ansiHighlight + span + ansiReset)
+		} else {
+			b.WriteString(span)
+		}
+	}
+	return b.String()
+}
+
+// useColor reports whether stdout is a terminal that accepts color.
+func useColor() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
 	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
 }
 
 func printJournalResults(entries []db.JournalEntry) {
@@ -114,8 +200,10 @@ func (stdoutWriter) Write(p []byte) (int, error) {
 func init() {
 	searchCmd.Flags().StringVar(&searchType, "type", "", "Filter by entity type")
 	searchCmd.Flags().StringArrayVar(&searchTags, "tag", nil, "Filter by tag (AND); can be repeated")
-	searchCmd.Flags().IntVar(&searchLimit, "limit", 10, "Max results")
+	searchCmd.Flags().IntVar(&searchLimit, "limit", 10, "Max results (0 = all)")
 	searchCmd.Flags().StringVar(&searchSort, "sort", "recent", "Sort: recent|accessed|name")
 	searchCmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+	searchCmd.Flags().StringVar(&searchMode, "mode", db.ModeKeyword, "Search mode: keyword|semantic|hybrid")
+	searchCmd.Flags().BoolVar(&searchMatchedOnly, "matched-only", false, "Show only the observations that matched the query")
 	rootCmd.AddCommand(searchCmd)
 }
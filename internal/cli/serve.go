@@ -1,33 +1,111 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 
+	"github.com/MyAgentHubs/aimemo/internal/db"
 	"github.com/MyAgentHubs/aimemo/internal/mcp"
 	"github.com/spf13/cobra"
 )
 
+var (
+	serveTransport string
+	serveHost      string
+	servePort      int
+	serveConnect   bool
+)
+
 var serveCmd = &cobra.Command{
 	Use:   "serve",
-	Short: "Start the MCP server (stdio transport)",
-	Long:  `Start the aimemo MCP server. Usually auto-spawned by your AI client.`,
+	Short: "Start the MCP server (stdio or HTTP transport)",
+	Long: `Start the aimemo MCP server. Usually auto-spawned by your AI client.
+
+With --transport http the server speaks the MCP Streamable HTTP transport at
+http://<host>:<port>/mcp, so several agents and editors can share one
+long-lived server. It listens on 127.0.0.1:8080 unless [server] http_host and
+http_port or --host and --port say otherwise. Once a token exists (see
+'aimemo token'), clients must send one; beyond localhost they always must.
+
+With --connect, serve is a thin stdio proxy to 'aimemo daemon', starting the
+daemon if needed, so all clients share one server and its database handles.
+
+Examples:
+  aimemo serve
+  aimemo serve --connect
+  aimemo serve --transport http --port 9090`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		database, dbPath, err := openDB()
+		if serveConnect {
+			return proxyToDaemon()
+		}
+		transport := serveTransport
+		if transport == "" {
+			transport = cfg.Server.DefaultTransport
+		}
+		if transport != "" && transport != "stdio" && transport != "http" {
+			return fmt.Errorf("unknown transport %q: use stdio or http", transport)
+		}
+
+		opts := dbOptions()
+		opts.Source = db.SourceMCP
+		database, dbPath, err := openDBWithOptions(opts)
 		if err != nil {
 			return err
 		}
 		defer database.Close()
 
+		server := mcp.NewServer(database, dbPath)
+		server.SetDBOptions(opts)
+		defer server.Close()
+
+		if transport == "http" {
+			host, port := cfg.Server.HTTPHost, cfg.Server.HTTPPort
+			if cmd.Flags().Changed("host") {
+				host = serveHost
+			}
+			if cmd.Flags().Changed("port") {
+				port = servePort
+			}
+			addr := net.JoinHostPort(host, strconv.Itoa(port))
+			// Beyond loopback, clients must authenticate even before the
+			// first token is created.
+			ip := net.ParseIP(host)
+			httpOpts := mcp.HTTPOptions{
+				AllowedOrigins: cfg.Server.AllowedOrigins,
+				RequireToken:   host != "localhost" && (ip == nil || !ip.IsLoopback()),
+			}
+			if httpOpts.RequireToken {
+				if ok, err := database.HasTokens(context.Background()); err == nil && !ok {
+					fmt.Fprintf(os.Stderr, "Warning: listening on %s requires a bearer token; create one with 'aimemo token create'\n", host)
+				}
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			slog.Info("aimemo MCP server starting", "db", dbPath, "addr", addr)
+			fmt.Fprintf(os.Stderr, "aimemo MCP server listening on http://%s%s (db: %s)\n", addr, mcp.HTTPPath, dbPath)
+			return server.ListenAndServeHTTP(ctx, addr, httpOpts)
+		}
+
 		slog.Info("aimemo MCP server starting", "db", dbPath)
 		fmt.Fprintf(os.Stderr, "aimemo MCP server ready (db: %s)\n", dbPath)
-
-		server := mcp.NewServer(database, dbPath)
 		return server.ServeStdio()
 	},
 }
 
 func init() {
+	serveCmd.Flags().StringVar(&serveTransport, "transport", "", "Transport: stdio|http (default: [server] default_transport)")
+	serveCmd.Flags().StringVar(&serveHost, "host", "", "HTTP listen host (default: [server] http_host)")
+	serveCmd.Flags().IntVar(&servePort, "port", 0, "HTTP listen port (default: [server] http_port)")
+	serveCmd.Flags().BoolVar(&serveConnect, "connect", false, "Proxy stdio to 'aimemo daemon', starting it if needed")
+	serveCmd.Flags().StringVar(&daemonSocket, "socket", "", "Daemon socket for --connect (default: per-user socket)")
+	serveCmd.MarkFlagsMutuallyExclusive("connect", "transport")
 	rootCmd.AddCommand(serveCmd)
 }
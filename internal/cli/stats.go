@@ -28,6 +28,8 @@ var statsCmd = &cobra.Command{
 		fmt.Printf("Observations: %d\n", stats.ObservationCount)
 		fmt.Printf("Relations:    %d\n", stats.RelationCount)
 		fmt.Printf("Journal:      %d entries\n", stats.JournalCount)
+		fmt.Printf("Ranking:      %s\n", database.Ranking())
+		fmt.Printf("Embedder:     %s\n", database.Embedder().Name())
 		return nil
 	},
 }
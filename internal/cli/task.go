@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	taskPriority string
+	taskDue      string
+	taskEntity   string
+	taskNotes    string
+	taskAll      bool
+	taskStatus   string
+	taskLimit    int
+)
+
+var taskCmd = &cobra.Command{
+	Use:   "task",
+	Short: "Track tasks that carry over between sessions",
+}
+
+var taskAddCmd = &cobra.Command{
+	Use:   "add <title>",
+	Short: "Add an open task",
+	Long: `Add an open task.
+
+Example:
+  aimemo task add "Add rate limiting to /login" --priority high --entity auth-service --due 3d`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in := db.TaskInput{Title: args[0], Priority: taskPriority, Entity: taskEntity, Notes: taskNotes}
+		if taskDue != "" {
+			due, err := db.ParseDue(taskDue)
+			if err != nil {
+				return err
+			}
+			in.DueAt = &due
+		}
+
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		t, err := database.AddTask(context.Background(), in)
+		if err != nil {
+			return fmt.Errorf("add task: %w", err)
+		}
+		fmt.Printf("Added task #%d: %s\n", t.ID, t.Title)
+		return nil
+	},
+}
+
+var taskListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List incomplete tasks by priority and age",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		f := db.TaskFilter{Entity: taskEntity, Limit: taskLimit}
+		switch {
+		case taskAll:
+			f.Statuses = []string{db.TaskOpen, db.TaskInProgress, db.TaskBlocked, db.TaskDone, db.TaskCancelled}
+		case taskStatus != "":
+			f.Statuses = []string{taskStatus}
+		}
+		tasks, err := database.ListTasks(context.Background(), f)
+		if err != nil {
+			return fmt.Errorf("list tasks: %w", err)
+		}
+		if outputJSON {
+			if tasks == nil {
+				tasks = []db.Task{}
+			}
+			return printJSON(tasks)
+		}
+		if len(tasks) == 0 {
+			fmt.Println("No tasks.")
+			return nil
+		}
+		for _, t := range tasks {
+			printTask(t)
+		}
+		return nil
+	},
+}
+
+var taskDoneCmd = &cobra.Command{
+	Use:   "done <id>",
+	Short: "Mark a task as done",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid task id %q", args[0])
+		}
+
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		t, err := database.CompleteTask(context.Background(), id)
+		if err != nil {
+			return fmt.Errorf("complete task: %w", err)
+		}
+		fmt.Printf("Done: #%d %s\n", t.ID, t.Title)
+		return nil
+	},
+}
+
+func printTask(t db.Task) {
+	line := fmt.Sprintf("#%-4d [%s] %-6s %s", t.ID, t.Status, t.Priority, t.Title)
+	if t.Entity != "" {
+		line += " (" + t.Entity + ")"
+	}
+	if t.DueAt != nil {
+		line += " due " + time.UnixMilli(*t.DueAt).Format("2006-01-02")
+	}
+	fmt.Println(line)
+	if t.Notes != "" {
+		fmt.Printf("      %s\n", t.Notes)
+	}
+}
+
+func init() {
+	taskAddCmd.Flags().StringVar(&taskPriority, "priority", "medium", "Priority: high|medium|low")
+	taskAddCmd.Flags().StringVar(&taskDue, "due", "", "Due date: ISO date|3d|12h")
+	taskAddCmd.Flags().StringVar(&taskEntity, "entity", "", "Related existing entity (name or alias)")
+	taskAddCmd.Flags().StringVar(&taskNotes, "note", "", "Free-form notes")
+	taskListCmd.Flags().BoolVar(&taskAll, "all", false, "Include done and cancelled tasks")
+	taskListCmd.Flags().StringVar(&taskStatus, "status", "", "Only tasks with this status")
+	taskListCmd.Flags().StringVar(&taskEntity, "entity", "", "Only tasks linked to this entity")
+	taskListCmd.Flags().IntVar(&taskLimit, "limit", 50, "Max tasks")
+	taskListCmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+	taskCmd.AddCommand(taskAddCmd, taskListCmd, taskDoneCmd)
+	rootCmd.AddCommand(taskCmd)
+}
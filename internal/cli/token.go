@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tokenName     string
+	tokenScopes   []string
+	tokenContexts []string
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Manage bearer tokens for 'aimemo serve --transport http'",
+	Long: `Manage bearer tokens for the HTTP transport.
+
+Once any token exists, 'aimemo serve --transport http' requires clients to
+send one as "Authorization: Bearer <token>". Each token has scopes — read
+(search, context, graph), write (store, link, merge, tasks) and delete
+(forget, unlink, undo) — and may be limited to some contexts. Tokens are
+stored hashed in the database the server opens; the secret is shown once.
+
+Examples:
+  aimemo token create --name ci --scope read
+  aimemo token create --name agent --scope read,write --allow-context work
+  aimemo token list
+  aimemo token revoke ci`,
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a token and print its secret",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		secret, t, err := database.CreateToken(context.Background(), tokenName, tokenScopes, tokenContexts)
+		if err != nil {
+			return err
+		}
+		if outputJSON {
+			return printJSON(map[string]any{"token": secret, "info": t})
+		}
+		fmt.Printf("Created token %q (%s)\n", t.Name, tokenSummary(*t))
+		fmt.Printf("\n  %s\n\n", secret)
+		fmt.Println("Store it now: it cannot be shown again.")
+		return nil
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List tokens",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		tokens, err := database.ListTokens(context.Background())
+		if err != nil {
+			return fmt.Errorf("list tokens: %w", err)
+		}
+		if outputJSON {
+			if tokens == nil {
+				tokens = []db.Token{}
+			}
+			return printJSON(tokens)
+		}
+		if len(tokens) == 0 {
+			fmt.Println("No tokens.")
+			return nil
+		}
+		for _, t := range tokens {
+			used := "never used"
+			if t.LastUsedAt != nil {
+				used = "last used " + time.UnixMilli(*t.LastUsedAt).Format("2006-01-02 15:04")
+			}
+			fmt.Printf("• %s (%s) — created %s, %s\n", t.Name, tokenSummary(t), time.UnixMilli(t.CreatedAt).Format("2006-01-02"), used)
+		}
+		return nil
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>...",
+	Short: "Delete tokens; clients using them are refused immediately",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		for _, name := range args {
+			if err := database.RevokeToken(context.Background(), name); err != nil {
+				return err
+			}
+			fmt.Printf("Revoked token: %s\n", name)
+		}
+		return nil
+	},
+}
+
+// tokenSummary describes a token's scopes and contexts.
+func tokenSummary(t db.Token) string {
+	s := strings.Join(t.Scopes, ",")
+	if len(t.Contexts) > 0 {
+		s += "; contexts " + strings.Join(t.Contexts, ",")
+	}
+	return s
+}
+
+func init() {
+	tokenCreateCmd.Flags().StringVar(&tokenName, "name", "", "Token name (required)")
+	tokenCreateCmd.Flags().StringSliceVar(&tokenScopes, "scope", []string{db.ScopeRead}, "Scopes: read,write,delete")
+	tokenCreateCmd.Flags().StringSliceVar(&tokenContexts, "allow-context", nil, "Only allow these contexts ('default' for the default one)")
+	tokenCreateCmd.MarkFlagRequired("name")
+	tokenCreateCmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+	tokenListCmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+	tokenCmd.AddCommand(tokenCreateCmd, tokenListCmd, tokenRevokeCmd)
+	rootCmd.AddCommand(tokenCmd)
+}
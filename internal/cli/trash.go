@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var trashOlderThan string
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List, restore or purge soft-deleted entities",
+	Long: `List, restore or purge soft-deleted entities.
+
+'aimemo forget' moves an entity to the trash. Trashed entities keep their
+observations and relations until purged. Set [trash] purge_after_days in the
+config to purge old entries automatically.
+
+Examples:
+  aimemo trash list
+  aimemo trash restore old-feature
+  aimemo trash purge --older-than 30d`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List soft-deleted entities, most recent first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		trash, err := database.ListTrash(context.Background())
+		if err != nil {
+			return fmt.Errorf("list trash: %w", err)
+		}
+		if outputJSON {
+			if trash == nil {
+				trash = []db.TrashedEntity{}
+			}
+			return printJSON(trash)
+		}
+		if len(trash) == 0 {
+			fmt.Println("Trash is empty.")
+			return nil
+		}
+		for _, t := range trash {
+			deleted := time.UnixMilli(t.DeletedAt).Format("2006-01-02 15:04")
+			fmt.Printf("• %s (%s) — deleted %s, %d observations\n", t.Name, t.EntityType, deleted, t.Observations)
+		}
+		return nil
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <entity-name>...",
+	Short: "Restore soft-deleted entities",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		ctx := context.Background()
+		for _, name := range args {
+			e, err := database.RestoreEntity(ctx, name)
+			if err != nil {
+				return fmt.Errorf("restore: %w", err)
+			}
+			fmt.Printf("Restored entity: %s (%s)\n", e.Name, e.EntityType)
+		}
+		return nil
+	},
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge",
+	Short: "Permanently delete entities from the trash",
+	Long: `Permanently delete entities from the trash, with their observations and
+relations. Without --older-than the whole trash is emptied.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cutoff := time.Now().UnixMilli() + 1
+		if trashOlderThan != "" {
+			var err error
+			if cutoff, err = db.ParseSince(trashOlderThan); err != nil {
+				return err
+			}
+		}
+
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		n, err := database.PurgeTrash(context.Background(), cutoff)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Purged %d entities from the trash.\n", n)
+		return nil
+	},
+}
+
+func init() {
+	trashListCmd.Flags().BoolVar(&outputJSON, "json", false, "Output as JSON")
+	trashPurgeCmd.Flags().StringVar(&trashOlderThan, "older-than", "", "Only purge entities deleted before this: 30d|12h|2026-01-31")
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd, trashPurgeCmd)
+	rootCmd.AddCommand(trashCmd)
+}
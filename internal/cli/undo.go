@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var (
+	undoSteps  int
+	undoSince  string
+	undoYes    bool
+	undoDryRun bool
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Reverse recent changes to memory",
+	Long: `Reverse recent changes recorded in the change log (see 'aimemo log'),
+newest first. Permanently deleted entities come back with their observations,
+relations and aliases; overwritten types and tags are put back.
+
+The changes are listed before anything is applied. Undo refuses to reverse a
+change whose entities were modified since by a change it is not undoing.
+
+Examples:
+  aimemo undo
+  aimemo undo --steps 3
+  aimemo undo --since 10m --yes`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var since int64
+		if undoSince != "" {
+			var err error
+			if since, err = db.ParseSince(undoSince); err != nil {
+				return err
+			}
+		}
+
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		ctx := context.Background()
+		plan, err := database.PlanUndo(ctx, undoSteps, since)
+		if err != nil {
+			return fmt.Errorf("undo: %w", err)
+		}
+		if len(plan) == 0 {
+			fmt.Println("Nothing to undo.")
+			return nil
+		}
+
+		fmt.Printf("Will undo %d change(s):\n", len(plan))
+		for _, c := range plan {
+			t := time.UnixMilli(c.CreatedAt).Format("2006-01-02 15:04:05")
+			fmt.Printf("  #%d [%s] %s %s — %s\n", c.ID, t, c.Op, c.Entity, c.Summary())
+		}
+		if undoDryRun {
+			return nil
+		}
+		if !undoYes {
+			fmt.Print("Apply? [y/N]: ")
+			in := bufio.NewScanner(os.Stdin)
+			if !in.Scan() || !strings.EqualFold(strings.TrimSpace(in.Text()), "y") {
+				fmt.Println("Aborted.")
+				return nil
+			}
+		}
+
+		if err := database.Undo(ctx, plan); err != nil {
+			return err
+		}
+		fmt.Printf("Undid %d change(s).\n", len(plan))
+		return nil
+	},
+}
+
+func init() {
+	undoCmd.Flags().IntVar(&undoSteps, "steps", 0, "Number of changes to undo (default 1)")
+	undoCmd.Flags().StringVar(&undoSince, "since", "", "Undo every change in this window: 10m|2h|2026-01-31")
+	undoCmd.Flags().BoolVarP(&undoYes, "yes", "y", false, "Apply without asking")
+	undoCmd.Flags().BoolVar(&undoDryRun, "dry-run", false, "Only list the changes that would be undone")
+	undoCmd.MarkFlagsMutuallyExclusive("steps", "since")
+	rootCmd.AddCommand(undoCmd)
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var unlinkID int64
+
+var unlinkCmd = &cobra.Command{
+	Use:   "unlink [<from> [relation] <to>]",
+	Short: "Remove relations between two entities",
+	Long: `Remove relations between two entities.
+
+Examples:
+  aimemo unlink payment-service uses Redis   # remove one relation
+  aimemo unlink payment-service Redis        # remove every relation from A to B
+  aimemo unlink --id 12                      # remove by ID (shown by 'aimemo get')`,
+	Args: cobra.RangeArgs(0, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if unlinkID == 0 && len(args) < 2 {
+			return fmt.Errorf("provide <from> [relation] <to>, or --id")
+		}
+
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		ctx := context.Background()
+		if unlinkID != 0 {
+			if _, err := database.DeleteRelationByID(ctx, unlinkID); err != nil {
+				return fmt.Errorf("unlink: %w", err)
+			}
+			fmt.Printf("Removed relation #%d\n", unlinkID)
+			return nil
+		}
+
+		from, relation, to := args[0], "", args[len(args)-1]
+		if len(args) == 3 {
+			relation = args[1]
+		}
+		n, err := database.DeleteRelation(ctx, from, to, relation)
+		if err != nil {
+			return fmt.Errorf("unlink: %w", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("no matching relation from %q to %q", from, to)
+		}
+		fmt.Printf("Removed %d relation(s) from %s to %s\n", n, from, to)
+		return nil
+	},
+}
+
+var renameRelationCmd = &cobra.Command{
+	Use:   "rename-relation <old> <new>",
+	Short: "Rename a relation type across the whole graph",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openDB()
+		if err != nil {
+			return err
+		}
+		defer database.Close()
+
+		n, err := database.RenameRelationType(context.Background(), args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("rename relation: %w", err)
+		}
+		fmt.Printf("Renamed %q to %q on %d relation(s)\n", args[0], args[1], n)
+		return nil
+	},
+}
+
+func init() {
+	unlinkCmd.Flags().Int64Var(&unlinkID, "id", 0, "Relation ID to remove")
+	rootCmd.AddCommand(unlinkCmd)
+	rootCmd.AddCommand(renameRelationCmd)
+}
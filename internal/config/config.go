@@ -8,11 +8,15 @@ import (
 
 // Config holds all aimemo configuration.
 type Config struct {
-	Storage StorageConfig `toml:"storage"`
-	Search  SearchConfig  `toml:"search"`
-	Scoring ScoringConfig `toml:"scoring"`
-	Server  ServerConfig  `toml:"server"`
-	MCP     MCPConfig     `toml:"mcp"`
+	Storage    StorageConfig    `toml:"storage"`
+	Search     SearchConfig     `toml:"search"`
+	Scoring    ScoringConfig    `toml:"scoring"`
+	Embeddings EmbeddingsConfig `toml:"embeddings"`
+	Trash      TrashConfig      `toml:"trash"`
+	Log        LogConfig        `toml:"log"`
+	Backup     BackupConfig     `toml:"backup"`
+	Server     ServerConfig     `toml:"server"`
+	MCP        MCPConfig        `toml:"mcp"`
 }
 
 type StorageConfig struct {
@@ -28,14 +32,42 @@ type SearchConfig struct {
 }
 
 type ScoringConfig struct {
-	RecencyWeight float64 `toml:"recency_weight"`
-	AccessWeight  float64 `toml:"access_weight"`
+	RecencyWeight   float64 `toml:"recency_weight"`
+	AccessWeight    float64 `toml:"access_weight"`
+	RelevanceWeight float64 `toml:"relevance_weight"` // weight of BM25 text relevance for queries
+	Decay           string  `toml:"decay"`            // log | exponential | none
+	HalfLifeDays    float64 `toml:"half_life_days"`   // exponential decay half-life
+}
+
+type EmbeddingsConfig struct {
+	Provider       string   `toml:"provider"`        // hashed | command | http
+	Dimensions     int      `toml:"dimensions"`      // hashed vector length
+	Command        []string `toml:"command"`         // command provider argv
+	URL            string   `toml:"url"`             // http provider endpoint (OpenAI-compatible)
+	Model          string   `toml:"model"`           // http provider model name
+	TimeoutSeconds int      `toml:"timeout_seconds"` // per call to an external embedder
+}
+
+type TrashConfig struct {
+	PurgeAfterDays float64 `toml:"purge_after_days"` // purge soft-deleted entities on open; 0 = never
+}
+
+type LogConfig struct {
+	RetainDays float64 `toml:"retain_days"` // prune change log entries older than this on open; 0 = keep forever
+}
+
+type BackupConfig struct {
+	Daily bool   `toml:"daily"` // snapshot the database once a day while it is open
+	Keep  int    `toml:"keep"`  // daily snapshots to keep
+	Dir   string `toml:"dir"`   // backup directory; empty = backups/ next to the database
 }
 
 type ServerConfig struct {
-	DefaultTransport string `toml:"default_transport"`
-	HTTPPort         int    `toml:"http_port"`
-	HTTPHost         string `toml:"http_host"`
+	DefaultTransport string   `toml:"default_transport"` // stdio | http
+	HTTPPort         int      `toml:"http_port"`
+	HTTPHost         string   `toml:"http_host"`
+	AllowedOrigins   []string `toml:"allowed_origins"`     // browser origins allowed besides localhost
+	DaemonIdleMin    int      `toml:"daemon_idle_minutes"` // 'aimemo daemon' exits after this long without clients; 0 = never
 }
 
 type MCPConfig struct {
@@ -57,13 +89,25 @@ func Default() Config {
 			Highlight:    true,
 		},
 		Scoring: ScoringConfig{
-			RecencyWeight: 0.6,
-			AccessWeight:  0.4,
+			RecencyWeight:   0.6,
+			AccessWeight:    0.4,
+			RelevanceWeight: 3.0,
+			Decay:           "log",
+			HalfLifeDays:    7,
+		},
+		Embeddings: EmbeddingsConfig{
+			Provider:       "hashed",
+			Dimensions:     512,
+			TimeoutSeconds: 30,
+		},
+		Backup: BackupConfig{
+			Keep: 7,
 		},
 		Server: ServerConfig{
 			DefaultTransport: "stdio",
 			HTTPPort:         8080,
 			HTTPHost:         "127.0.0.1",
+			DaemonIdleMin:    10,
 		},
 		MCP: MCPConfig{
 			ServerName:    "aimemo-memory",
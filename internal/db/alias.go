@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Alias is an alternate name that resolves to an entity.
+type Alias struct {
+	Alias     string `json:"alias"`
+	EntityID  int64  `json:"entity_id"`
+	Entity    string `json:"entity"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// resolveEntity returns the ID and canonical name of the active entity
+// called name (case-insensitive), or failing that the active entity with
+// name as an alias. It returns sql.ErrNoRows if neither exists.
+func resolveEntity(ctx context.Context, q querier, name string) (int64, string, error) {
+	var id int64
+	var canonical string
+	err := q.QueryRowContext(ctx, `
+		SELECT id, name FROM (
+			SELECT id, name, 0 AS pref FROM entities
+			WHERE lower(name) = lower(?) AND deleted_at IS NULL
+			UNION ALL
+			SELECT e.id, e.name, 1 AS pref FROM entity_aliases a JOIN entities e ON e.id = a.entity_id
+			WHERE a.alias = ? AND e.deleted_at IS NULL
+		)
+		ORDER BY pref LIMIT 1
+	`, name, name).Scan(&id, &canonical)
+	return id, canonical, err
+}
+
+// EntityName returns the name of the active entity called name, directly or
+// through an alias, or "" if there is none. Unlike GetEntity it does not
+// count as an access.
+func (db *DB) EntityName(ctx context.Context, name string) (string, error) {
+	_, canonical, err := resolveEntity(ctx, db, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return canonical, err
+}
+
+// canonicalName returns the name of the active entity called name, directly
+// or through an alias, or name unchanged if there is none.
+func (db *DB) canonicalName(ctx context.Context, name string) (string, error) {
+	_, canonical, err := resolveEntity(ctx, db, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return name, nil
+	}
+	return canonical, err
+}
+
+// AddAlias makes alias an alternate name for the entity called entityName
+// (itself a name or alias). An alias cannot shadow another entity's name or
+// belong to two entities; adding an alias the entity already has is a no-op.
+func (db *DB) AddAlias(ctx context.Context, entityName, alias string) error {
+	id, err := db.activeEntityID(ctx, entityName)
+	if err != nil {
+		return err
+	}
+	m := &mutation{op: "alias.add", entityID: id, scope: scope{}.add("entity_aliases", "alias = ?", strings.TrimSpace(alias))}
+	return db.mutate(ctx, m, func(tx *sql.Tx) error {
+		return addAlias(ctx, tx, id, alias)
+	})
+}
+
+// addAlias adds alias to the entity with the given ID. An alias equal to the
+// entity's own name is ignored.
+func addAlias(ctx context.Context, q querier, entityID int64, alias string) error {
+	alias = strings.TrimSpace(alias)
+	if alias == "" {
+		return fmt.Errorf("alias cannot be empty")
+	}
+	if len(alias) > 1024 {
+		return fmt.Errorf("alias exceeds 1KB limit")
+	}
+
+	var owner int64
+	var ownerName string
+	err := q.QueryRowContext(ctx, `SELECT id, name FROM entities WHERE lower(name) = lower(?)`, alias).Scan(&owner, &ownerName)
+	switch {
+	case err == nil && owner == entityID:
+		return nil
+	case err == nil:
+		return fmt.Errorf("alias %q is already the name of entity %q", alias, ownerName)
+	case !errors.Is(err, sql.ErrNoRows):
+		return err
+	}
+
+	_, err = q.ExecContext(ctx, `
+		INSERT INTO entity_aliases (entity_id, alias) VALUES (?, ?)
+		ON CONFLICT(alias) DO NOTHING
+	`, entityID, alias)
+	if err != nil {
+		return fmt.Errorf("add alias: %w", err)
+	}
+	err = q.QueryRowContext(ctx, `
+		SELECT e.id, e.name FROM entity_aliases a JOIN entities e ON e.id = a.entity_id WHERE a.alias = ?
+	`, alias).Scan(&owner, &ownerName)
+	if err != nil {
+		return err
+	}
+	if owner != entityID {
+		return fmt.Errorf("alias %q already refers to entity %q", alias, ownerName)
+	}
+	return nil
+}
+
+// RemoveAlias deletes an alias (case-insensitive).
+func (db *DB) RemoveAlias(ctx context.Context, alias string) error {
+	m := &mutation{op: "alias.remove", scope: scope{}.add("entity_aliases", "alias = ?", alias)}
+	return db.mutate(ctx, m, func(tx *sql.Tx) error {
+		_ = tx.QueryRowContext(ctx, `SELECT entity_id FROM entity_aliases WHERE alias = ?`, alias).Scan(&m.entityID)
+		res, err := tx.ExecContext(ctx, `DELETE FROM entity_aliases WHERE alias = ?`, alias)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		if n == 0 {
+			return fmt.Errorf("alias %q not found", alias)
+		}
+		return nil
+	})
+}
+
+// ListAliases returns the aliases of the entity called entityName (a name or
+// alias), or of every active entity if entityName is empty, sorted by entity
+// then alias.
+func (db *DB) ListAliases(ctx context.Context, entityName string) ([]Alias, error) {
+	query := `
+		SELECT a.alias, e.id, e.name, a.created_at
+		FROM entity_aliases a JOIN entities e ON e.id = a.entity_id
+		WHERE e.deleted_at IS NULL`
+	var args []interface{}
+	if entityName != "" {
+		id, err := db.activeEntityID(ctx, entityName)
+		if err != nil {
+			return nil, err
+		}
+		query += " AND e.id = ?"
+		args = append(args, id)
+	}
+	query += " ORDER BY lower(e.name), lower(a.alias)"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aliases []Alias
+	for rows.Next() {
+		var a Alias
+		if err := rows.Scan(&a.Alias, &a.EntityID, &a.Entity, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
+
+// aliasesByEntityID returns the aliases of one entity, sorted.
+func (db *DB) aliasesByEntityID(ctx context.Context, entityID int64) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT alias FROM entity_aliases WHERE entity_id = ? ORDER BY lower(alias)
+	`, entityID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aliases []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, a)
+	}
+	return aliases, rows.Err()
+}
@@ -0,0 +1,236 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultBackupDir returns the backups directory next to the database at
+// dbPath, e.g. .aimemo/backups for .aimemo/memory.db.
+func DefaultBackupDir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "backups")
+}
+
+// BackupPath returns the file a backup of the database at dbPath taken at t
+// is written to in dir: memory.db becomes memory.20260217-150405.db.
+func BackupPath(dir, dbPath string, t time.Time) string {
+	return filepath.Join(dir, backupBase(dbPath)+"."+t.Format("20060102-150405")+".db")
+}
+
+func backupBase(dbPath string) string {
+	return strings.TrimSuffix(filepath.Base(dbPath), filepath.Ext(dbPath))
+}
+
+// Backup writes a consistent copy of the database to dest with VACUUM INTO,
+// which is safe while other connections are reading and writing. dest must
+// not exist; its directory is created if needed.
+func (db *DB) Backup(ctx context.Context, dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup %s: file already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o700); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, dest); err != nil {
+		return fmt.Errorf("backup %s: %w", dest, err)
+	}
+	return nil
+}
+
+// VerifyBackup checks that the file at path is an intact aimemo database
+// this binary can open, and returns its schema version.
+func VerifyBackup(ctx context.Context, path string) (int, error) {
+	src, err := openReadOnly(path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return verify(ctx, src)
+}
+
+// openReadOnly opens an existing database file without creating or
+// modifying it.
+func openReadOnly(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	sqldb, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	sqldb.SetMaxOpenConns(1)
+	return sqldb, nil
+}
+
+// verify runs SQLite's integrity and foreign key checks on q and reads its
+// schema version.
+func verify(ctx context.Context, q querier) (int, error) {
+	var result string
+	if err := q.QueryRowContext(ctx, `PRAGMA integrity_check(1)`).Scan(&result); err != nil {
+		return 0, fmt.Errorf("integrity check: %w", err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("integrity check failed: %s", result)
+	}
+	rows, err := q.QueryContext(ctx, `PRAGMA foreign_key_check`)
+	if err != nil {
+		return 0, fmt.Errorf("foreign key check: %w", err)
+	}
+	broken := rows.Next()
+	rows.Close()
+	if broken {
+		return 0, fmt.Errorf("foreign key check failed")
+	}
+
+	var version int
+	if err := q.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("not an aimemo database: %w", err)
+	}
+	if latest := LatestSchemaVersion(); version > latest {
+		return 0, fmt.Errorf("%w (backup version %d, supported %d): upgrade aimemo", ErrSchemaTooNew, version, latest)
+	}
+	return version, nil
+}
+
+// Restore replaces the database at dst with the backup at src. The backup
+// is verified first, and the current database, if any, is saved to a
+// pre-restore backup in safetyDir, whose path is returned. Servers using dst
+// should be stopped first: they keep reading the replaced file until
+// restarted.
+func Restore(ctx context.Context, src, dst, safetyDir string) (string, error) {
+	srcDB, err := openReadOnly(src)
+	if err != nil {
+		return "", err
+	}
+	defer srcDB.Close()
+	if _, err := verify(ctx, srcDB); err != nil {
+		return "", fmt.Errorf("verify %s: %w", src, err)
+	}
+
+	// Copy through VACUUM INTO rather than the file itself, so that a backup
+	// with its own -wal file is restored with everything it holds.
+	tmp := dst + ".restore"
+	os.Remove(tmp)
+	if _, err := srcDB.ExecContext(ctx, `VACUUM INTO ?`, tmp); err != nil {
+		return "", fmt.Errorf("copy %s: %w", src, err)
+	}
+	defer os.Remove(tmp)
+
+	var safety string
+	if _, err := os.Stat(dst); err == nil {
+		cur, err := OpenWithOptions(dst, Options{SkipMigrate: true})
+		if err != nil {
+			return "", err
+		}
+		safety = filepath.Join(safetyDir, backupBase(dst)+".pre-restore."+time.Now().Format("20060102-150405")+".db")
+		err = cur.Backup(ctx, safety)
+		cur.Close()
+		if err != nil {
+			return "", fmt.Errorf("safety backup: %w", err)
+		}
+	}
+
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dst + suffix); err != nil && !os.IsNotExist(err) {
+			return safety, err
+		}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return safety, fmt.Errorf("replace %s: %w", dst, err)
+	}
+	return safety, nil
+}
+
+// backupCheckInterval is how often an open database with daily backups
+// checks whether today's snapshot has been taken.
+const backupCheckInterval = time.Hour
+
+// dailyPrefix returns the file name prefix of the daily snapshots of the
+// database at path, e.g. memory.daily.1a2b3c4d. for memory.db. The key hashes
+// the absolute path, so databases sharing a backup directory never take or
+// rotate each other's snapshots.
+func dailyPrefix(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sum := sha256.Sum256([]byte(path))
+	return backupBase(path) + ".daily." + hex.EncodeToString(sum[:4]) + "."
+}
+
+// dailyBackup takes today's snapshot of the database at path into dir unless
+// one exists, then deletes all but the newest keep daily snapshots. The
+// snapshot is written to a temporary file and linked into place, so a
+// process opening the same database at the same time never sees a partial
+// file, and whichever finishes second keeps the first one's snapshot.
+func (db *DB) dailyBackup(ctx context.Context, path, dir string, keep int) error {
+	prefix := dailyPrefix(path)
+	today := filepath.Join(dir, prefix+time.Now().Format("2006-01-02")+".db")
+	if _, err := os.Stat(today); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, prefix+"*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	// VACUUM INTO accepts an existing file only if it is empty.
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, tmp.Name()); err != nil {
+		return fmt.Errorf("backup %s: %w", today, err)
+	}
+	if err := os.Link(tmp.Name(), today); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var daily []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), prefix) && strings.HasSuffix(e.Name(), ".db") {
+			daily = append(daily, e.Name())
+		}
+	}
+	sort.Strings(daily)
+	for len(daily) > keep {
+		if err := os.Remove(filepath.Join(dir, daily[0])); err != nil {
+			return err
+		}
+		daily = daily[1:]
+	}
+	return nil
+}
+
+// runDailyBackups takes daily snapshots for as long as the database is
+// open, so a long-running server gets one each day, not only the day it
+// started. Failures are logged: a missed snapshot must not stop the caller.
+func (db *DB) runDailyBackups(path, dir string, keep int) {
+	defer close(db.backupsDone)
+	ticker := time.NewTicker(backupCheckInterval)
+	defer ticker.Stop()
+	for {
+		if err := db.dailyBackup(context.Background(), path, dir, keep); err != nil {
+			slog.Warn("daily backup", "db", path, "err", err)
+		}
+		select {
+		case <-db.stopBackups:
+			return
+		case <-ticker.C:
+		}
+	}
+}
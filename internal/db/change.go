@@ -0,0 +1,492 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Change sources recorded in the change log.
+const (
+	SourceCLI = "cli"
+	SourceMCP = "mcp"
+)
+
+// Change is one entry of the append-only change log: a single mutation with
+// the rows it touched as they were before and after. Before and After map a
+// table name to its rows; a row only in After was created, one only in
+// Before was deleted. Rows the mutation left unchanged are omitted.
+type Change struct {
+	ID        int64           `json:"id"`
+	Op        string          `json:"op"`
+	EntityID  *int64          `json:"entity_id,omitempty"`
+	Entity    string          `json:"entity,omitempty"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Source    string          `json:"source,omitempty"`
+	CreatedAt int64           `json:"created_at"`
+	Undoes    *int64          `json:"undoes,omitempty"`    // for undo entries, the change reversed
+	UndoneBy  *int64          `json:"undone_by,omitempty"` // the undo entry that reversed this change
+}
+
+// ChangeFilter selects change log entries.
+type ChangeFilter struct {
+	Entity string // entity name or alias, including former and deleted names
+	Since  int64  // Unix ms; 0 means all time
+	Limit  int    // 0 means all
+
+	// Undoable keeps only changes that can still be undone: those not yet
+	// undone, excluding undo entries themselves.
+	Undoable bool
+}
+
+// loggedTable is a table whose rows the change log snapshots.
+type loggedTable struct {
+	name     string
+	columns  []string
+	singular string
+	plural   string
+	label    string // column shown when a summary names a single row
+}
+
+// loggedTables lists the tables the change log covers, parents first.
+var loggedTables = []loggedTable{
+	{"entities", []string{"id", "name", "entity_type", "tags", "created_at", "updated_at", "deleted_at", "access_count", "last_accessed"}, "entity", "entities", "name"},
+	{"entity_aliases", []string{"id", "entity_id", "alias", "created_at"}, "alias", "aliases", "alias"},
+	{"observations", []string{"id", "entity_id", "content", "created_at"}, "observation", "observations", "content"},
+	{"relations", []string{"id", "from_id", "to_id", "relation", "created_at"}, "relation", "relations", "relation"},
+	{"tasks", []string{"id", "title", "status", "priority", "notes", "due_at", "entity_id", "created_at", "updated_at", "completed_at"}, "task", "tasks", "title"},
+	{"journal", []string{"id", "content", "tags", "created_at"}, "journal entry", "journal entries", "content"},
+}
+
+// rowSet holds snapshotted rows keyed by table name.
+type rowSet map[string][]map[string]any
+
+// condition is a SQL boolean expression with its arguments.
+type condition struct {
+	sql  string
+	args []interface{}
+}
+
+// scope selects, per logged table, the rows a mutation may touch.
+type scope map[string]condition
+
+// add ORs cond into the table's selection and returns s.
+func (s scope) add(table, cond string, args ...interface{}) scope {
+	if c, ok := s[table]; ok {
+		s[table] = condition{"(" + c.sql + ") OR (" + cond + ")", append(c.args, args...)}
+	} else {
+		s[table] = condition{cond, args}
+	}
+	return s
+}
+
+// entityScope selects the entities matching cond (a condition on entities)
+// together with everything that hangs off them: aliases, observations,
+// relations in either direction and linked tasks.
+func entityScope(cond string, args ...interface{}) scope {
+	ids := "SELECT id FROM entities WHERE " + cond
+	return scope{}.
+		add("entities", "id IN ("+ids+")", args...).
+		add("entity_aliases", "entity_id IN ("+ids+")", args...).
+		add("observations", "entity_id IN ("+ids+")", args...).
+		add("relations", "from_id IN ("+ids+") OR to_id IN ("+ids+")", append(append([]interface{}{}, args...), args...)...).
+		add("tasks", "entity_id IN ("+ids+")", args...)
+}
+
+// mutation describes one logged write.
+type mutation struct {
+	op       string
+	entityID int64
+	entity   string
+	scope    scope
+	undoes   int64 // set on undo entries
+
+	tx     *sql.Tx
+	before rowSet
+}
+
+// mutate runs fn in a transaction and appends an entry to the change log in
+// the same transaction, recording the rows selected by m.scope before and
+// after fn. fn may set m.entityID and m.entity once known, and widen the
+// scope with m.track. Nothing is logged if fn changed none of the rows.
+func (db *DB) mutate(ctx context.Context, m *mutation, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := db.mutateTx(ctx, tx, m, fn); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// mutateTx is mutate within a caller's transaction, for writes that log
+// several changes atomically.
+func (db *DB) mutateTx(ctx context.Context, tx *sql.Tx, m *mutation, fn func(tx *sql.Tx) error) error {
+	var err error
+	if m.scope == nil {
+		m.scope = scope{}
+	}
+	m.tx = tx
+	if m.before, err = snapshot(ctx, tx, m.scope, nil); err != nil {
+		return fmt.Errorf("change log: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	after, err := snapshot(ctx, tx, m.scope, m.before)
+	if err != nil {
+		return fmt.Errorf("change log: %w", err)
+	}
+	if err := db.logChange(ctx, tx, m, m.before, after); err != nil {
+		return fmt.Errorf("change log: %w", err)
+	}
+	return nil
+}
+
+// track adds the rows of table matching cond to the mutation's scope,
+// recording their current state as the before image. Call it before the
+// rows change, for writes whose targets are only known mid-transaction.
+func (m *mutation) track(ctx context.Context, table, cond string, args ...interface{}) error {
+	rs, err := snapshot(ctx, m.tx, scope{table: {cond, args}}, nil)
+	if err != nil {
+		return fmt.Errorf("change log: %w", err)
+	}
+	seen := map[int64]bool{}
+	for _, r := range m.before[table] {
+		seen[r["id"].(int64)] = true
+	}
+	for _, r := range rs[table] {
+		if !seen[r["id"].(int64)] {
+			m.before[table] = append(m.before[table], r)
+		}
+	}
+	m.scope.add(table, cond, args...)
+	return nil
+}
+
+// snapshot reads the rows selected by sc. Rows present in prev are read
+// again even if they no longer match, so an update that moves a row out of
+// the selection is still seen as an update rather than a delete.
+func snapshot(ctx context.Context, q querier, sc scope, prev rowSet) (rowSet, error) {
+	rs := rowSet{}
+	for _, t := range loggedTables {
+		c, ok := sc[t.name]
+		if !ok {
+			continue
+		}
+		where := "(" + c.sql + ")"
+		if ids := rowIDs(prev[t.name]); ids != "" {
+			where += " OR id IN (" + ids + ")"
+		}
+		rows, err := q.QueryContext(ctx, `SELECT `+strings.Join(t.columns, ", ")+` FROM `+t.name+` WHERE `+where+` ORDER BY id`, c.args...)
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s: %w", t.name, err)
+		}
+		for rows.Next() {
+			vals := make([]any, len(t.columns))
+			ptrs := make([]any, len(t.columns))
+			for i := range vals {
+				ptrs[i] = &vals[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			row := make(map[string]any, len(t.columns))
+			for i, col := range t.columns {
+				row[col] = vals[i]
+			}
+			rs[t.name] = append(rs[t.name], row)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
+
+// rowIDs returns the IDs of rows as a comma-separated SQL list.
+func rowIDs(rows []map[string]any) string {
+	ids := make([]string, 0, len(rows))
+	for _, r := range rows {
+		switch id := r["id"].(type) {
+		case int64:
+			ids = append(ids, strconv.FormatInt(id, 10))
+		case json.Number: // decoded from the log
+			if _, err := id.Int64(); err == nil {
+				ids = append(ids, id.String())
+			}
+		}
+	}
+	return strings.Join(ids, ",")
+}
+
+// prune removes rows that are identical in before and after.
+func prune(before, after rowSet) {
+	for table, rows := range before {
+		unchanged := map[int64]bool{}
+		for _, a := range after[table] {
+			for _, b := range rows {
+				if a["id"] == b["id"] && reflect.DeepEqual(a, b) {
+					unchanged[a["id"].(int64)] = true
+				}
+			}
+		}
+		before[table] = dropRows(rows, unchanged)
+		after[table] = dropRows(after[table], unchanged)
+	}
+	for _, rs := range []rowSet{before, after} {
+		for table, rows := range rs {
+			if len(rows) == 0 {
+				delete(rs, table)
+			}
+		}
+	}
+}
+
+func dropRows(rows []map[string]any, ids map[int64]bool) []map[string]any {
+	kept := rows[:0]
+	for _, r := range rows {
+		if id, _ := r["id"].(int64); !ids[id] {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// logChange appends m to the change log unless before and after are equal.
+// Undo entries are always logged, since they mark a change as undone.
+func (db *DB) logChange(ctx context.Context, tx *sql.Tx, m *mutation, before, after rowSet) error {
+	prune(before, after)
+	if len(before) == 0 && len(after) == 0 && m.undoes == 0 {
+		return nil
+	}
+	if m.entity == "" && m.entityID != 0 {
+		err := tx.QueryRowContext(ctx, `SELECT name FROM entities WHERE id = ?`, m.entityID).Scan(&m.entity)
+		if errors.Is(err, sql.ErrNoRows) {
+			// Deleted by the mutation; the before image still has it.
+			for _, r := range before["entities"] {
+				if r["id"] == m.entityID {
+					m.entity, _ = r["name"].(string)
+				}
+			}
+		} else if err != nil {
+			return err
+		}
+	}
+	var entityID, undoes sql.NullInt64
+	if m.entityID != 0 {
+		entityID = sql.NullInt64{Int64: m.entityID, Valid: true}
+	}
+	if m.undoes != 0 {
+		undoes = sql.NullInt64{Int64: m.undoes, Valid: true}
+	}
+	beforeJSON, err := rowSetJSON(before)
+	if err != nil {
+		return err
+	}
+	afterJSON, err := rowSetJSON(after)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO changes (op, entity_id, entity, before, after, source, undoes) VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, m.op, entityID, m.entity, beforeJSON, afterJSON, db.source, undoes)
+	return err
+}
+
+// rowSetJSON encodes rs, or returns nil (SQL NULL) if it is empty.
+func rowSetJSON(rs rowSet) (interface{}, error) {
+	if len(rs) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(rs)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// PruneChanges permanently deletes change log entries recorded before the
+// cutoff (Unix ms) and returns how many were deleted. Pruned changes can no
+// longer be listed or undone. Pruning is not itself logged.
+func (db *DB) PruneChanges(ctx context.Context, before int64) (int64, error) {
+	res, err := db.ExecContext(ctx, `DELETE FROM changes WHERE created_at < ?`, before)
+	if err != nil {
+		return 0, fmt.Errorf("prune change log: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ListChanges returns change log entries, newest first. An entity filter
+// matches the entity's current ID and every change recorded under the
+// given name, so history survives renames, merges and deletion.
+func (db *DB) ListChanges(ctx context.Context, f ChangeFilter) ([]Change, error) {
+	query := `
+		SELECT c.id, c.op, c.entity_id, c.entity, c.before, c.after, c.source, c.created_at, c.undoes,
+			(SELECT u.id FROM changes u WHERE u.undoes = c.id)
+		FROM changes c WHERE 1=1`
+	var args []interface{}
+	if f.Entity != "" {
+		var id int64
+		if resolved, _, err := resolveEntity(ctx, db, f.Entity); err == nil {
+			id = resolved
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		query += ` AND (c.entity_id = ? OR lower(c.entity) = lower(?)
+			OR c.entity_id IN (SELECT entity_id FROM changes WHERE lower(entity) = lower(?)))`
+		args = append(args, id, f.Entity, f.Entity)
+	}
+	if f.Since > 0 {
+		query += ` AND c.created_at >= ?`
+		args = append(args, f.Since)
+	}
+	if f.Undoable {
+		query += ` AND c.undoes IS NULL AND NOT EXISTS (SELECT 1 FROM changes u WHERE u.undoes = c.id)`
+	}
+	query += ` ORDER BY c.id DESC`
+	if f.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, f.Limit)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		var entityID, undoes, undoneBy sql.NullInt64
+		var before, after sql.NullString
+		if err := rows.Scan(&c.ID, &c.Op, &entityID, &c.Entity, &before, &after, &c.Source, &c.CreatedAt, &undoes, &undoneBy); err != nil {
+			return nil, err
+		}
+		if entityID.Valid {
+			c.EntityID = &entityID.Int64
+		}
+		if undoes.Valid {
+			c.Undoes = &undoes.Int64
+		}
+		if undoneBy.Valid {
+			c.UndoneBy = &undoneBy.Int64
+		}
+		if before.Valid {
+			c.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			c.After = json.RawMessage(after.String)
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// summaryFields are the entity columns a summary reports changes to.
+var summaryFields = []string{"name", "entity_type", "tags"}
+
+// Summary describes what the change did in a few words, for example
+// `+2 observations, -1 relation` or `entity_type: "concept" -> "service"`.
+func (c Change) Summary() string {
+	before, after := decodeRowSet(c.Before), decodeRowSet(c.After)
+	var parts []string
+	for _, t := range loggedTables {
+		old := map[string]map[string]any{}
+		for _, r := range before[t.name] {
+			old[fmt.Sprint(r["id"])] = r
+		}
+		var created, updated []map[string]any
+		for _, r := range after[t.name] {
+			id := fmt.Sprint(r["id"])
+			if prev, ok := old[id]; ok {
+				diff := []string(nil)
+				if t.name == "entities" && len(after[t.name]) == 1 {
+					diff = entityDiff(prev, r)
+				}
+				if diff != nil {
+					parts = append(parts, diff...)
+				} else {
+					updated = append(updated, r)
+				}
+				delete(old, id)
+			} else {
+				created = append(created, r)
+			}
+		}
+		var deleted []map[string]any
+		for _, r := range before[t.name] {
+			if _, ok := old[fmt.Sprint(r["id"])]; ok {
+				deleted = append(deleted, r)
+			}
+		}
+		for _, g := range []struct {
+			sign string
+			rows []map[string]any
+		}{{"+", created}, {"-", deleted}, {"~", updated}} {
+			switch n := len(g.rows); {
+			case n == 1:
+				parts = append(parts, fmt.Sprintf("%s%s %s", g.sign, t.singular, quoteShort(fmt.Sprint(g.rows[0][t.label]))))
+			case n > 1:
+				parts = append(parts, fmt.Sprintf("%s%d %s", g.sign, n, t.plural))
+			}
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// entityDiff describes the changed fields between two snapshots of an
+// entity, or returns nil if only bookkeeping columns differ.
+func entityDiff(before, after map[string]any) []string {
+	var parts []string
+	for _, f := range summaryFields {
+		old, cur := fmt.Sprint(before[f]), fmt.Sprint(after[f])
+		if old == cur {
+			continue
+		}
+		if f != "tags" { // tags are already a JSON list
+			old, cur = quoteShort(old), quoteShort(cur)
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s -> %s", f, old, cur))
+	}
+	switch {
+	case before["deleted_at"] == nil && after["deleted_at"] != nil:
+		parts = append(parts, "moved to trash")
+	case before["deleted_at"] != nil && after["deleted_at"] == nil:
+		parts = append(parts, "restored")
+	}
+	return parts
+}
+
+// decodeRowSet parses a change's row JSON; IDs decode as json.Number.
+func decodeRowSet(raw json.RawMessage) rowSet {
+	rs := rowSet{}
+	if len(raw) == 0 {
+		return rs
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	_ = dec.Decode(&rs)
+	return rs
+}
+
+// quoteShort quotes s, truncated to a readable length.
+func quoteShort(s string) string {
+	if r := []rune(s); len(r) > 60 {
+		s = string(r[:57]) + "..."
+	}
+	return strconv.Quote(s)
+}
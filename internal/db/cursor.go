@@ -0,0 +1,67 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned for a cursor that is malformed or was issued
+// for a different query.
+var ErrInvalidCursor = errors.New("invalid pagination cursor: pass the next_cursor from the previous page of the same query")
+
+// pageCursor is the decoded form of an opaque pagination cursor. It records
+// the sort key of the last row returned so the next page resumes after it
+// (keyset pagination), which keeps pages stable while rows are added.
+type pageCursor struct {
+	Query uint64  `json:"q"`           // fingerprint of the query the cursor belongs to
+	Clock int64   `json:"c,omitempty"` // ranking clock (Unix ms) for scored search
+	Score float64 `json:"s,omitempty"`
+	Num   int64   `json:"n,omitempty"`
+	Str   string  `json:"t,omitempty"`
+	Since int64   `json:"w,omitempty"` // start of a relative time window, fixed at the first page
+	ID    int64   `json:"i"`
+}
+
+// page describes which slice of a result set to fetch.
+type page struct {
+	limit int
+	after *pageCursor // nil for the first page
+	query uint64
+	clock int64 // ranking clock shared by every page of a search
+	since int64 // time window start carried to the next cursor
+}
+
+// newPage decodes cursor for the query identified by parts.
+func newPage(limit int, cursor string, parts ...string) (page, error) {
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(parts, "\x00")))
+	p := page{limit: limit, query: h.Sum64(), clock: time.Now().UnixMilli()}
+	if cursor == "" {
+		return p, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return p, ErrInvalidCursor
+	}
+	var c pageCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.Query != p.query {
+		return p, ErrInvalidCursor
+	}
+	p.after = &c
+	if c.Clock != 0 {
+		p.clock = c.Clock
+	}
+	return p, nil
+}
+
+// next encodes the cursor for the page after a row with the given sort key.
+func (p page) next(c pageCursor) string {
+	c.Query = p.query
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -1,19 +1,103 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"sync"
+	"time"
 
+	"github.com/MyAgentHubs/aimemo/internal/embed"
 	_ "modernc.org/sqlite"
 )
 
 // DB wraps sql.DB with aimemo-specific helpers.
 type DB struct {
 	*sql.DB
+	ranking       RankingModel
+	plainSnippets bool
+	fuzzy         bool
+	embedder      embed.Embedder
+	source        string
+
+	closeOnce   sync.Once
+	stopBackups chan struct{} // closed by Close to end runDailyBackups
+	backupsDone chan struct{}
+}
+
+// querier is satisfied by *DB and *sql.Tx, so helpers can run on their own
+// or inside a mutation's transaction.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Options controls how OpenWithOptions prepares a database.
+type Options struct {
+	// SkipMigrate opens the database without applying pending migrations.
+	// Used by 'aimemo migrate' to inspect and move the schema explicitly.
+	SkipMigrate bool
+
+	// Ranking scores search results. The zero value uses DefaultRankingModel.
+	Ranking RankingModel
+
+	// PlainSnippets omits highlight markers from search match excerpts.
+	PlainSnippets bool
+
+	// DisableFuzzy turns off the typo-tolerant fallback used when a search
+	// finds nothing (see FuzzyEnabled).
+	DisableFuzzy bool
+
+	// Embeddings selects the embedder behind semantic and hybrid search.
+	// The zero value uses the offline hashed n-gram embedder.
+	Embeddings embed.Options
+
+	// PurgeTrashAfter permanently deletes entities that have been
+	// soft-deleted for longer than this when the database opens.
+	// Zero keeps the trash forever.
+	PurgeTrashAfter time.Duration
+
+	// PruneChangesAfter permanently deletes change log entries older than
+	// this when the database opens, so undo only reaches back this far.
+	// Zero keeps the change log forever.
+	PruneChangesAfter time.Duration
+
+	// DailyBackups keeps this many daily snapshots of the database, taking
+	// today's in the background when the database opens and checking again
+	// every hour while it stays open. Failures are logged, not returned.
+	// Zero turns daily snapshots off.
+	DailyBackups int
+
+	// BackupDir is where daily snapshots are written. Empty uses
+	// DefaultBackupDir.
+	BackupDir string
+
+	// Source is recorded in the change log as the origin of every mutation
+	// made through this handle, e.g. SourceCLI or SourceMCP.
+	Source string
 }
 
 // Open opens (or creates) the SQLite database at path, configures WAL mode and FTS5, and runs migrations.
 func Open(path string) (*DB, error) {
+	return OpenWithOptions(path, Options{})
+}
+
+// OpenWithOptions is Open with explicit options.
+func OpenWithOptions(path string, opts Options) (*DB, error) {
+	ranking := opts.Ranking
+	if ranking == (RankingModel{}) {
+		ranking = DefaultRankingModel()
+	}
+	if err := ranking.Validate(); err != nil {
+		return nil, fmt.Errorf("ranking: %w", err)
+	}
+
+	embedder, err := embed.New(opts.Embeddings)
+	if err != nil {
+		return nil, err
+	}
+
 	sqldb, err := sql.Open("sqlite", path)
 	if err != nil {
 		return nil, fmt.Errorf("open db: %w", err)
@@ -36,26 +120,56 @@ func Open(path string) (*DB, error) {
 		}
 	}
 
-	db := &DB{sqldb}
-	if err := db.migrate(); err != nil {
-		sqldb.Close()
-		return nil, fmt.Errorf("migrate: %w", err)
+	db := &DB{DB: sqldb, ranking: ranking, plainSnippets: opts.PlainSnippets, fuzzy: !opts.DisableFuzzy, embedder: embedder, source: opts.Source}
+	if !opts.SkipMigrate {
+		if err := db.Migrate(context.Background()); err != nil {
+			sqldb.Close()
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+		if opts.PurgeTrashAfter > 0 {
+			cutoff := time.Now().Add(-opts.PurgeTrashAfter).UnixMilli()
+			if _, err := db.PurgeTrash(context.Background(), cutoff); err != nil {
+				sqldb.Close()
+				return nil, err
+			}
+		}
+		if opts.PruneChangesAfter > 0 {
+			cutoff := time.Now().Add(-opts.PruneChangesAfter).UnixMilli()
+			if _, err := db.PruneChanges(context.Background(), cutoff); err != nil {
+				sqldb.Close()
+				return nil, err
+			}
+		}
+		if opts.DailyBackups > 0 {
+			dir := opts.BackupDir
+			if dir == "" {
+				dir = DefaultBackupDir(path)
+			}
+			db.stopBackups, db.backupsDone = make(chan struct{}), make(chan struct{})
+			go db.runDailyBackups(path, dir, opts.DailyBackups)
+		}
 	}
 	return db, nil
 }
 
-// migrate runs the schema creation statements and ensures FTS indexes are populated.
-func (db *DB) migrate() error {
-	if _, err := db.Exec(Schema); err != nil {
-		return err
-	}
-	// Rebuild journal_fts to index any pre-existing journal rows that were
-	// inserted before the journal_fts table existed.
-	_, err := db.Exec(`INSERT INTO journal_fts(journal_fts) VALUES('rebuild')`)
-	return err
+// Ranking returns the model used to score search results.
+func (db *DB) Ranking() RankingModel {
+	return db.ranking
 }
 
-// Close closes the database connection.
+// Embedder returns the embedder used for semantic search.
+func (db *DB) Embedder() embed.Embedder {
+	return db.embedder
+}
+
+// Close stops daily backups, waiting for one in progress, and closes the
+// database connection.
 func (db *DB) Close() error {
+	db.closeOnce.Do(func() {
+		if db.stopBackups != nil {
+			close(db.stopBackups)
+			<-db.backupsDone
+		}
+	})
 	return db.DB.Close()
 }
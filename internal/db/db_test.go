@@ -3,8 +3,12 @@ package db
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -69,6 +73,16 @@ func TestEntity_SoftDelete(t *testing.T) {
 	e, err := db.GetEntity(ctx, "Old Thing")
 	require.NoError(t, err)
 	assert.Nil(t, e) // soft-deleted, not visible
+
+	// An alias names the entity to forget.
+	_, err = db.UpsertEntity(ctx, "auth-service", "module", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddAlias(ctx, "auth-service", "AuthService"))
+	require.NoError(t, db.SoftDeleteEntity(ctx, "AuthService"))
+	e, err = db.GetEntity(ctx, "auth-service")
+	require.NoError(t, err)
+	assert.Nil(t, e)
+	assert.Error(t, db.SoftDeleteEntity(ctx, "AuthService"))
 }
 
 func TestEntity_HardDelete(t *testing.T) {
@@ -84,6 +98,14 @@ func TestEntity_HardDelete(t *testing.T) {
 	e, err := db.GetEntity(ctx, "Temp")
 	require.NoError(t, err)
 	assert.Nil(t, e)
+
+	_, err = db.UpsertEntity(ctx, "auth-service", "module", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddAlias(ctx, "auth-service", "AuthService"))
+	require.NoError(t, db.HardDeleteEntity(ctx, "AuthService"))
+	e, err = db.GetEntity(ctx, "auth-service")
+	require.NoError(t, err)
+	assert.Nil(t, e)
 }
 
 func TestObservation_AddAndList(t *testing.T) {
@@ -251,6 +273,31 @@ func TestJournal(t *testing.T) {
 	assert.Len(t, entries, 2)
 }
 
+func TestJournalDays(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.AppendJournal(ctx, "today", nil)
+	require.NoError(t, err)
+	old := time.Date(2026, 2, 17, 15, 0, 0, 0, time.Local).UnixMilli()
+	_, err = db.ExecContext(ctx, `INSERT INTO journal (content, created_at) VALUES ('older', ?), ('oldest', ?)`, old, old-1)
+	require.NoError(t, err)
+
+	days, err := db.JournalDays(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{time.Now().Format("2006-01-02"), "2026-02-17"}, days)
+	days, err = db.JournalDays(ctx, 1)
+	require.NoError(t, err)
+	assert.Len(t, days, 1)
+
+	entries, err := db.JournalDay(ctx, "2026-02-17")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "oldest", entries[0].Content)
+	_, err = db.JournalDay(ctx, "17 Feb")
+	assert.Error(t, err)
+}
+
 func TestSearchJournal_FTS5(t *testing.T) {
 	db := NewTestDB(t)
 	ctx := context.Background()
@@ -308,7 +355,1461 @@ func TestParseSince(t *testing.T) {
 	require.NoError(t, err)
 	assert.Greater(t, ms, int64(0))
 
+	ms, err = ParseSince("10m")
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().Add(-10*time.Minute).UnixMilli(), ms, 1000)
+
 	_, err = ParseSince("bogus")
 	assert.Error(t, err)
 }
 
+func TestMigrations_Contiguous(t *testing.T) {
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version, "migration %q", m.Name)
+		assert.NotEmpty(t, m.Up, "migration %d has no Up", m.Version)
+	}
+}
+
+func TestMigrate_RecordsVersion(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	v, err := db.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, LatestSchemaVersion(), v)
+
+	statuses, err := db.MigrationStatus(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, len(migrations))
+	for _, st := range statuses {
+		assert.True(t, st.Applied)
+	}
+
+	// Re-running is a no-op.
+	require.NoError(t, db.Migrate(ctx))
+}
+
+func TestMigrate_AdoptsUnversionedDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	ctx := context.Background()
+
+	// Simulate a database created before schema versioning existed.
+	legacy, err := OpenWithOptions(path, Options{SkipMigrate: true})
+	require.NoError(t, err)
+	_, err = legacy.Exec(Schema)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`INSERT INTO journal (content) VALUES ('old entry')`)
+	require.NoError(t, err)
+	require.NoError(t, legacy.Close())
+
+	db, err := Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	v, err := db.SchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, LatestSchemaVersion(), v)
+
+	entries, err := db.SearchJournal(ctx, "old", 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestMigrate_RefusesNewerSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.db")
+
+	db, err := Open(path)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO schema_version (version, name) VALUES (?, 'from the future')`, LatestSchemaVersion()+1)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	_, err = Open(path)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrSchemaTooNew)
+
+	// Still inspectable without migrating.
+	raw, err := OpenWithOptions(path, Options{SkipMigrate: true})
+	require.NoError(t, err)
+	defer raw.Close()
+	v, err := raw.SchemaVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, LatestSchemaVersion()+1, v)
+}
+
+func TestMigrate_BaselineIrreversible(t *testing.T) {
+	db := NewTestDB(t)
+	err := db.MigrateTo(context.Background(), 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not reversible")
+}
+
+func TestTasks_PriorityAndAgeOrder(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.UpsertEntity(ctx, "auth-service", "service", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddAlias(ctx, "auth-service", "auth"))
+
+	low, err := db.AddTask(ctx, TaskInput{Title: "Tidy docs", Priority: "low"})
+	require.NoError(t, err)
+	first, err := db.AddTask(ctx, TaskInput{Title: "Fix login", Priority: "high", Entity: "auth"})
+	require.NoError(t, err)
+	second, err := db.AddTask(ctx, TaskInput{Title: "Rotate keys", Priority: "high"})
+	require.NoError(t, err)
+
+	tasks, err := db.ListTasks(ctx, TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, tasks, 3)
+	assert.Equal(t, []int64{first.ID, second.ID, low.ID}, []int64{tasks[0].ID, tasks[1].ID, tasks[2].ID})
+	assert.Equal(t, "auth-service", tasks[0].Entity)
+	assert.Equal(t, TaskOpen, tasks[0].Status)
+
+	// A misspelled entity is an error, not a new stub entity.
+	_, err = db.AddTask(ctx, TaskInput{Title: "Fix logout", Entity: "auth-servce"})
+	assert.ErrorContains(t, err, "not found")
+	missing := "auth-servce"
+	_, err = db.UpdateTask(ctx, low.ID, TaskUpdate{Entity: &missing})
+	assert.ErrorContains(t, err, "not found")
+	e, err := db.GetEntity(ctx, "auth-servce")
+	require.NoError(t, err)
+	assert.Nil(t, e)
+}
+
+func TestTasks_UpdateAndComplete(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	task, err := db.AddTask(ctx, TaskInput{Title: "Ship v2"})
+	require.NoError(t, err)
+	assert.Equal(t, "medium", task.Priority)
+
+	blocked := TaskBlocked
+	notes := "waiting on review"
+	task, err = db.UpdateTask(ctx, task.ID, TaskUpdate{Status: &blocked, Notes: &notes})
+	require.NoError(t, err)
+	assert.Equal(t, TaskBlocked, task.Status)
+	assert.Equal(t, notes, task.Notes)
+
+	task, err = db.CompleteTask(ctx, task.ID)
+	require.NoError(t, err)
+	assert.Equal(t, TaskDone, task.Status)
+	assert.NotNil(t, task.CompletedAt)
+
+	open, err := db.ListTasks(ctx, TaskFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, open)
+
+	done, err := db.ListTasks(ctx, TaskFilter{Statuses: []string{TaskDone}})
+	require.NoError(t, err)
+	assert.Len(t, done, 1)
+
+	bogus := "later"
+	_, err = db.UpdateTask(ctx, task.ID, TaskUpdate{Status: &bogus})
+	assert.Error(t, err)
+	_, err = db.CompleteTask(ctx, 9999)
+	assert.Error(t, err)
+}
+
+func TestRelation_Delete(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.UpsertRelationByName(ctx, "API", "Redis", "uses"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "API", "Redis", "depends_on"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "API", "PG", "uses"))
+
+	require.NoError(t, db.AddAlias(ctx, "Redis", "cache"))
+	require.NoError(t, db.AddAlias(ctx, "API", "gateway"))
+
+	rels, err := db.ListRelationsByEntity(ctx, "cache")
+	require.NoError(t, err)
+	assert.Len(t, rels, 2, "relations are listed by alias")
+
+	n, err := db.DeleteRelation(ctx, "api", "redis", "uses")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	// Empty relation removes every type between the pair, named by alias.
+	n, err = db.DeleteRelation(ctx, "gateway", "cache", "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	rels, err = db.ListRelationsByEntity(ctx, "API")
+	require.NoError(t, err)
+	require.Len(t, rels, 1)
+
+	n, err = db.DeleteRelationByID(ctx, rels[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = db.DeleteRelationByID(ctx, rels[0].ID)
+	assert.Error(t, err)
+}
+
+func TestRelation_RenameType(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.UpsertRelationByName(ctx, "A", "B", "depends-on"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "B", "C", "depends-on"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "A", "B", "depends_on")) // collides after rename
+
+	n, err := db.RenameRelationType(ctx, "depends-on", "depends_on")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	stats, err := db.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.RelationCount)
+
+	rels, err := db.ListRelationsByEntity(ctx, "B")
+	require.NoError(t, err)
+	for _, r := range rels {
+		assert.Equal(t, "depends_on", r.Relation)
+	}
+}
+
+func TestGraph_Neighbors(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	// api -> auth -> redis -> api (cycle), api -> pg, worker -> redis
+	require.NoError(t, db.UpsertRelationByName(ctx, "api", "auth", "depends_on"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "auth", "redis", "uses"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "redis", "api", "notifies"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "api", "pg", "uses"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "worker", "redis", "uses"))
+
+	g, err := db.Neighbors(ctx, "API", GraphOptions{Depth: 1, Direction: "out"})
+	require.NoError(t, err)
+	assert.Equal(t, "api", g.Root)
+	assert.Equal(t, []string{"api", "auth", "pg"}, graphNodeNames(g.Nodes))
+	assert.Len(t, g.Edges, 2)
+
+	// Cycle does not loop; depth reported is the shortest distance.
+	g, err = db.Neighbors(ctx, "api", GraphOptions{Depth: 6, Direction: "out"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"api", "auth", "pg", "redis"}, graphNodeNames(g.Nodes))
+	assert.Equal(t, 2, g.Nodes[3].Depth)
+
+	// Incoming edges to redis, filtered by relation type.
+	g, err = db.Neighbors(ctx, "redis", GraphOptions{Depth: 1, Direction: "in", Relations: []string{"uses"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"redis", "auth", "worker"}, graphNodeNames(g.Nodes))
+
+	g, err = db.Neighbors(ctx, "api", GraphOptions{Depth: 3, MaxNodes: 2})
+	require.NoError(t, err)
+	assert.Len(t, g.Nodes, 2)
+	assert.True(t, g.Truncated)
+
+	_, err = db.Neighbors(ctx, "missing", GraphOptions{})
+	assert.Error(t, err)
+}
+
+func TestGraph_ShortestPath(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, db.UpsertRelationByName(ctx, "a", "b", "calls"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "b", "c", "calls"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "c", "d", "calls"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "a", "x", "calls"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "x", "d", "calls"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "d", "a", "calls")) // cycle
+
+	p, err := db.ShortestPath(ctx, "a", "d", GraphOptions{Direction: "out"})
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	assert.Equal(t, 2, p.Hops)
+	assert.Equal(t, []string{"a", "x", "d"}, graphNodeNames(p.Nodes))
+	require.Len(t, p.Edges, 2)
+	assert.Equal(t, "x", p.Edges[0].ToName)
+
+	// Soft-deleted entities are not traversed.
+	require.NoError(t, db.SoftDeleteEntity(ctx, "x"))
+	p, err = db.ShortestPath(ctx, "a", "d", GraphOptions{Direction: "out"})
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	assert.Equal(t, 3, p.Hops)
+
+	_, err = db.UpsertEntity(ctx, "island", "concept", nil)
+	require.NoError(t, err)
+	p, err = db.ShortestPath(ctx, "a", "island", GraphOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, p)
+
+	// In a dense graph the walk hits its row cap before ruling out a path.
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			if i != j {
+				require.NoError(t, db.UpsertRelationByName(ctx, fmt.Sprintf("k%d", i), fmt.Sprintf("k%d", j), "calls"))
+			}
+		}
+	}
+	_, err = db.ShortestPath(ctx, "k0", "island", GraphOptions{})
+	assert.ErrorIs(t, err, ErrPathSearchTruncated)
+	p, err = db.ShortestPath(ctx, "k0", "k9", GraphOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.Hops)
+}
+
+func graphNodeNames(nodes []GraphNode) []string {
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i] = n.Name
+	}
+	return names
+}
+
+func TestRanking_DecayModels(t *testing.T) {
+	ctx := context.Background()
+	seed := func(t *testing.T, m RankingModel) *DB {
+		db, err := OpenWithOptions(":memory:", Options{Ranking: m})
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+
+		oldID, err := db.UpsertEntity(ctx, "old decision", "decision", nil)
+		require.NoError(t, err)
+		require.NoError(t, db.AddObservation(ctx, oldID, "cache layer chosen"))
+		newID, err := db.UpsertEntity(ctx, "new note", "concept", nil)
+		require.NoError(t, err)
+		require.NoError(t, db.AddObservation(ctx, newID, "cache warmup idea"))
+
+		// The old decision is 60 days stale but frequently read.
+		_, err = db.Exec(`UPDATE entities SET updated_at = updated_at - 60 * 86400000, access_count = 50 WHERE id = ?`, oldID)
+		require.NoError(t, err)
+		return db
+	}
+
+	db := seed(t, RankingModel{})
+	assert.Equal(t, DefaultRankingModel(), db.Ranking())
+	results, err := db.Search(ctx, "cache", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "new note", results[0].Name, "log decay strongly favours fresh entities")
+
+	// Recency-only exponential decay with a short half-life also favours the new note.
+	db = seed(t, RankingModel{RecencyWeight: 1, AccessWeight: 0, Decay: DecayExponential, HalfLife: 24 * time.Hour})
+	results, err = db.Search(ctx, "cache", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "new note", results[0].Name)
+	assert.Greater(t, results[0].Score, results[1].Score)
+
+	db = seed(t, RankingModel{AccessWeight: 1, Decay: DecayNone})
+	results, err = db.Search(ctx, "cache", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "old decision", results[0].Name)
+	assert.Equal(t, 0.0, results[1].Score)
+}
+
+func TestRanking_RelevanceBeatsRecency(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	focusedID, err := db.UpsertEntity(ctx, "redis connection pooling", "concept", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, focusedID, "pool size is tuned per worker"))
+	passingID, err := db.UpsertEntity(ctx, "deploy checklist", "concept", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, passingID, "check redis connection pooling before rollout"))
+
+	// The focused entity was last touched a day ago; the passing mention is fresh.
+	_, err = db.Exec(`UPDATE entities SET updated_at = updated_at - 86400000 WHERE id = ?`, focusedID)
+	require.NoError(t, err)
+
+	results, err := db.Search(ctx, "redis connection pooling", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "redis connection pooling", results[0].Name)
+	assert.Greater(t, results[0].Score, results[1].Score)
+
+	// Without the relevance term, recency wins again.
+	db.ranking.RelevanceWeight = 0
+	results, err = db.Search(ctx, "redis connection pooling", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "deploy checklist", results[0].Name)
+}
+
+func TestSearch_Matches(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	id, err := db.UpsertEntity(ctx, "cache service", "system", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, id, "uses redis for sessions"))
+	require.NoError(t, db.AddObservation(ctx, id, "deployed on three nodes"))
+	require.NoError(t, db.AddObservation(ctx, id, "redis eviction policy is allkeys-lru, so the least recently used keys go first once maxmemory is reached on any of the nodes"))
+
+	results, err := db.Search(ctx, "redis", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Len(t, results[0].Observations, 3)
+	require.Len(t, results[0].Matches, 2)
+	assert.Equal(t, "uses redis for sessions", results[0].Matches[0].Content)
+	assert.Equal(t, "uses ⟦redis⟧ for sessions", results[0].Matches[0].Highlight)
+	assert.Empty(t, results[0].Matches[0].Snippet, "short observations need no excerpt")
+	assert.Contains(t, results[0].Matches[1].Snippet, "⟦redis⟧")
+	assert.Less(t, len(results[0].Matches[1].Snippet), len(results[0].Matches[1].Highlight))
+	assert.Equal(t, []string{"uses ", "redis", " for sessions"}, HighlightSpans(results[0].Matches[0].Highlight))
+
+	// Listing has no query, so nothing is marked as matched.
+	results, err = db.Search(ctx, "", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Empty(t, results[0].Matches)
+
+	plain, err := OpenWithOptions(":memory:", Options{PlainSnippets: true})
+	require.NoError(t, err)
+	defer plain.Close()
+	id, err = plain.UpsertEntity(ctx, "cache service", "system", nil)
+	require.NoError(t, err)
+	require.NoError(t, plain.AddObservation(ctx, id, "uses redis for sessions"))
+	results, err = plain.Search(ctx, "redis", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Len(t, results[0].Matches, 1)
+	assert.Equal(t, "uses redis for sessions", results[0].Matches[0].Highlight)
+}
+
+func TestFuzzySearch(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	k8s, err := db.UpsertEntity(ctx, "cluster", "system", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, k8s, "runs on Kubernetes 1.29"))
+	require.NoError(t, db.AddObservation(ctx, k8s, "state kept in PostgreSQL"))
+	_, err = db.UpsertEntity(ctx, "auth-service", "service", nil)
+	require.NoError(t, err)
+
+	results, err := db.Search(ctx, "kubrenetes", "", nil, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	results, suggestion, err := db.FuzzySearch(ctx, "kubrenetes", "", nil, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "cluster", results[0].Name)
+	assert.True(t, results[0].Fuzzy)
+	assert.Equal(t, "kubernetes", suggestion)
+	require.Len(t, results[0].Matches, 1)
+	assert.Contains(t, results[0].Matches[0].Highlight, "⟦Kubernetes⟧")
+
+	// Partial camelCase identifier: "auth" is a word, "svc" abbreviates "service".
+	results, _, err = db.FuzzySearch(ctx, "authSvc", "", nil, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "auth-service", results[0].Name)
+
+	// Substring inside a word falls through to the trigram index.
+	results, suggestion, err = db.FuzzySearch(ctx, "gresql", "", nil, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "cluster", results[0].Name)
+	assert.Empty(t, suggestion)
+	require.Len(t, results[0].Matches, 1)
+	assert.Contains(t, results[0].Matches[0].Highlight, "Post⟦greSQL⟧")
+
+	results, _, err = db.FuzzySearch(ctx, "zzzzzz", "", nil, 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	// Only terms termCost could match are read from the vocabulary.
+	vocab, err := db.vocabulary(ctx, "svc")
+	require.NoError(t, err)
+	assert.Contains(t, vocab, "servic")
+	assert.NotContains(t, vocab, "kubernet")
+	assert.NotContains(t, vocab, "cluster")
+}
+
+func TestFuzzyTokens(t *testing.T) {
+	assert.Equal(t, []string{"auth", "svc"}, fuzzyTokens("authSvc"))
+	assert.Equal(t, []string{"redis", "pool", "v2"}, fuzzyTokens("redis-pool v2"))
+	assert.Equal(t, 1, levenshtein([]rune("kubernets"), []rune("kubernet")))
+}
+
+func TestParseQuery(t *testing.T) {
+	cases := []struct {
+		query   string
+		match   string
+		filters []QueryFilter
+	}{
+		{`redis`, `"redis"`, nil},
+		{`redis cache`, `("redis" AND "cache")`, nil},
+		{`redis OR memcached`, `("redis" OR "memcached")`, nil},
+		{`"jwt" NOT refresh`, `("jwt" NOT "refresh")`, nil},
+		{`jwt AND NOT refresh`, `("jwt" NOT "refresh")`, nil},
+		{`auth*`, `"auth"*`, nil},
+		{`"connection pool"*`, `"connection pool"*`, nil},
+		{`NEAR(redis "connection pool", 5)`, `NEAR("redis" "connection pool", 5)`, nil},
+		{`(redis OR memcached) ttl`, `(("redis" OR "memcached") AND "ttl")`, nil},
+		{`auth-service`, `"auth-service"`, nil},
+		{"a\u00a0b", `("a" AND "b")`, nil},
+		{"a\vb", `("a" AND "b")`, nil},
+		{"redis\u3000cache", `("redis" AND "cache")`, nil},
+		{`type:decision jwt`, `"jwt"`, []QueryFilter{{Field: FieldType, Value: "decision"}}},
+		{`jwt NOT tag:legacy`, `"jwt"`, []QueryFilter{{Field: FieldTag, Value: "legacy", Negate: true}}},
+		{`name:"auth service" type:serv*`, ``, []QueryFilter{
+			{Field: FieldName, Value: "auth service"},
+			{Field: FieldType, Value: "serv", Prefix: true},
+		}},
+		{`http://example.com`, `"http://example.com"`, nil},
+	}
+	for _, c := range cases {
+		pq, err := ParseQuery(c.query)
+		require.NoError(t, err, c.query)
+		assert.Equal(t, c.match, pq.Match, c.query)
+		assert.Equal(t, c.filters, pq.Filters, c.query)
+	}
+
+	for _, bad := range []string{
+		`redis OR`, `AND redis`, `NOT refresh`, `(redis`, `redis)`, `()`, `"unterminated`,
+		`*`, `say "hi""`, `NEAR(redis)`, `NEAR(redis pool, x)`, `type:`, `redis OR type:decision`, `a OR NOT b`,
+	} {
+		_, err := ParseQuery(bad)
+		var syntaxErr *QuerySyntaxError
+		assert.ErrorAs(t, err, &syntaxErr, bad)
+	}
+}
+
+func TestSearch_QuerySyntax(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	redis, err := db.UpsertEntity(ctx, "redis", "system", []string{"cache"})
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, redis, "session store with jwt refresh tokens"))
+	memcached, err := db.UpsertEntity(ctx, "memcached", "system", []string{"cache", "legacy"})
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, memcached, "jwt blacklist"))
+	auth, err := db.UpsertEntity(ctx, "auth-service", "service", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, auth, "authentication via jwt"))
+
+	names := func(query string) []string {
+		t.Helper()
+		results, err := db.Search(ctx, query, "", nil, "name", 10)
+		require.NoError(t, err, query)
+		var out []string
+		for _, r := range results {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	assert.ElementsMatch(t, []string{"redis", "memcached"}, names("redis OR memcached"))
+	assert.ElementsMatch(t, []string{"memcached", "auth-service"}, names(`"jwt" NOT refresh`))
+	assert.ElementsMatch(t, []string{"auth-service"}, names("authent*"))
+	assert.ElementsMatch(t, []string{"redis"}, names("NEAR(jwt tokens, 2)"))
+	assert.ElementsMatch(t, []string{"redis", "memcached"}, names("jwt type:system"))
+	assert.ElementsMatch(t, []string{"redis"}, names("jwt tag:cache NOT tag:legacy"))
+	assert.ElementsMatch(t, []string{"auth-service"}, names("name:auth*"))
+	assert.Equal(t, []string{"memcached", "redis"}, names("tag:cache"), "filter-only query lists in sort order")
+
+	_, err = db.Search(ctx, "redis OR", "", nil, "", 10)
+	var syntaxErr *QuerySyntaxError
+	assert.ErrorAs(t, err, &syntaxErr)
+
+	_, err = db.AppendJournal(ctx, "rotated jwt signing key", []string{"security"})
+	require.NoError(t, err)
+	entries, err := db.SearchJournal(ctx, "jwt tag:security", 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	entries, err = db.SearchJournal(ctx, "jwt type:system", 10)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestSearchPage_Cursor(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	for i := 0; i < 7; i++ {
+		id, err := db.UpsertEntity(ctx, fmt.Sprintf("svc-%d", i), "service", nil)
+		require.NoError(t, err)
+		require.NoError(t, db.AddObservation(ctx, id, "uses redis"))
+		_, err = db.AppendJournal(ctx, fmt.Sprintf("redis note %d", i), nil)
+		require.NoError(t, err)
+	}
+
+	for _, tc := range []struct{ query, sort string }{
+		{"", "name"}, {"", "recent"}, {"", "accessed"}, {"redis", ""},
+	} {
+		var names []string
+		cursor := ""
+		pages := 0
+		for {
+			results, next, err := db.SearchPage(ctx, tc.query, "", nil, tc.sort, 3, cursor)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(results), 3)
+			for _, r := range results {
+				names = append(names, r.Name)
+				assert.NotEmpty(t, r.Observations)
+			}
+			pages++
+			if next == "" {
+				break
+			}
+			cursor = next
+		}
+		assert.Equal(t, 3, pages, "%q sort %q", tc.query, tc.sort)
+		assert.Len(t, names, 7, "%q sort %q", tc.query, tc.sort)
+		assert.ElementsMatch(t, []string{"svc-0", "svc-1", "svc-2", "svc-3", "svc-4", "svc-5", "svc-6"}, names)
+	}
+
+	first, next, err := db.SearchPage(ctx, "", "", nil, "name", 3, "")
+	require.NoError(t, err)
+	assert.Equal(t, "svc-0", first[0].Name)
+	_, err = db.UpsertEntity(ctx, "aaa", "service", nil)
+	require.NoError(t, err)
+	second, _, err := db.SearchPage(ctx, "", "", nil, "name", 3, next)
+	require.NoError(t, err)
+	assert.Equal(t, "svc-3", second[0].Name, "pages stay stable when rows are added")
+
+	_, _, err = db.SearchPage(ctx, "redis", "", nil, "", 3, next)
+	assert.ErrorIs(t, err, ErrInvalidCursor, "cursor from another query")
+	_, _, err = db.SearchPage(ctx, "", "", nil, "name", 3, "garbage!")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	for _, search := range []bool{false, true} {
+		var contents []string
+		cursor := ""
+		for {
+			var entries []JournalEntry
+			if search {
+				entries, cursor, err = db.SearchJournalPage(ctx, "redis", 4, cursor)
+			} else {
+				entries, cursor, err = db.ListJournalPage(ctx, "24h", 4, cursor)
+			}
+			require.NoError(t, err)
+			for _, e := range entries {
+				contents = append(contents, e.Content)
+			}
+			if cursor == "" {
+				break
+			}
+		}
+		assert.Len(t, contents, 7)
+		assert.Equal(t, "redis note 6", contents[0], "newest first")
+	}
+}
+
+func TestSearchModePage_Semantic(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	auth, err := db.UpsertEntity(ctx, "auth-service", "service", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, auth, "access tokens expire after an hour"))
+	cache, err := db.UpsertEntity(ctx, "cache", "system", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, cache, "redis cluster with three nodes"))
+	billing, err := db.UpsertEntity(ctx, "billing", "service", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, billing, "invoices are sent monthly"))
+
+	keyword, _, err := db.SearchModePage(ctx, ModeKeyword, "token expiry", "", nil, "", 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, keyword)
+
+	semantic, _, err := db.SearchModePage(ctx, ModeSemantic, "token expiry", "", nil, "", 10, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, semantic)
+	assert.Equal(t, "auth-service", semantic[0].Name)
+	require.Len(t, semantic[0].Matches, 1)
+	assert.Equal(t, "access tokens expire after an hour", semantic[0].Matches[0].Content)
+
+	filtered, _, err := db.SearchModePage(ctx, ModeSemantic, "token expiry type:system", "", nil, "", 10, "")
+	require.NoError(t, err)
+	for _, r := range filtered {
+		assert.Equal(t, "system", r.EntityType)
+	}
+
+	hybrid, _, err := db.SearchModePage(ctx, ModeHybrid, "redis OR invoices", "", nil, "", 10, "")
+	require.NoError(t, err)
+	var names []string
+	for _, r := range hybrid {
+		names = append(names, r.Name)
+	}
+	assert.Subset(t, names, []string{"cache", "billing"})
+	for _, r := range hybrid {
+		if r.Name == "cache" {
+			assert.Equal(t, "⟦redis⟧ cluster with three nodes", r.Matches[0].Highlight, "keyword hits keep highlights")
+		}
+	}
+
+	first, next, err := db.SearchModePage(ctx, ModeHybrid, "redis OR invoices", "", nil, "", 1, "")
+	require.NoError(t, err)
+	require.Len(t, first, 1)
+	require.NotEmpty(t, next)
+	second, _, err := db.SearchModePage(ctx, ModeHybrid, "redis OR invoices", "", nil, "", 1, next)
+	require.NoError(t, err)
+	require.Len(t, second, 1)
+	assert.NotEqual(t, first[0].Name, second[0].Name)
+
+	_, _, err = db.SearchModePage(ctx, "vector", "redis", "", nil, "", 10, "")
+	assert.Error(t, err)
+
+	// Searching ranks only existing vectors and never embeds.
+	_, err = db.ExecContext(ctx, `DELETE FROM observation_embeddings`)
+	require.NoError(t, err)
+	semantic, _, err = db.SearchModePage(ctx, ModeSemantic, "token expiry", "", nil, "", 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, semantic)
+	var vectors int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM observation_embeddings`).Scan(&vectors))
+	assert.Zero(t, vectors)
+
+	stats, err := db.Reindex(ctx, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Embedded)
+	semantic, _, err = db.SearchModePage(ctx, ModeSemantic, "token expiry", "", nil, "", 10, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, semantic)
+	assert.Equal(t, "auth-service", semantic[0].Name)
+}
+
+func TestReindex(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	id, err := db.UpsertEntity(ctx, "cache", "system", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, id, "redis cluster"))
+	require.NoError(t, db.AddObservation(ctx, id, "three nodes"))
+
+	stats, err := db.Reindex(ctx, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ReindexStats{Model: db.Embedder().Name(), Embedded: 0, Total: 2}, stats, "observations are embedded when added")
+
+	_, err = db.ExecContext(ctx, `DELETE FROM observation_embeddings`)
+	require.NoError(t, err)
+	stats, err = db.Reindex(ctx, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, ReindexStats{Model: db.Embedder().Name(), Embedded: 2, Total: 2}, stats)
+
+	stats, err = db.Reindex(ctx, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Embedded, "up-to-date vectors are kept")
+
+	_, err = db.ExecContext(ctx, `UPDATE observation_embeddings SET model = 'old-model' WHERE observation_id = (SELECT MIN(id) FROM observations)`)
+	require.NoError(t, err)
+	var calls []int
+	stats, err = db.Reindex(ctx, false, func(done, todo int) { calls = append(calls, done, todo) })
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Embedded, "stale vectors are replaced")
+	assert.Equal(t, []int{1, 1}, calls)
+
+	stats, err = db.Reindex(ctx, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Embedded)
+	assert.Equal(t, 2, stats.Total)
+}
+
+func TestRanking_Validate(t *testing.T) {
+	assert.NoError(t, DefaultRankingModel().Validate())
+	assert.Error(t, RankingModel{Decay: "linear"}.Validate())
+	assert.Error(t, RankingModel{Decay: DecayExponential}.Validate())
+	assert.Error(t, RankingModel{RecencyWeight: -1, Decay: DecayLog}.Validate())
+
+	_, err := OpenWithOptions(":memory:", Options{Ranking: RankingModel{Decay: "linear"}})
+	assert.Error(t, err)
+}
+
+func TestNearDuplicates(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	id, err := db.UpsertEntity(ctx, "auth", "service", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, id, "Access tokens expire after 1 hour"))
+
+	dups, err := db.AddObservationWithDuplicates(ctx, id, "access token expires after 1 hour.")
+	require.NoError(t, err)
+	require.Len(t, dups, 1)
+	assert.Equal(t, "auth", dups[0].Entity)
+	assert.Equal(t, "Access tokens expire after 1 hour", dups[0].Existing)
+	assert.InDelta(t, 1.0, dups[0].Similarity, 1e-9)
+
+	dups, err = db.AddObservationWithDuplicates(ctx, id, "sessions are stored in redis")
+	require.NoError(t, err)
+	assert.Empty(t, dups)
+
+	_, dups, err = db.StoreEntitiesWithDuplicates(ctx, []EntityInput{{
+		Name: "auth", EntityType: "service",
+		Observations: []string{"access tokens expire after 1 hour!", "refresh tokens last 30 days"},
+	}})
+	require.NoError(t, err)
+	require.Len(t, dups, 2, "both stored restatements are reported")
+	for _, d := range dups {
+		assert.Equal(t, "access tokens expire after 1 hour!", d.Observation)
+	}
+
+	obs, err := db.ListObservationsByEntityID(ctx, id)
+	require.NoError(t, err)
+	assert.Len(t, obs, 5, "duplicates are reported, not rejected")
+}
+
+func TestFindDuplicateClusters(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	auth, err := db.UpsertEntity(ctx, "auth", "service", nil)
+	require.NoError(t, err)
+	for _, o := range []string{
+		"uses JWT for sessions",
+		"redis caches the session store",
+		"Uses JWT for sessions.",
+		"uses jwt for session",
+	} {
+		require.NoError(t, db.AddObservation(ctx, auth, o))
+	}
+	cache, err := db.UpsertEntity(ctx, "cache", "system", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, cache, "uses JWT for sessions"))
+
+	clusters, err := db.FindDuplicateClusters(ctx, "", 0)
+	require.NoError(t, err)
+	require.Len(t, clusters, 1, "duplicates are only grouped within an entity")
+	c := clusters[0]
+	assert.Equal(t, "auth", c.Entity)
+	require.Len(t, c.Observations, 3)
+	assert.Equal(t, "uses JWT for sessions", c.Observations[0].Content)
+
+	keep, err := c.Canonical(KeepLongest)
+	require.NoError(t, err)
+	assert.Equal(t, "Uses JWT for sessions.", c.Observations[keep].Content)
+	keep, err = c.Canonical(KeepOldest)
+	require.NoError(t, err)
+	assert.Equal(t, 0, keep)
+	keep, err = c.Canonical(KeepNewest)
+	require.NoError(t, err)
+	assert.Equal(t, 2, keep)
+	_, err = c.Canonical("shortest")
+	assert.Error(t, err)
+
+	clusters, err = db.FindDuplicateClusters(ctx, "cache", 0)
+	require.NoError(t, err)
+	assert.Empty(t, clusters)
+
+	deleted, err := db.MergeDuplicates(ctx, c, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	obs, err := db.ListObservationsByEntityID(ctx, auth)
+	require.NoError(t, err)
+	assert.Len(t, obs, 2)
+
+	clusters, err = db.FindDuplicateClusters(ctx, "auth", 0)
+	require.NoError(t, err)
+	assert.Empty(t, clusters)
+
+	// The first and last observations are only linked through the middle one.
+	deploys, err := db.UpsertEntity(ctx, "deploys", "process", nil)
+	require.NoError(t, err)
+	for _, o := range []string{
+		"deploys run on fridays after the release review",
+		"deploys run on fridays after the release review with the oncall team",
+		"deploys run after the release review with the oncall team and product",
+	} {
+		require.NoError(t, db.AddObservation(ctx, deploys, o))
+	}
+	clusters, err = db.FindDuplicateClusters(ctx, "deploys", 0)
+	require.NoError(t, err)
+	require.Len(t, clusters, 1)
+	require.Len(t, clusters[0].Observations, 3)
+	assert.False(t, clusters[0].Direct(0, 0), "chained members are not all similar to the first")
+	assert.True(t, clusters[0].Direct(1, 0))
+	assert.False(t, clusters[0].Direct(1, 0.7))
+}
+
+func TestAliases(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	id, err := db.UpsertEntity(ctx, "auth-service", "module", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, id, "uses JWT"))
+	other, err := db.UpsertEntity(ctx, "billing", "module", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, db.AddAlias(ctx, "auth-service", "AuthService"))
+	require.NoError(t, db.AddAlias(ctx, "authservice", "authentication service"), "aliases resolve when adding aliases")
+	require.NoError(t, db.AddAlias(ctx, "auth-service", "AUTHSERVICE"), "re-adding an alias is a no-op")
+	require.NoError(t, db.AddAlias(ctx, "auth-service", "Auth-Service"), "the entity's own name is ignored")
+	assert.Error(t, db.AddAlias(ctx, "auth-service", "billing"), "aliases cannot shadow another entity")
+	assert.Error(t, db.AddAlias(ctx, "billing", "authservice"), "aliases belong to one entity")
+	assert.Error(t, db.AddAlias(ctx, "missing", "x"))
+
+	e, err := db.GetEntity(ctx, "Authentication Service")
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, id, e.ID)
+	assert.Equal(t, []string{"authentication service", "AuthService"}, e.Aliases)
+	assert.Equal(t, []string{"uses JWT"}, e.Observations)
+
+	aliases, err := db.ListAliases(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, aliases, 2)
+	assert.Equal(t, "auth-service", aliases[0].Entity)
+
+	// Links through an alias reach the entity instead of creating a stub.
+	require.NoError(t, db.UpsertRelationByName(ctx, "billing", "AuthService", "calls"))
+	rels, err := db.ListRelationsByEntity(ctx, "auth-service")
+	require.NoError(t, err)
+	require.Len(t, rels, 1)
+	assert.Equal(t, other, rels[0].FromID)
+	assert.Equal(t, id, rels[0].ToID)
+
+	// Storing under an alias updates the entity the alias refers to.
+	stored, err := db.StoreEntities(ctx, []EntityInput{{
+		Name: "AuthService", EntityType: "module", Observations: []string{"rotates keys daily"}, Aliases: []string{"auth"},
+	}})
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+	assert.Equal(t, "auth-service", stored[0].Name)
+	assert.Contains(t, stored[0].Aliases, "auth")
+
+	remaining, err := db.RetractObservation(ctx, "auth", "uses JWT")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rotates keys daily"}, remaining)
+
+	results, err := db.Search(ctx, "authentication", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1, "aliases are matched by search")
+	assert.Equal(t, "auth-service", results[0].Name)
+	results, err = db.Search(ctx, "name:authservice", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	require.NoError(t, db.RemoveAlias(ctx, "authservice"))
+	assert.Error(t, db.RemoveAlias(ctx, "authservice"))
+	e, err = db.GetEntity(ctx, "AuthService")
+	require.NoError(t, err)
+	assert.Nil(t, e)
+
+	require.NoError(t, db.HardDeleteEntity(ctx, "auth-service"))
+	aliases, err = db.ListAliases(ctx, "")
+	require.NoError(t, err)
+	assert.Empty(t, aliases, "aliases are deleted with their entity")
+}
+
+func TestRenameEntity(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	id, err := db.UpsertEntity(ctx, "AuthService", "module", []string{"core"})
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, id, "uses JWT"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "api", "AuthService", "calls"))
+	_, err = db.UpsertEntity(ctx, "billing", "module", nil)
+	require.NoError(t, err)
+
+	e, err := db.RenameEntity(ctx, "authservice", "auth-service")
+	require.NoError(t, err)
+	assert.Equal(t, id, e.ID)
+	assert.Equal(t, "auth-service", e.Name)
+
+	got, err := db.GetEntity(ctx, "AuthService")
+	require.NoError(t, err)
+	require.NotNil(t, got, "the old name is left as an alias")
+	assert.Equal(t, "auth-service", got.Name)
+	assert.Equal(t, []string{"AuthService"}, got.Aliases)
+	assert.Equal(t, []string{"uses JWT"}, got.Observations)
+	rels, err := db.ListRelationsByEntity(ctx, "auth-service")
+	require.NoError(t, err)
+	assert.Len(t, rels, 1)
+
+	results, err := db.Search(ctx, "name:auth-service", "", nil, "", 10)
+	require.NoError(t, err)
+	assert.Len(t, results, 1, "the search index follows the rename")
+
+	// Renaming back to an alias swaps it with the current name.
+	e, err = db.RenameEntity(ctx, "auth-service", "AuthService")
+	require.NoError(t, err)
+	assert.Equal(t, "AuthService", e.Name)
+	aliases, err := db.ListAliases(ctx, "AuthService")
+	require.NoError(t, err)
+	require.Len(t, aliases, 1)
+	assert.Equal(t, "auth-service", aliases[0].Alias)
+
+	_, err = db.RenameEntity(ctx, "AuthService", "Billing")
+	assert.Error(t, err, "renaming onto another entity needs a merge")
+	_, err = db.RenameEntity(ctx, "missing", "x")
+	assert.Error(t, err)
+}
+
+func TestMergeEntities(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	dst, err := db.UpsertEntity(ctx, "auth-service", "module", []string{"core"})
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, dst, "uses JWT"))
+	src, err := db.UpsertEntity(ctx, "AuthService", "concept", []string{"security", "core"})
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, src, "uses JWT"))
+	require.NoError(t, db.AddObservation(ctx, src, "rotates keys daily"))
+	require.NoError(t, db.AddAlias(ctx, "AuthService", "authn"))
+	_, err = db.ExecContext(ctx, `UPDATE entities SET access_count = ? WHERE id = ?`, 3, dst)
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `UPDATE entities SET access_count = ? WHERE id = ?`, 4, src)
+	require.NoError(t, err)
+
+	require.NoError(t, db.UpsertRelationByName(ctx, "api", "auth-service", "calls"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "api", "AuthService", "calls"))       // duplicate after merge
+	require.NoError(t, db.UpsertRelationByName(ctx, "AuthService", "auth-service", "is")) // self-loop after merge
+	require.NoError(t, db.UpsertRelationByName(ctx, "AuthService", "Redis", "uses"))
+	task, err := db.AddTask(ctx, TaskInput{Title: "review", Entity: "AuthService"})
+	require.NoError(t, err)
+
+	r, err := db.MergeEntities(ctx, "authservice", "auth-service")
+	require.NoError(t, err)
+	assert.Equal(t, MergeResult{
+		Entity: "auth-service", Merged: "AuthService",
+		Observations: 1, DroppedObservations: 1,
+		Relations: 1, DroppedRelations: 2,
+		Aliases: 1,
+	}, r)
+
+	var accessCount int64
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT access_count FROM entities WHERE id = ?`, dst).Scan(&accessCount))
+	assert.Equal(t, int64(7), accessCount)
+
+	e, err := db.GetEntity(ctx, "AuthService")
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, dst, e.ID)
+	assert.Equal(t, "module", e.EntityType)
+	assert.Equal(t, []string{"core", "security"}, e.Tags)
+	assert.ElementsMatch(t, []string{"uses JWT", "rotates keys daily"}, e.Observations)
+	assert.ElementsMatch(t, []string{"AuthService", "authn"}, e.Aliases)
+
+	rels, err := db.ListRelationsByEntity(ctx, "auth-service")
+	require.NoError(t, err)
+	require.Len(t, rels, 2)
+	for _, rel := range rels {
+		assert.NotEqual(t, rel.FromID, rel.ToID)
+	}
+
+	tasks, err := db.ListTasks(ctx, TaskFilter{Entity: "auth-service"})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	assert.Equal(t, task.ID, tasks[0].ID)
+
+	var n int
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT COUNT(*) FROM entities WHERE id = ?`, src).Scan(&n))
+	assert.Zero(t, n)
+
+	_, err = db.MergeEntities(ctx, "authn", "auth-service")
+	assert.Error(t, err, "an alias of the target is the same entity")
+}
+
+func TestTrash(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	id, err := db.UpsertEntity(ctx, "Redis", "system", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, id, "port 6379"))
+	require.NoError(t, db.UpsertRelationByName(ctx, "api", "Redis", "uses"))
+	require.NoError(t, db.SoftDeleteEntity(ctx, "redis"))
+
+	trash, err := db.ListTrash(ctx)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, "Redis", trash[0].Name)
+	assert.Equal(t, 1, trash[0].Observations)
+	results, err := db.Search(ctx, "redis", "", nil, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	e, err := db.RestoreEntity(ctx, "redis")
+	require.NoError(t, err)
+	assert.Equal(t, id, e.ID)
+	assert.Nil(t, e.DeletedAt)
+	results, err = db.Search(ctx, "name:redis", "", nil, "", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1, "restore re-indexes the entity")
+	rels, err := db.ListRelationsByEntity(ctx, "Redis")
+	require.NoError(t, err)
+	assert.Len(t, rels, 1)
+	_, err = db.RestoreEntity(ctx, "Redis")
+	assert.Error(t, err, "only trashed entities can be restored")
+
+	require.NoError(t, db.SoftDeleteEntity(ctx, "Redis"))
+	_, err = db.UpsertEntity(ctx, "redis", "system", nil)
+	require.NoError(t, err)
+	_, err = db.RestoreEntity(ctx, "Redis")
+	assert.Error(t, err, "restoring must not shadow an active entity")
+
+	n, err := db.PurgeTrash(ctx, time.Now().Add(-time.Hour).UnixMilli())
+	require.NoError(t, err)
+	assert.Zero(t, n, "recent deletions are kept")
+	n, err = db.PurgeTrash(ctx, time.Now().UnixMilli()+1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	trash, err = db.ListTrash(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+
+	// Purged rows were not indexed, so the indexes stay consistent.
+	for _, fts := range []string{"entities_fts", "entities_trigram"} {
+		_, err = db.ExecContext(ctx, `INSERT INTO `+fts+`(`+fts+`, rank) VALUES('integrity-check', 1)`)
+		assert.NoError(t, err, fts)
+	}
+}
+
+func TestOpen_PurgesTrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	db, err := Open(path)
+	require.NoError(t, err)
+	ctx := context.Background()
+	_, err = db.UpsertEntity(ctx, "old", "concept", nil)
+	require.NoError(t, err)
+	_, err = db.UpsertEntity(ctx, "recent", "concept", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.SoftDeleteEntity(ctx, "recent"))
+	_, err = db.ExecContext(ctx, `UPDATE entities SET deleted_at = ? WHERE name = 'old'`,
+		time.Now().Add(-40*24*time.Hour).UnixMilli())
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	db, err = OpenWithOptions(path, Options{PurgeTrashAfter: 30 * 24 * time.Hour})
+	require.NoError(t, err)
+	defer db.Close()
+	trash, err := db.ListTrash(ctx)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, "recent", trash[0].Name)
+}
+
+func TestChangeLog(t *testing.T) {
+	db := NewTestDB(t)
+	db.source = SourceMCP
+	ctx := context.Background()
+
+	id, err := db.UpsertEntity(ctx, "Redis", "system", []string{"cache"})
+	require.NoError(t, err)
+	_, err = db.UpsertEntity(ctx, "Redis", "service", []string{"cache"})
+	require.NoError(t, err)
+	require.NoError(t, db.AddObservation(ctx, id, "port 6379"))
+	require.NoError(t, db.AddObservation(ctx, id, "port 6379"), "a no-op write")
+	_, err = db.RetractObservation(ctx, "Redis", "not stored")
+	require.Error(t, err, "a failed write")
+	require.NoError(t, db.UpsertRelationByName(ctx, "api", "Redis", "uses"))
+	_, err = db.AppendJournal(ctx, "cache tuned", nil)
+	require.NoError(t, err)
+	require.NoError(t, db.HardDeleteEntity(ctx, "redis"))
+
+	changes, err := db.ListChanges(ctx, ChangeFilter{})
+	require.NoError(t, err)
+	var ops []string
+	for _, c := range changes {
+		ops = append(ops, c.Op)
+		assert.Equal(t, SourceMCP, c.Source)
+	}
+	assert.Equal(t, []string{"entity.delete", "journal.add", "relation.add", "observation.add", "entity.upsert", "entity.upsert"}, ops,
+		"newest first; no-op and failed writes are not logged")
+
+	assert.Equal(t, `+entity "Redis"`, changes[5].Summary())
+	assert.Equal(t, `entity_type: "system" -> "service"`, changes[4].Summary())
+	assert.Equal(t, `+entity "api", +relation "uses"`, changes[2].Summary())
+
+	del := changes[0]
+	assert.Equal(t, "Redis", del.Entity)
+	require.NotNil(t, del.EntityID)
+	assert.Equal(t, id, *del.EntityID)
+	assert.Nil(t, del.After)
+	assert.Contains(t, string(del.Before), "port 6379", "hard deletes keep a copy of the observations")
+	assert.Equal(t, `-entity "Redis", -observation "port 6379", -relation "uses"`, del.Summary())
+
+	history, err := db.ListChanges(ctx, ChangeFilter{Entity: "REDIS"})
+	require.NoError(t, err)
+	assert.Len(t, history, 4, "history outlives the entity")
+
+	recent, err := db.ListChanges(ctx, ChangeFilter{Since: time.Now().Add(time.Hour).UnixMilli()})
+	require.NoError(t, err)
+	assert.Empty(t, recent)
+	limited, err := db.ListChanges(ctx, ChangeFilter{Limit: 2})
+	require.NoError(t, err)
+	assert.Len(t, limited, 2)
+
+	_, err = db.ExecContext(ctx, `UPDATE changes SET created_at = ? WHERE op = 'entity.upsert'`, time.Now().Add(-48*time.Hour).UnixMilli())
+	require.NoError(t, err)
+	pruned, err := db.PruneChanges(ctx, time.Now().Add(-24*time.Hour).UnixMilli())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), pruned)
+	changes, err = db.ListChanges(ctx, ChangeFilter{})
+	require.NoError(t, err)
+	assert.Len(t, changes, 4)
+	pruned, err = db.PruneChanges(ctx, time.Now().Add(-24*time.Hour).UnixMilli())
+	require.NoError(t, err)
+	assert.Zero(t, pruned)
+}
+
+func TestUndo(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	_, err := db.StoreEntities(ctx, []EntityInput{{Name: "Redis", EntityType: "system", Tags: []string{"cache"}, Observations: []string{"port 6379"}}})
+	require.NoError(t, err)
+	require.NoError(t, db.UpsertRelationByName(ctx, "api", "Redis", "uses"))
+	require.NoError(t, db.AddAlias(ctx, "Redis", "kv-store"))
+
+	// An accidental overwrite of type and tags.
+	_, err = db.UpsertEntity(ctx, "Redis", "concept", nil)
+	require.NoError(t, err)
+	plan, err := db.PlanUndo(ctx, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, plan, 1)
+	assert.Equal(t, "entity.upsert", plan[0].Op)
+	require.NoError(t, db.Undo(ctx, plan))
+	e, err := db.GetEntity(ctx, "Redis")
+	require.NoError(t, err)
+	assert.Equal(t, "system", e.EntityType)
+	assert.Equal(t, []string{"cache"}, e.Tags)
+
+	// A permanent delete comes back with observations, relations and aliases.
+	require.NoError(t, db.HardDeleteEntity(ctx, "Redis"))
+	plan, err = db.PlanUndo(ctx, 1, 0)
+	require.NoError(t, err)
+	require.Equal(t, "entity.delete", plan[0].Op)
+	require.NoError(t, db.Undo(ctx, plan))
+	e, err = db.GetEntity(ctx, "kv-store")
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, []string{"port 6379"}, e.Observations)
+	rels, err := db.ListRelationsByEntity(ctx, "Redis")
+	require.NoError(t, err)
+	assert.Len(t, rels, 1)
+	results, err := db.Search(ctx, "redis", "", nil, "", 10)
+	require.NoError(t, err)
+	assert.NotEmpty(t, results, "restored rows are re-indexed")
+
+	// Undone changes and undo entries are not planned again.
+	plan, err = db.PlanUndo(ctx, 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "alias.add", plan[0].Op)
+	undone, err := db.ListChanges(ctx, ChangeFilter{Limit: 2})
+	require.NoError(t, err)
+	assert.ErrorContains(t, db.Undo(ctx, undone[1:]), "already undone")
+
+	// Undoing a change whose rows were changed later is refused.
+	_, err = db.StoreEntities(ctx, []EntityInput{{Name: "Kafka", Observations: []string{"3 brokers"}}})
+	require.NoError(t, err)
+	kafka, err := db.PlanUndo(ctx, 1, 0)
+	require.NoError(t, err)
+	_, err = db.UpsertEntity(ctx, "Kafka", "system", nil)
+	require.NoError(t, err)
+	err = db.Undo(ctx, kafka)
+	assert.ErrorContains(t, err, "has changed since")
+	_, err = db.PlanUndoIDs(ctx, []int64{kafka[0].ID})
+	assert.ErrorContains(t, err, "no longer the newest", "a change was logged after the preview")
+
+	// Undoing both, newest first, removes the entity entirely.
+	plan, err = db.PlanUndo(ctx, 2, 0)
+	require.NoError(t, err)
+	confirmed, err := db.PlanUndoIDs(ctx, []int64{plan[1].ID, plan[0].ID})
+	require.NoError(t, err)
+	assert.Equal(t, plan, confirmed)
+	_, err = db.PlanUndoIDs(ctx, []int64{plan[0].ID, plan[0].ID})
+	assert.Error(t, err)
+	require.NoError(t, db.Undo(ctx, confirmed))
+	e, err = db.GetEntity(ctx, "Kafka")
+	require.NoError(t, err)
+	assert.Nil(t, e)
+
+	changes, err := db.ListChanges(ctx, ChangeFilter{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "undo", changes[0].Op)
+	require.NotNil(t, changes[0].Undoes)
+	for _, fts := range []string{"entities_fts", "entities_trigram", "observations_fts"} {
+		_, err = db.ExecContext(ctx, `INSERT INTO `+fts+`(`+fts+`, rank) VALUES('integrity-check', 1)`)
+		assert.NoError(t, err, fts)
+	}
+}
+
+func TestBackupRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "memory.db")
+	ctx := context.Background()
+
+	db, err := Open(path)
+	require.NoError(t, err)
+	_, err = db.StoreEntities(ctx, []EntityInput{{Name: "Redis", EntityType: "system", Observations: []string{"port 6379"}}})
+	require.NoError(t, err)
+
+	backup := BackupPath(DefaultBackupDir(path), path, time.Now())
+	require.NoError(t, db.Backup(ctx, backup))
+	assert.Error(t, db.Backup(ctx, backup), "existing files are not overwritten")
+	version, err := VerifyBackup(ctx, backup)
+	require.NoError(t, err)
+	assert.Equal(t, LatestSchemaVersion(), version)
+
+	require.NoError(t, db.HardDeleteEntity(ctx, "Redis"))
+	require.NoError(t, db.Close())
+
+	safety, err := Restore(ctx, backup, path, DefaultBackupDir(path))
+	require.NoError(t, err)
+	assert.FileExists(t, safety)
+
+	db, err = Open(path)
+	require.NoError(t, err)
+	e, err := db.GetEntity(ctx, "Redis")
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	hits, err := db.Search(ctx, "6379", "", nil, "", 10)
+	require.NoError(t, err)
+	assert.Len(t, hits, 1)
+	require.NoError(t, db.Close())
+
+	// The safety backup holds the database as it was before the restore.
+	saved, err := Open(safety)
+	require.NoError(t, err)
+	e, err = saved.GetEntity(ctx, "Redis")
+	require.NoError(t, err)
+	assert.Nil(t, e)
+	require.NoError(t, saved.Close())
+
+	garbage := filepath.Join(dir, "garbage.db")
+	require.NoError(t, os.WriteFile(garbage, []byte("not a database"), 0o600))
+	_, err = Restore(ctx, garbage, path, DefaultBackupDir(path))
+	assert.Error(t, err)
+}
+
+func TestDailyBackup(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "memory.db")
+	backups := filepath.Join(dir, "backups")
+	prefix := dailyPrefix(path)
+	require.NoError(t, os.MkdirAll(backups, 0o700))
+	for _, day := range []string{"2026-01-01", "2026-01-02", "2026-01-03"} {
+		require.NoError(t, os.WriteFile(filepath.Join(backups, prefix+day+".db"), nil, 0o600))
+	}
+	// Other databases' snapshots, even of a file with the same name in
+	// another directory, and manual backups are left alone.
+	other := dailyPrefix(filepath.Join(dir, "other", "memory.db"))
+	require.NotEqual(t, prefix, other)
+	require.NoError(t, os.WriteFile(filepath.Join(backups, other+"2026-01-01.db"), nil, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(backups, "memory-work.daily.2026-01-01.db"), nil, 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(backups, "memory.20260101-120000.db"), nil, 0o600))
+
+	for i := 0; i < 2; i++ {
+		db, err := OpenWithOptions(path, Options{DailyBackups: 2})
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+	}
+
+	entries, err := os.ReadDir(backups)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	today := prefix + time.Now().Format("2006-01-02") + ".db"
+	assert.ElementsMatch(t, []string{other + "2026-01-01.db", "memory-work.daily.2026-01-01.db", "memory.20260101-120000.db", prefix + "2026-01-03.db", today}, names,
+		"no temporary files are left behind")
+	_, err = VerifyBackup(context.Background(), filepath.Join(backups, today))
+	assert.NoError(t, err)
+
+	// A snapshot another process finished first is kept as it is.
+	require.NoError(t, os.WriteFile(filepath.Join(backups, today), nil, 0o600))
+	db, err := OpenWithOptions(path, Options{DailyBackups: 2})
+	require.NoError(t, err)
+	require.NoError(t, db.dailyBackup(context.Background(), path, backups, 2))
+	require.NoError(t, db.Close())
+	info, err := os.Stat(filepath.Join(backups, today))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	// A backup directory that cannot be created does not stop the open.
+	blocked := filepath.Join(dir, "blocked")
+	require.NoError(t, os.WriteFile(blocked, nil, 0o600))
+	db, err = OpenWithOptions(path, Options{DailyBackups: 2, BackupDir: filepath.Join(blocked, "backups")})
+	require.NoError(t, err)
+	assert.NoError(t, db.Close())
+}
+
+func TestTokens(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	secret, tok, err := db.CreateToken(ctx, "ci", []string{ScopeRead}, nil)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, "aimemo_"))
+	assert.Equal(t, []string{ScopeRead}, tok.Scopes)
+	assert.Empty(t, tok.Contexts)
+
+	_, _, err = db.CreateToken(ctx, "CI", []string{ScopeRead}, nil)
+	assert.ErrorContains(t, err, "already exists")
+	_, _, err = db.CreateToken(ctx, "admin", []string{"root"}, nil)
+	assert.ErrorContains(t, err, "invalid scope")
+
+	var stored string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT hash FROM tokens WHERE name = 'ci'`).Scan(&stored))
+	assert.NotContains(t, stored, strings.TrimPrefix(secret, "aimemo_"), "only a hash is stored")
+
+	got, err := db.AuthenticateToken(ctx, secret)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "ci", got.Name)
+	assert.NotNil(t, got.LastUsedAt)
+	got, err = db.AuthenticateToken(ctx, "aimemo_bogus")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	require.NoError(t, db.RevokeToken(ctx, "ci"))
+	got, err = db.AuthenticateToken(ctx, secret)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	assert.Error(t, db.RevokeToken(ctx, "ci"))
+	has, err := db.HasTokens(ctx)
+	require.NoError(t, err)
+	assert.False(t, has)
+}
+
+func TestTouchedSince(t *testing.T) {
+	db := NewTestDB(t)
+	ctx := context.Background()
+
+	redisID, err := db.UpsertEntity(ctx, "Redis", "system", nil)
+	require.NoError(t, err)
+	authID, err := db.UpsertEntity(ctx, "auth", "service", nil)
+	require.NoError(t, err)
+	mark, err := db.LastChangeID(ctx)
+	require.NoError(t, err)
+
+	touched, err := db.TouchedSince(ctx, mark)
+	require.NoError(t, err)
+	assert.Equal(t, mark, touched.LastID)
+	assert.Empty(t, touched.Entities)
+
+	// Observations and relations touch their entities without listing changes.
+	require.NoError(t, db.AddObservation(ctx, redisID, "Port 6379"))
+	require.NoError(t, db.UpsertRelation(ctx, authID, redisID, "uses"))
+	touched, err = db.TouchedSince(ctx, mark)
+	require.NoError(t, err)
+	assert.Greater(t, touched.LastID, mark)
+	assert.Equal(t, []string{"Redis", "auth"}, touched.Entities)
+	assert.Equal(t, []string{"service", "system"}, touched.Types)
+	assert.False(t, touched.Listed)
+
+	// Deletes and journal entries change the listing.
+	mark = touched.LastID
+	require.NoError(t, db.SoftDeleteEntity(ctx, "auth"))
+	_, err = db.AppendJournal(ctx, "Dropped auth", nil)
+	require.NoError(t, err)
+	touched, err = db.TouchedSince(ctx, mark)
+	require.NoError(t, err)
+	assert.Contains(t, touched.Entities, "auth")
+	assert.Equal(t, []string{time.Now().Format("2006-01-02")}, touched.Days)
+	assert.True(t, touched.Listed)
+
+	v, err := db.DataVersion(ctx)
+	require.NoError(t, err)
+	assert.Positive(t, v)
+}
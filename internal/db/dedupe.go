@@ -0,0 +1,393 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// NearDuplicateThreshold is the shingle similarity (Jaccard index, 0..1) at
+// or above which two observations are reported as likely duplicates.
+const NearDuplicateThreshold = 0.5
+
+const (
+	// minhashSize is the number of hash functions in a MinHash signature.
+	minhashSize = 64
+	// lshBands splits signatures into bands for locality-sensitive hashing;
+	// observations agreeing on every row of any band become candidates.
+	// 32 bands of 2 rows catch pairs at similarity 0.5 with near certainty.
+	lshBands = 32
+)
+
+// dedupeStopwords are ignored when comparing observations.
+var dedupeStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "its": true, "of": true, "on": true, "or": true, "that": true,
+	"the": true, "this": true, "to": true, "was": true, "were": true, "with": true,
+}
+
+// NearDuplicate reports a new observation that closely restates an existing
+// one on the same entity.
+type NearDuplicate struct {
+	Entity      string  `json:"entity"`
+	Observation string  `json:"observation"` // the observation being stored
+	Existing    string  `json:"existing"`    // the stored observation it resembles
+	Similarity  float64 `json:"similarity"`
+}
+
+// dedupeTokens normalizes s for comparison: lower case, split at anything
+// but letters and digits, stopwords dropped and common suffixes stripped,
+// so "Tokens expire" and "token expires" compare equal.
+func dedupeTokens(s string) []string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := words[:0]
+	for _, w := range words {
+		if dedupeStopwords[w] {
+			continue
+		}
+		if len(w) > 4 {
+			for _, suffix := range []string{"ing", "ed", "es", "s", "e"} {
+				if strings.HasSuffix(w, suffix) {
+					w = strings.TrimSuffix(w, suffix)
+					break
+				}
+			}
+		}
+		tokens = append(tokens, w)
+	}
+	return tokens
+}
+
+// shingles returns the set of word unigrams and bigrams of s, hashed.
+func shingles(s string) map[uint64]bool {
+	tokens := dedupeTokens(s)
+	set := make(map[uint64]bool, 2*len(tokens))
+	for i, t := range tokens {
+		set[hashString(t)] = true
+		if i > 0 {
+			set[hashString(tokens[i-1]+" "+t)] = true
+		}
+	}
+	return set
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// jaccard returns |a ∩ b| / |a ∪ b|, or 0 if both are empty.
+func jaccard(a, b map[uint64]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for x := range a {
+		if b[x] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+// minhash returns the MinHash signature of a shingle set: for each of
+// minhashSize seeded hash functions, the smallest hash over the set. Two
+// signatures agree in a slot with probability equal to the sets' Jaccard index.
+func minhash(set map[uint64]bool) [minhashSize]uint64 {
+	var sig [minhashSize]uint64
+	for i := range sig {
+		sig[i] = ^uint64(0)
+	}
+	for x := range set {
+		for i := range sig {
+			if h := mix64(x ^ uint64(i+1)*0x9e3779b97f4a7c15); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// mix64 is the splitmix64 finalizer, a fast well-distributed 64-bit hash.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// AddObservationWithDuplicates is AddObservation that also reports existing
+// observations of the entity that the new one closely restates. The
+// observation is stored either way; identical text is still a no-op.
+func (db *DB) AddObservationWithDuplicates(ctx context.Context, entityID int64, content string) ([]NearDuplicate, error) {
+	dups, err := nearDuplicates(ctx, db, entityID, content)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.AddObservation(ctx, entityID, content); err != nil {
+		return nil, err
+	}
+	return dups, nil
+}
+
+// nearDuplicates compares content with the entity's stored observations.
+func nearDuplicates(ctx context.Context, q querier, entityID int64, content string) ([]NearDuplicate, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT e.name, o.content FROM observations o JOIN entities e ON e.id = o.entity_id
+		WHERE o.entity_id = ? AND o.content != ?
+		ORDER BY o.created_at ASC, o.id ASC`, entityID, content)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	set := shingles(content)
+	var dups []NearDuplicate
+	for rows.Next() {
+		var name, existing string
+		if err := rows.Scan(&name, &existing); err != nil {
+			return nil, err
+		}
+		if sim := jaccard(set, shingles(existing)); sim >= NearDuplicateThreshold {
+			dups = append(dups, NearDuplicate{Entity: name, Observation: content, Existing: existing, Similarity: sim})
+		}
+	}
+	return dups, rows.Err()
+}
+
+// ObservationRecord is a stored observation with its identity.
+type ObservationRecord struct {
+	ID        int64  `json:"id"`
+	Content   string `json:"content"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// DuplicateCluster is a group of observations on one entity that restate
+// each other, oldest first.
+type DuplicateCluster struct {
+	EntityID     int64               `json:"entity_id"`
+	Entity       string              `json:"entity"`
+	Observations []ObservationRecord `json:"observations"`
+}
+
+// FindDuplicateClusters groups the observations of every active entity (or
+// just entityName, a name or alias, if set) into clusters of near-duplicates:
+// observations linked by a chain of pairs with similarity >= threshold, so
+// members at either end of a chain may differ more (see Direct). Candidate pairs
+// come from MinHash LSH, so large entities are not compared pairwise, and
+// are confirmed with the exact similarity.
+func (db *DB) FindDuplicateClusters(ctx context.Context, entityName string, threshold float64) ([]DuplicateCluster, error) {
+	if threshold <= 0 {
+		threshold = NearDuplicateThreshold
+	}
+	query := `
+		SELECT e.id, e.name, o.id, o.content, o.created_at
+		FROM observations o JOIN entities e ON e.id = o.entity_id
+		WHERE e.deleted_at IS NULL`
+	var args []interface{}
+	if entityName != "" {
+		id, err := db.activeEntityID(ctx, entityName)
+		if err != nil {
+			return nil, err
+		}
+		query += " AND e.id = ?"
+		args = append(args, id)
+	}
+	query += " ORDER BY e.id, o.created_at ASC, o.id ASC"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clusters []DuplicateCluster
+	var cur DuplicateCluster
+	for rows.Next() {
+		var entityID int64
+		var name string
+		var o ObservationRecord
+		if err := rows.Scan(&entityID, &name, &o.ID, &o.Content, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		if entityID != cur.EntityID {
+			clusters = append(clusters, clusterEntity(cur, threshold)...)
+			cur = DuplicateCluster{EntityID: entityID, Entity: name}
+		}
+		cur.Observations = append(cur.Observations, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return append(clusters, clusterEntity(cur, threshold)...), nil
+}
+
+// clusterEntity splits the observations of one entity into duplicate
+// clusters of two or more.
+func clusterEntity(all DuplicateCluster, threshold float64) []DuplicateCluster {
+	obs := all.Observations
+	if len(obs) < 2 {
+		return nil
+	}
+
+	sets := make([]map[uint64]bool, len(obs))
+	buckets := map[[2]uint64][]int{}
+	for i, o := range obs {
+		sets[i] = shingles(o.Content)
+		if len(sets[i]) == 0 {
+			continue
+		}
+		sig := minhash(sets[i])
+		rows := minhashSize / lshBands
+		for b := 0; b < lshBands; b++ {
+			h := uint64(b)
+			for _, v := range sig[b*rows : (b+1)*rows] {
+				h = mix64(h ^ v)
+			}
+			key := [2]uint64{uint64(b), h}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	parent := make([]int, len(obs))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	checked := map[[2]int]bool{}
+	for _, members := range buckets {
+		for x := 0; x < len(members); x++ {
+			for y := x + 1; y < len(members); y++ {
+				i, j := members[x], members[y]
+				if checked[[2]int{i, j}] {
+					continue
+				}
+				checked[[2]int{i, j}] = true
+				if jaccard(sets[i], sets[j]) >= threshold {
+					parent[find(i)] = find(j)
+				}
+			}
+		}
+	}
+
+	groups := map[int][]ObservationRecord{}
+	var roots []int
+	for i, o := range obs {
+		r := find(i)
+		if _, ok := groups[r]; !ok {
+			roots = append(roots, r)
+		}
+		groups[r] = append(groups[r], o)
+	}
+	var out []DuplicateCluster
+	for _, r := range roots {
+		if len(groups[r]) > 1 {
+			out = append(out, DuplicateCluster{EntityID: all.EntityID, Entity: all.Entity, Observations: groups[r]})
+		}
+	}
+	return out
+}
+
+// Canonical rules for choosing the observation a duplicate cluster keeps.
+const (
+	KeepLongest = "longest" // most text, newest on ties
+	KeepNewest  = "newest"
+	KeepOldest  = "oldest"
+)
+
+// Canonical returns the index of the observation c keeps under rule.
+func (c DuplicateCluster) Canonical(rule string) (int, error) {
+	idx := make([]int, len(c.Observations))
+	for i := range idx {
+		idx[i] = i
+	}
+	obs := c.Observations
+	var prefer func(a, b int) bool // reports whether a is kept over b
+	switch rule {
+	case "", KeepLongest:
+		prefer = func(a, b int) bool {
+			if len(obs[a].Content) != len(obs[b].Content) {
+				return len(obs[a].Content) > len(obs[b].Content)
+			}
+			return a > b
+		}
+	case KeepNewest:
+		prefer = func(a, b int) bool { return a > b }
+	case KeepOldest:
+		prefer = func(a, b int) bool { return a < b }
+	default:
+		return 0, fmt.Errorf("unknown keep rule %q: use longest, newest or oldest", rule)
+	}
+	if len(idx) == 0 {
+		return 0, fmt.Errorf("empty duplicate cluster")
+	}
+	sort.Slice(idx, func(i, j int) bool { return prefer(idx[i], idx[j]) })
+	return idx[0], nil
+}
+
+// Direct reports whether every observation of c is at least threshold
+// similar to observation keep itself, not just linked to it through a chain
+// of similar pairs. Only direct clusters are safe to merge unattended.
+func (c DuplicateCluster) Direct(keep int, threshold float64) bool {
+	if threshold <= 0 {
+		threshold = NearDuplicateThreshold
+	}
+	if keep < 0 || keep >= len(c.Observations) {
+		return false
+	}
+	kept := shingles(c.Observations[keep].Content)
+	for i, o := range c.Observations {
+		if i != keep && jaccard(kept, shingles(o.Content)) < threshold {
+			return false
+		}
+	}
+	return true
+}
+
+// MergeDuplicates keeps observation keep of the cluster and deletes the
+// others, in one transaction. It returns the number deleted.
+func (db *DB) MergeDuplicates(ctx context.Context, c DuplicateCluster, keep int) (int, error) {
+	if keep < 0 || keep >= len(c.Observations) {
+		return 0, fmt.Errorf("keep index %d out of range", keep)
+	}
+	ids := make([]interface{}, 0, len(c.Observations))
+	for i, o := range c.Observations {
+		if i != keep {
+			ids = append(ids, o.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	deleted := 0
+	m := &mutation{op: "observation.dedupe", entityID: c.EntityID,
+		scope: scope{}.add("observations", "id IN ("+placeholders(len(ids))+")", ids...)}
+	err := db.mutate(ctx, m, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			res, err := tx.ExecContext(ctx, `DELETE FROM observations WHERE id = ? AND entity_id = ?`, id, c.EntityID)
+			if err != nil {
+				return err
+			}
+			n, _ := res.RowsAffected()
+			deleted += int(n)
+		}
+		return nil
+	})
+	return deleted, err
+}
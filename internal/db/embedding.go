@@ -0,0 +1,430 @@
+package db
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Search modes accepted by SearchModePage.
+const (
+	ModeKeyword  = "keyword"  // FTS5 with BM25 and importance (Search)
+	ModeSemantic = "semantic" // cosine similarity of observation embeddings
+	ModeHybrid   = "hybrid"   // reciprocal-rank fusion of keyword and semantic
+)
+
+const (
+	// embedBatchSize is how many observations are sent to the embedder at once.
+	embedBatchSize = 32
+
+	// minSimilarity drops semantic hits whose best observation is about as
+	// close to the query as unrelated text.
+	minSimilarity = 0.1
+
+	// hybridPool is how many top hits of each ranking take part in fusion.
+	hybridPool = 100
+
+	// rrfK damps the influence of the very top ranks in reciprocal-rank fusion.
+	rrfK = 60
+)
+
+// rankedEntity is one hit of an in-memory ranking.
+type rankedEntity struct {
+	id    int64
+	score float64
+	match int64 // observation to report as the match; 0 for none
+}
+
+// SearchModePage is SearchPage with a search mode: keyword (the default when
+// mode is ""), semantic or hybrid. Semantic and hybrid queries accept the
+// same syntax; their text is the query's positive terms, and field filters
+// apply as usual. A query with no text lists entities like Search.
+func (db *DB) SearchModePage(ctx context.Context, mode, query, entityType string, tags []string, sort string, limit int, cursor string) ([]SearchResult, string, error) {
+	switch mode {
+	case "", ModeKeyword:
+		return db.SearchPage(ctx, query, entityType, tags, sort, limit, cursor)
+	case ModeSemantic, ModeHybrid:
+	default:
+		return nil, "", fmt.Errorf("unknown search mode %q: use keyword, semantic or hybrid", mode)
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	pq, err := ParseQuery(query)
+	if err != nil {
+		return nil, "", err
+	}
+	pg, err := newPage(limit, cursor, mode, query, entityType, strings.Join(tags, ","), sort)
+	if err != nil {
+		return nil, "", err
+	}
+	text := strings.Join(pq.Terms, " ")
+	if pq.Match == "" || text == "" {
+		return db.listAll(ctx, entityType, tags, pq.Filters, sort, pg)
+	}
+
+	semantic, err := db.semanticRanking(ctx, text, entityType, tags, pq.Filters)
+	if err != nil {
+		return nil, "", err
+	}
+	if mode == ModeSemantic {
+		return db.rankedPage(ctx, semantic, pg)
+	}
+
+	keyword, err := db.keywordRanking(ctx, pq.Match, entityType, tags, pq.Filters, pg.clock)
+	if err != nil {
+		return nil, "", err
+	}
+	results, next, err := db.rankedPage(ctx, fuseRankings(keyword, semantic), pg)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := db.attachMatches(ctx, wordIndex, pq.Match, results); err != nil {
+		return nil, "", fmt.Errorf("search matches: %w", err)
+	}
+	return results, next, nil
+}
+
+// fuseRankings merges the top hybridPool hits of the keyword ranking (entity
+// IDs, best first) and the semantic ranking by reciprocal-rank fusion: each
+// entity scores the sum of 1/(rrfK+rank) over the rankings it appears in.
+// Semantic matches are kept only for entities the keyword ranking missed;
+// the others get highlighted keyword matches instead.
+func fuseRankings(keyword []int64, semantic []rankedEntity) []rankedEntity {
+	fused := map[int64]*rankedEntity{}
+	for i, id := range keyword {
+		fused[id] = &rankedEntity{id: id, score: 1 / float64(rrfK+i+1)}
+	}
+	for i, r := range semantic {
+		if i == hybridPool {
+			break
+		}
+		score := 1 / float64(rrfK+i+1)
+		if f, ok := fused[r.id]; ok {
+			f.score += score
+		} else {
+			fused[r.id] = &rankedEntity{id: r.id, score: score, match: r.match}
+		}
+	}
+	out := make([]rankedEntity, 0, len(fused))
+	for _, f := range fused {
+		out = append(out, *f)
+	}
+	sortRanked(out)
+	return out
+}
+
+func sortRanked(r []rankedEntity) {
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].score != r[j].score {
+			return r[i].score > r[j].score
+		}
+		return r[i].id < r[j].id
+	})
+}
+
+// keywordRanking returns the IDs of the top hybridPool entities for the FTS5
+// expression match, in Search order.
+func (db *DB) keywordRanking(ctx context.Context, match, entityType string, tags []string, filters []QueryFilter, clock int64) ([]int64, error) {
+	inner, args := db.ranking.relevanceSQL(wordIndex, match, clock)
+	where, whereArgs := entityFilterSQL(entityType, tags, filters)
+	args = append(append(args, whereArgs...), hybridPool)
+
+	rows, err := db.QueryContext(ctx, "SELECT id FROM ("+inner+where+") ORDER BY final_rank DESC, id ASC LIMIT ?", args...)
+	if err != nil {
+		return nil, fmt.Errorf("search query: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// semanticRanking scores each active entity matching the filters by the
+// cosine similarity between text and its closest observation, best first.
+// Only observations that already have a vector from the current embedder
+// take part; searching never writes, so the rest wait for the next write to
+// their entity or for Reindex.
+func (db *DB) semanticRanking(ctx context.Context, text, entityType string, tags []string, filters []QueryFilter) ([]rankedEntity, error) {
+	vecs, err := db.embedder.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	query := normalize(vecs[0])
+
+	where, args := entityFilterSQL(entityType, tags, filters)
+	rows, err := db.QueryContext(ctx, `
+		SELECT o.entity_id, o.id, v.vector
+		FROM observation_embeddings v
+		JOIN observations o ON o.id = v.observation_id
+		JOIN entities e ON e.id = o.entity_id
+		WHERE e.deleted_at IS NULL AND v.model = ?`+where,
+		append([]interface{}{db.embedder.Name()}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("semantic search: %w", err)
+	}
+	defer rows.Close()
+
+	best := map[int64]*rankedEntity{}
+	for rows.Next() {
+		var entityID, obsID int64
+		var blob []byte
+		if err := rows.Scan(&entityID, &obsID, &blob); err != nil {
+			return nil, err
+		}
+		sim := dot(query, decodeVector(blob))
+		if sim < minSimilarity {
+			continue
+		}
+		if b, ok := best[entityID]; !ok || sim > b.score {
+			best[entityID] = &rankedEntity{id: entityID, score: sim, match: obsID}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ranked := make([]rankedEntity, 0, len(best))
+	for _, b := range best {
+		ranked = append(ranked, *b)
+	}
+	sortRanked(ranked)
+	return ranked, nil
+}
+
+// rankedPage returns the page of ranked after pg's cursor as search results,
+// with each result's match observation, if any, as its only match.
+func (db *DB) rankedPage(ctx context.Context, ranked []rankedEntity, pg page) ([]SearchResult, string, error) {
+	if c := pg.after; c != nil {
+		i := sort.Search(len(ranked), func(i int) bool {
+			r := ranked[i]
+			return r.score < c.Score || (r.score == c.Score && r.id > c.ID)
+		})
+		ranked = ranked[i:]
+	}
+	var next string
+	if len(ranked) > pg.limit {
+		ranked = ranked[:pg.limit]
+		last := ranked[len(ranked)-1]
+		next = pg.next(pageCursor{Clock: pg.clock, Score: last.score, ID: last.id})
+	}
+	if len(ranked) == 0 {
+		return nil, "", nil
+	}
+
+	ids := make([]interface{}, len(ranked))
+	for i, r := range ranked {
+		ids[i] = r.id
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.id, e.name, e.entity_type, e.tags, e.created_at, e.updated_at, e.deleted_at,
+		       e.access_count, e.last_accessed, 0.0
+		FROM entities e WHERE e.id IN (`+placeholders(len(ids))+`)`, ids...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+	found, err := scanSearchRows(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	byID := make(map[int64]SearchResult, len(found))
+	for _, r := range found {
+		byID[r.ID] = r
+	}
+
+	results := make([]SearchResult, 0, len(ranked))
+	for _, r := range ranked {
+		res, ok := byID[r.id]
+		if !ok {
+			continue
+		}
+		res.Score = r.score
+		if r.match != 0 {
+			var content string
+			if err := db.QueryRowContext(ctx, `SELECT content FROM observations WHERE id = ?`, r.match).Scan(&content); err != nil {
+				return nil, "", err
+			}
+			res.Matches = []ObservationMatch{{Content: content, Highlight: content}}
+		}
+		results = append(results, res)
+	}
+	if err := db.loadObservations(ctx, results); err != nil {
+		return nil, "", err
+	}
+	return results, next, nil
+}
+
+// embedEntity embeds the observations of an entity that have no vector
+// from the current embedder, after a write that may have added some. It is
+// best effort: the write has already committed, so a failing embedder is
+// logged and the observations are left for Reindex.
+func (db *DB) embedEntity(ctx context.Context, entityID int64) {
+	if _, err := db.embedMissing(ctx, entityID, nil); err != nil {
+		slog.Warn("embed observations", "entity_id", entityID, "err", err)
+	}
+}
+
+// embedMissing embeds every observation that has no vector from the current
+// embedder, only those of entityID unless it is 0, in batches, calling
+// progress after each. It returns how many observations were embedded.
+func (db *DB) embedMissing(ctx context.Context, entityID int64, progress func(done int)) (int, error) {
+	model := db.embedder.Name()
+	done := 0
+	for {
+		rows, err := db.QueryContext(ctx, `
+			SELECT o.id, o.content FROM observations o
+			LEFT JOIN observation_embeddings v ON v.observation_id = o.id AND v.model = ?
+			WHERE v.observation_id IS NULL AND (? = 0 OR o.entity_id = ?)
+			ORDER BY o.id LIMIT ?`, model, entityID, entityID, embedBatchSize)
+		if err != nil {
+			return done, err
+		}
+		var ids []int64
+		var texts []string
+		for rows.Next() {
+			var id int64
+			var content string
+			if err := rows.Scan(&id, &content); err != nil {
+				rows.Close()
+				return done, err
+			}
+			ids = append(ids, id)
+			texts = append(texts, content)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return done, err
+		}
+		if len(ids) == 0 {
+			return done, nil
+		}
+
+		vecs, err := db.embedder.Embed(ctx, texts)
+		if err != nil {
+			return done, fmt.Errorf("embed observations: %w", err)
+		}
+		if len(vecs) != len(ids) {
+			return done, fmt.Errorf("embed observations: got %d vectors for %d inputs", len(vecs), len(ids))
+		}
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return done, err
+		}
+		for i, id := range ids {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT OR REPLACE INTO observation_embeddings (observation_id, model, vector)
+				VALUES (?, ?, ?)`, id, model, encodeVector(normalize(vecs[i]))); err != nil {
+				tx.Rollback()
+				return done, err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return done, err
+		}
+		done += len(ids)
+		if progress != nil {
+			progress(done)
+		}
+	}
+}
+
+// ReindexStats reports the outcome of Reindex.
+type ReindexStats struct {
+	Model    string `json:"model"`
+	Embedded int    `json:"embedded"`
+	Total    int    `json:"total"` // observations with a current vector afterwards
+}
+
+// Reindex brings observation embeddings up to date with the current
+// embedder: vectors from other embedders are dropped and missing ones
+// computed. force recomputes every vector. progress, if non-nil, is called
+// with the number embedded so far and the number to embed.
+func (db *DB) Reindex(ctx context.Context, force bool, progress func(done, todo int)) (ReindexStats, error) {
+	model := db.embedder.Name()
+	stats := ReindexStats{Model: model}
+
+	drop, args := `DELETE FROM observation_embeddings WHERE model != ?`, []interface{}{model}
+	if force {
+		drop, args = `DELETE FROM observation_embeddings`, nil
+	}
+	if _, err := db.ExecContext(ctx, drop, args...); err != nil {
+		return stats, err
+	}
+
+	var todo int
+	if err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM observations
+		WHERE id NOT IN (SELECT observation_id FROM observation_embeddings)`).Scan(&todo); err != nil {
+		return stats, err
+	}
+	var report func(int)
+	if progress != nil {
+		report = func(done int) { progress(done, todo) }
+	}
+	n, err := db.embedMissing(ctx, 0, report)
+	stats.Embedded = n
+	if err != nil {
+		return stats, err
+	}
+	err = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM observation_embeddings`).Scan(&stats.Total)
+	return stats, err
+}
+
+// normalize scales v to unit length so cosine similarity is a dot product.
+func normalize(v []float32) []float32 {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	scale := 1 / math.Sqrt(sum)
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(float64(x) * scale)
+	}
+	return out
+}
+
+// dot returns the dot product of a and b, or 0 if their lengths differ.
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// encodeVector stores v as little-endian float32s.
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+	return b
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
@@ -20,6 +20,7 @@ type Entity struct {
 	DeletedAt    *int64   `json:"deleted_at,omitempty"`
 	AccessCount  int64    `json:"access_count"`
 	LastAccessed *int64   `json:"last_accessed,omitempty"`
+	Aliases      []string `json:"aliases,omitempty"`
 	Observations []string `json:"observations,omitempty"`
 }
 
@@ -29,6 +30,7 @@ type EntityInput struct {
 	EntityType   string   `json:"entityType"`
 	Observations []string `json:"observations"`
 	Tags         []string `json:"tags"`
+	Aliases      []string `json:"aliases"`
 }
 
 // scanEntity scans a row into an Entity (without Observations).
@@ -68,6 +70,19 @@ func scanEntity(row interface {
 // UpsertEntity upserts an entity (insert or update name/type/tags/updated_at).
 // Returns the entity ID.
 func (db *DB) UpsertEntity(ctx context.Context, name, entityType string, tags []string) (int64, error) {
+	var id int64
+	m := &mutation{op: "entity.upsert", entity: name, scope: scope{}.add("entities", "name = ?", name)}
+	err := db.mutate(ctx, m, func(tx *sql.Tx) error {
+		var err error
+		id, err = upsertEntity(ctx, tx, name, entityType, tags)
+		m.entityID = id
+		return err
+	})
+	return id, err
+}
+
+// upsertEntity is UpsertEntity without the change log entry.
+func upsertEntity(ctx context.Context, q querier, name, entityType string, tags []string) (int64, error) {
 	if len(name) == 0 {
 		return 0, fmt.Errorf("entity name cannot be empty")
 	}
@@ -86,7 +101,7 @@ func (db *DB) UpsertEntity(ctx context.Context, name, entityType string, tags []
 	}
 
 	// Upsert: insert or update on conflict (no WHERE so it always upserts)
-	_, err = db.ExecContext(ctx, `
+	_, err = q.ExecContext(ctx, `
 		INSERT INTO entities (name, entity_type, tags, updated_at)
 		VALUES (?, ?, ?, unixepoch('now', 'subsec') * 1000)
 		ON CONFLICT(name) DO UPDATE SET
@@ -101,28 +116,27 @@ func (db *DB) UpsertEntity(ctx context.Context, name, entityType string, tags []
 
 	// Always query for the ID — LastInsertId() is unreliable for UPSERT
 	var id int64
-	err = db.QueryRowContext(ctx, `SELECT id FROM entities WHERE name = ?`, name).Scan(&id)
+	err = q.QueryRowContext(ctx, `SELECT id FROM entities WHERE name = ?`, name).Scan(&id)
 	if err != nil {
 		return 0, fmt.Errorf("get entity id: %w", err)
 	}
 	return id, nil
 }
 
-// GetEntity retrieves an entity by name (case-insensitive), with its observations.
+// GetEntity retrieves an entity by name (case-insensitive) or alias, with its
+// observations and aliases.
 func (db *DB) GetEntity(ctx context.Context, name string) (*Entity, error) {
-	row := db.QueryRowContext(ctx, `
-		SELECT id, name, entity_type, tags, created_at, updated_at, deleted_at, access_count, last_accessed
-		FROM entities
-		WHERE lower(name) = lower(?) AND deleted_at IS NULL
-	`, name)
-
-	e, err := scanEntity(row)
+	id, _, err := resolveEntity(ctx, db, name)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
+	e, err := db.GetEntityByID(ctx, id)
+	if err != nil || e == nil {
+		return e, err
+	}
 
 	// Update access count
 	now := time.Now().UnixMilli()
@@ -137,6 +151,9 @@ func (db *DB) GetEntity(ctx context.Context, name string) (*Entity, error) {
 		return nil, err
 	}
 	e.Observations = obs
+	if e.Aliases, err = db.aliasesByEntityID(ctx, e.ID); err != nil {
+		return nil, err
+	}
 	return e, nil
 }
 
@@ -212,63 +229,191 @@ func (db *DB) ListEntities(ctx context.Context, entityType string, tags []string
 	return entities, rows.Err()
 }
 
-// SoftDeleteEntity soft-deletes an entity by name.
+// EntityTypes returns the entity type of every active entity matching the
+// filters, keyed by ID. It is a lightweight index for callers that stream the
+// entities themselves page by page.
+func (db *DB) EntityTypes(ctx context.Context, entityType string, tags []string) (map[int64]string, error) {
+	where, args := entityFilterSQL(entityType, tags, nil)
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.id, e.entity_type FROM entities e
+		WHERE e.deleted_at IS NULL`+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var t string
+		if err := rows.Scan(&id, &t); err != nil {
+			return nil, err
+		}
+		types[id] = t
+	}
+	return types, rows.Err()
+}
+
+// TypeCount is the number of active entities of one entity type.
+type TypeCount struct {
+	EntityType string `json:"entity_type"`
+	Count      int    `json:"count"`
+}
+
+// EntityTypeCounts returns the entity types in use, with how many active
+// entities have each, ordered by type.
+func (db *DB) EntityTypeCounts(ctx context.Context) ([]TypeCount, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT entity_type, COUNT(*) FROM entities
+		WHERE deleted_at IS NULL
+		GROUP BY entity_type ORDER BY entity_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []TypeCount
+	for rows.Next() {
+		var c TypeCount
+		if err := rows.Scan(&c.EntityType, &c.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// SoftDeleteEntity soft-deletes an entity by name or alias.
 func (db *DB) SoftDeleteEntity(ctx context.Context, name string) error {
-	now := time.Now().UnixMilli()
-	res, err := db.ExecContext(ctx, `
-		UPDATE entities SET deleted_at = ? WHERE lower(name) = lower(?) AND deleted_at IS NULL
-	`, now, name)
+	name, err := db.canonicalName(ctx, name)
 	if err != nil {
 		return err
 	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return fmt.Errorf("entity %q not found", name)
-	}
-	return nil
+	m := &mutation{op: "entity.forget", entity: name, scope: scope{}.add("entities", "lower(name) = lower(?) AND deleted_at IS NULL", name)}
+	return db.mutate(ctx, m, func(tx *sql.Tx) error {
+		_ = tx.QueryRowContext(ctx, `
+			SELECT id, name FROM entities WHERE lower(name) = lower(?) AND deleted_at IS NULL
+		`, name).Scan(&m.entityID, &m.entity)
+		now := time.Now().UnixMilli()
+		res, err := tx.ExecContext(ctx, `
+			UPDATE entities SET deleted_at = ? WHERE lower(name) = lower(?) AND deleted_at IS NULL
+		`, now, name)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		if n == 0 {
+			return fmt.Errorf("entity %q not found", name)
+		}
+		return nil
+	})
 }
 
-// HardDeleteEntity permanently deletes an entity and all its observations/relations.
+// HardDeleteEntity permanently deletes an entity, by name or by the alias of
+// an active entity, and all its observations/relations.
+// The change log keeps a full copy of everything removed.
 func (db *DB) HardDeleteEntity(ctx context.Context, name string) error {
-	res, err := db.ExecContext(ctx, `DELETE FROM entities WHERE lower(name) = lower(?)`, name)
+	name, err := db.canonicalName(ctx, name)
 	if err != nil {
 		return err
 	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return fmt.Errorf("entity %q not found", name)
-	}
-	return nil
+	m := &mutation{op: "entity.delete", entity: name, scope: entityScope("lower(name) = lower(?)", name)}
+	return db.mutate(ctx, m, func(tx *sql.Tx) error {
+		_ = tx.QueryRowContext(ctx, `
+			SELECT id, name FROM entities WHERE lower(name) = lower(?) ORDER BY deleted_at IS NOT NULL LIMIT 1
+		`, name).Scan(&m.entityID, &m.entity)
+		res, err := tx.ExecContext(ctx, `DELETE FROM entities WHERE lower(name) = lower(?)`, name)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		if n == 0 {
+			return fmt.Errorf("entity %q not found", name)
+		}
+		return nil
+	})
 }
 
 // StoreEntities upserts a batch of entities with their observations.
 func (db *DB) StoreEntities(ctx context.Context, inputs []EntityInput) ([]Entity, error) {
+	results, _, err := db.StoreEntitiesWithDuplicates(ctx, inputs)
+	return results, err
+}
+
+// StoreEntitiesWithDuplicates is StoreEntities that also reports new
+// observations that closely restate ones already stored (see
+// AddObservationWithDuplicates). An input named by an existing alias updates
+// the entity the alias refers to.
+func (db *DB) StoreEntitiesWithDuplicates(ctx context.Context, inputs []EntityInput) ([]Entity, []NearDuplicate, error) {
 	var results []Entity
+	var dups []NearDuplicate
 	for _, inp := range inputs {
 		entityType := inp.EntityType
 		if entityType == "" {
 			entityType = "concept"
 		}
-		id, err := db.UpsertEntity(ctx, inp.Name, entityType, inp.Tags)
-		if err != nil {
-			return nil, fmt.Errorf("upsert %q: %w", inp.Name, err)
+		name := inp.Name
+		if _, canonical, err := resolveEntity(ctx, db, name); err == nil {
+			name = canonical
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+
+		// One change per entity: the entity row plus the aliases and
+		// observations this input could add.
+		ids := `SELECT id FROM entities WHERE name = ?`
+		sc := scope{}.add("entities", "name = ?", name).
+			add("entity_aliases", "entity_id IN ("+ids+")", name)
+		if len(inp.Observations) > 0 {
+			args := []interface{}{name}
+			for _, obs := range inp.Observations {
+				args = append(args, obs)
+			}
+			sc.add("observations", "entity_id IN ("+ids+") AND content IN ("+placeholders(len(inp.Observations))+")", args...)
 		}
-		for _, obs := range inp.Observations {
-			if err := db.AddObservation(ctx, id, obs); err != nil {
-				return nil, fmt.Errorf("add observation to %q: %w", inp.Name, err)
+		var id int64
+		m := &mutation{op: "entity.store", entity: name, scope: sc}
+		err := db.mutate(ctx, m, func(tx *sql.Tx) error {
+			var err error
+			if id, err = upsertEntity(ctx, tx, name, entityType, inp.Tags); err != nil {
+				return fmt.Errorf("upsert %q: %w", inp.Name, err)
+			}
+			m.entityID = id
+			for _, alias := range inp.Aliases {
+				if err := addAlias(ctx, tx, id, alias); err != nil {
+					return fmt.Errorf("alias %q: %w", inp.Name, err)
+				}
+			}
+			for _, obs := range inp.Observations {
+				d, err := nearDuplicates(ctx, tx, id, obs)
+				if err != nil {
+					return err
+				}
+				if err := addObservation(ctx, tx, id, obs); err != nil {
+					return fmt.Errorf("add observation to %q: %w", inp.Name, err)
+				}
+				dups = append(dups, d...)
 			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(inp.Observations) > 0 {
+			db.embedEntity(ctx, id)
 		}
 		e, err := db.GetEntityByID(ctx, id)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		obs, _ := db.ListObservationsByEntityID(ctx, id)
 		if e != nil {
 			e.Observations = obs
+			e.Aliases, _ = db.aliasesByEntityID(ctx, id)
 			results = append(results, *e)
 		}
 	}
-	return results, nil
+	return results, dups, nil
 }
 
 // placeholders returns n comma-separated "?" for SQL IN clauses.
@@ -0,0 +1,304 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFuzzyExpansions caps how many vocabulary terms one query token expands to.
+const maxFuzzyExpansions = 3
+
+// fuzzyCandidate is a vocabulary term close to a query token.
+type fuzzyCandidate struct {
+	term string // indexed (stemmed) term
+	cost int    // 0 exact, 1-2 edits, prefix or abbreviation
+	docs int
+}
+
+// FuzzyEnabled reports whether typo-tolerant fallback search is on.
+func (db *DB) FuzzyEnabled() bool {
+	return db.fuzzy
+}
+
+// FuzzySearch is the typo-tolerant fallback for a query that Search found
+// nothing for. Operators and negated terms are dropped and field filters
+// kept; each remaining word is expanded to nearby vocabulary terms (by
+// edit distance, prefix or abbreviation) and the expansions are searched as
+// words; if that still finds nothing, tokens are matched as substrings in the
+// trigram index. All results are flagged Fuzzy. The returned suggestion is the
+// query rewritten with the closest known words, or "" if it would not change.
+func (db *DB) FuzzySearch(ctx context.Context, query, entityType string, tags []string, limit int) ([]SearchResult, string, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	pq, err := ParseQuery(query)
+	if err != nil {
+		return nil, "", err
+	}
+	tokens := fuzzyTokens(strings.Join(pq.Terms, " "))
+	if len(tokens) == 0 {
+		return nil, "", nil
+	}
+
+	var clauses, suggested []string
+	for _, tok := range tokens {
+		vocab, err := db.vocabulary(ctx, tok)
+		if err != nil {
+			return nil, "", err
+		}
+		cands := closestTerms(tok, vocab)
+		if len(cands) == 0 {
+			suggested = append(suggested, tok)
+			continue
+		}
+		var words []string
+		for _, c := range cands {
+			w, err := db.surfaceForm(ctx, c.term)
+			if err != nil {
+				return nil, "", err
+			}
+			words = append(words, ftsEscape(w))
+		}
+		clauses = append(clauses, "("+strings.Join(words, " OR ")+")")
+		if cands[0].cost == 0 {
+			suggested = append(suggested, tok)
+		} else {
+			suggested = append(suggested, strings.Trim(words[0], `"`))
+		}
+	}
+
+	suggestion := strings.Join(suggested, " ")
+	if suggestion == strings.Join(tokens, " ") {
+		suggestion = ""
+	}
+
+	pg, err := newPage(limit, "")
+	if err != nil {
+		return nil, "", err
+	}
+	var results []SearchResult
+	if len(clauses) > 0 {
+		results, _, err = db.searchIndex(ctx, wordIndex, strings.Join(clauses, " AND "), entityType, tags, pq.Filters, pg)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if len(results) == 0 {
+		var grams []string
+		for _, tok := range tokens {
+			if len([]rune(tok)) >= 3 {
+				grams = append(grams, ftsEscape(tok))
+			}
+		}
+		if len(grams) > 0 {
+			results, _, err = db.searchIndex(ctx, trigramIndex, strings.Join(grams, " OR "), entityType, tags, pq.Filters, pg)
+			if err != nil {
+				return nil, "", err
+			}
+		}
+	}
+	for i := range results {
+		results[i].Fuzzy = true
+	}
+	return results, suggestion, nil
+}
+
+// fuzzyTokens lowercases a query and splits it into words at non-alphanumeric
+// characters and camelCase boundaries, so "authSvc" yields "auth", "svc".
+func fuzzyTokens(q string) []string {
+	var tokens []string
+	var cur []rune
+	var prev rune
+	flush := func() {
+		if len(cur) > 0 {
+			tokens = append(tokens, string(cur))
+			cur = cur[:0]
+		}
+	}
+	for _, r := range q {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if unicode.IsUpper(r) && unicode.IsLower(prev) {
+				flush()
+			}
+			cur = append(cur, unicode.ToLower(r))
+		} else {
+			flush()
+		}
+		prev = r
+	}
+	flush()
+	return tokens
+}
+
+// vocabulary returns the indexed terms that termCost could match to tok,
+// with their document counts. Only terms within edit range of tok's length
+// are read, plus longer terms sharing its first letter, which may be
+// prefixes or abbreviations of it.
+func (db *DB) vocabulary(ctx context.Context, tok string) (map[string]int, error) {
+	n := utf8.RuneCountInString(tok)
+	edits := maxEdits(n)
+	first, _ := utf8.DecodeRuneInString(tok)
+	rows, err := db.QueryContext(ctx, `
+		SELECT term, SUM(doc) FROM (
+			SELECT term, doc FROM entities_vocab
+			UNION ALL
+			SELECT term, doc FROM observations_vocab
+		)
+		WHERE length(term) BETWEEN ? AND ?
+		   OR (substr(term, 1, 1) = ? AND length(term) > ?)
+		GROUP BY term`, min(n-edits, 4), n+edits, string(first), n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vocab := map[string]int{}
+	for rows.Next() {
+		var term string
+		var docs int
+		if err := rows.Scan(&term, &docs); err != nil {
+			return nil, err
+		}
+		vocab[term] = docs
+	}
+	return vocab, rows.Err()
+}
+
+// closestTerms returns up to maxFuzzyExpansions vocabulary terms near tok,
+// cheapest first, breaking ties by document frequency.
+func closestTerms(tok string, vocab map[string]int) []fuzzyCandidate {
+	var cands []fuzzyCandidate
+	for term, docs := range vocab {
+		if cost, ok := termCost(tok, term); ok {
+			cands = append(cands, fuzzyCandidate{term: term, cost: cost, docs: docs})
+		}
+	}
+	sort.Slice(cands, func(i, j int) bool {
+		if cands[i].cost != cands[j].cost {
+			return cands[i].cost < cands[j].cost
+		}
+		if cands[i].docs != cands[j].docs {
+			return cands[i].docs > cands[j].docs
+		}
+		return cands[i].term < cands[j].term
+	})
+	if len(cands) > maxFuzzyExpansions {
+		cands = cands[:maxFuzzyExpansions]
+	}
+	return cands
+}
+
+// termCost scores how closely term matches the query token tok. Short tokens
+// allow no edits, tokens up to 5 letters one, longer tokens two. A token of
+// 3+ letters that starts term, or abbreviates it (same first letter, letters
+// in order, like "svc" for "service"), also matches.
+func termCost(tok, term string) (int, bool) {
+	if tok == term {
+		return 0, true
+	}
+	a, b := []rune(tok), []rune(term)
+	edits := maxEdits(len(a))
+	if edits > 0 {
+		// Terms are stems ("kubernet"), so also compare against the token
+		// cut to the stem's length, ignoring the suffix the stemmer dropped.
+		if abs(len(a)-len(b)) <= edits {
+			if d := levenshtein(a, b); d <= edits {
+				return d, true
+			}
+		}
+		if len(a) > len(b) && len(b) > 3 {
+			if d := levenshtein(a[:len(b)], b); d <= edits {
+				return max(d, 1), true
+			}
+		}
+	}
+	if len(a) >= 3 && strings.HasPrefix(term, tok) {
+		return 1, true
+	}
+	if len(a) >= 2 && len(b) > len(a) && a[0] == b[0] && isSubsequence(a, b) {
+		return 2, true
+	}
+	return 0, false
+}
+
+// maxEdits is how many edits termCost allows for a token of n letters.
+func maxEdits(n int) int {
+	switch {
+	case n > 5:
+		return 2
+	case n > 3:
+		return 1
+	}
+	return 0
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// isSubsequence reports whether every rune of a appears in b, in order.
+func isSubsequence(a, b []rune) bool {
+	i := 0
+	for _, r := range b {
+		if i < len(a) && a[i] == r {
+			i++
+		}
+	}
+	return i == len(a)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// surfaceForm maps an indexed (stemmed) term back to a word as it appears in
+// stored text, e.g. "kubernet" to "kubernetes". It falls back to the term.
+func (db *DB) surfaceForm(ctx context.Context, term string) (string, error) {
+	lookups := []struct{ table, match string }{
+		{"entities_fts", "name : " + ftsEscape(term)},
+		{"observations_fts", ftsEscape(term)},
+	}
+	for _, l := range lookups {
+		var marked string
+		err := db.QueryRowContext(ctx, `
+			SELECT highlight(`+l.table+`, 0, char(1), char(2))
+			FROM `+l.table+` WHERE `+l.table+` MATCH ? LIMIT 1`, l.match).Scan(&marked)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		start := strings.IndexByte(marked, 1)
+		end := strings.IndexByte(marked, 2)
+		if start >= 0 && end > start {
+			return strings.ToLower(marked[start+1 : end]), nil
+		}
+	}
+	return term, nil
+}
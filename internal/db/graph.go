@@ -0,0 +1,332 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Graph traversal limits. Depth and node caps keep a densely linked graph
+// from turning one call into a full-database scan.
+const (
+	DefaultGraphDepth    = 2
+	MaxGraphDepth        = 6
+	DefaultGraphMaxNodes = 100
+	MaxGraphNodes        = 500
+
+	// maxPathRows bounds the number of partial paths explored by ShortestPath.
+	maxPathRows = 20000
+)
+
+// GraphOptions controls neighborhood and path traversal.
+type GraphOptions struct {
+	Depth     int      // max hops from the root (default 2, max 6)
+	Relations []string // only follow these relation types; empty means all
+	Direction string   // out (follow from->to), in (to->from) or both (default)
+	MaxNodes  int      // node cap (default 100, max 500)
+}
+
+// GraphNode is an entity reached by a traversal, with its distance from the root.
+type GraphNode struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	EntityType string `json:"entity_type"`
+	Depth      int    `json:"depth"`
+}
+
+// Subgraph is the neighborhood of an entity.
+type Subgraph struct {
+	Root      string      `json:"root"`
+	Nodes     []GraphNode `json:"nodes"`
+	Edges     []Relation  `json:"edges"`
+	Truncated bool        `json:"truncated"`
+}
+
+// GraphPath is a chain of relations connecting two entities.
+type GraphPath struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []Relation  `json:"edges"`
+	Hops  int         `json:"hops"`
+}
+
+// normalize fills defaults and clamps limits.
+func (o GraphOptions) normalize() (GraphOptions, error) {
+	if o.Depth <= 0 {
+		o.Depth = DefaultGraphDepth
+	}
+	if o.Depth > MaxGraphDepth {
+		o.Depth = MaxGraphDepth
+	}
+	if o.MaxNodes <= 0 {
+		o.MaxNodes = DefaultGraphMaxNodes
+	}
+	if o.MaxNodes > MaxGraphNodes {
+		o.MaxNodes = MaxGraphNodes
+	}
+	switch o.Direction {
+	case "":
+		o.Direction = "both"
+	case "out", "in", "both":
+	default:
+		return o, fmt.Errorf("invalid direction %q: use out, in or both", o.Direction)
+	}
+	return o, nil
+}
+
+// edgesCTE returns a CTE body "edges(rid, src, dst)" listing traversable
+// steps for the given direction and relation filter, with its args.
+func edgesCTE(o GraphOptions) (string, []interface{}) {
+	filter := ""
+	var filterArgs []interface{}
+	if len(o.Relations) > 0 {
+		filter = " WHERE relation IN (" + placeholders(len(o.Relations)) + ")"
+		for _, r := range o.Relations {
+			filterArgs = append(filterArgs, r)
+		}
+	}
+
+	var parts []string
+	var args []interface{}
+	if o.Direction == "out" || o.Direction == "both" {
+		parts = append(parts, "SELECT id, from_id, to_id FROM relations"+filter)
+		args = append(args, filterArgs...)
+	}
+	if o.Direction == "in" || o.Direction == "both" {
+		parts = append(parts, "SELECT id, to_id, from_id FROM relations"+filter)
+		args = append(args, filterArgs...)
+	}
+	return "edges(rid, src, dst) AS (" + strings.Join(parts, " UNION ALL ") + ")", args
+}
+
+// activeEntityID resolves a name or alias to an active entity ID.
+func (db *DB) activeEntityID(ctx context.Context, name string) (int64, error) {
+	id, _, err := resolveEntity(ctx, db, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("entity %q not found", name)
+	}
+	return id, err
+}
+
+// Neighbors returns the subgraph within opts.Depth hops of the named entity.
+// The walk uses UNION over (entity, depth) pairs, so cycles cannot recurse
+// past the depth bound; soft-deleted entities are never traversed.
+func (db *DB) Neighbors(ctx context.Context, name string, opts GraphOptions) (*Subgraph, error) {
+	o, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+	rootID, err := db.activeEntityID(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	edges, args := edgesCTE(o)
+	query := `
+WITH RECURSIVE ` + edges + `,
+walk(id, depth) AS (
+    SELECT ?, 0
+    UNION
+    SELECT e.dst, w.depth + 1
+    FROM walk w
+    JOIN edges e ON e.src = w.id
+    JOIN entities n ON n.id = e.dst AND n.deleted_at IS NULL
+    WHERE w.depth < ?
+)
+SELECT n.id, n.name, n.entity_type, MIN(w.depth) AS d
+FROM walk w JOIN entities n ON n.id = w.id
+GROUP BY n.id
+ORDER BY d ASC, n.name ASC
+LIMIT ?`
+	args = append(args, rootID, o.Depth, o.MaxNodes+1)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("graph walk: %w", err)
+	}
+	g := &Subgraph{Root: name, Nodes: []GraphNode{}, Edges: []Relation{}}
+	for rows.Next() {
+		var n GraphNode
+		if err := rows.Scan(&n.ID, &n.Name, &n.EntityType, &n.Depth); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		g.Nodes = append(g.Nodes, n)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(g.Nodes) > o.MaxNodes {
+		g.Nodes = g.Nodes[:o.MaxNodes]
+		g.Truncated = true
+	}
+	if len(g.Nodes) > 0 {
+		g.Root = g.Nodes[0].Name
+	}
+
+	ids := make([]int64, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ids[i] = n.ID
+	}
+	g.Edges, err = db.RelationsAmong(ctx, ids, o.Relations)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// RelationsAmong returns relations whose endpoints are both in ids,
+// optionally restricted to the given relation types.
+func (db *DB) RelationsAmong(ctx context.Context, ids []int64, relations []string) ([]Relation, error) {
+	if len(ids) == 0 {
+		return []Relation{}, nil
+	}
+	query := `
+		SELECT r.id, r.from_id, fe.name, r.to_id, te.name, r.relation, r.created_at
+		FROM relations r
+		JOIN entities fe ON r.from_id = fe.id
+		JOIN entities te ON r.to_id = te.id
+		WHERE r.from_id IN (` + placeholders(len(ids)) + `)
+		  AND r.to_id IN (` + placeholders(len(ids)) + `)`
+	args := make([]interface{}, 0, 2*len(ids)+len(relations))
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	if len(relations) > 0 {
+		query += " AND r.relation IN (" + placeholders(len(relations)) + ")"
+		for _, r := range relations {
+			args = append(args, r)
+		}
+	}
+	query += " ORDER BY r.id"
+	return db.queryRelations(ctx, query, args...)
+}
+
+// queryRelations scans relation rows selected in ListRelationsByEntity column order.
+func (db *DB) queryRelations(ctx context.Context, query string, args ...interface{}) ([]Relation, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rels := []Relation{}
+	for rows.Next() {
+		var r Relation
+		if err := rows.Scan(&r.ID, &r.FromID, &r.FromName, &r.ToID, &r.ToName, &r.Relation, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rels = append(rels, r)
+	}
+	return rels, rows.Err()
+}
+
+// ErrPathSearchTruncated is returned by ShortestPath when it explored
+// maxPathRows partial paths without reaching the target, so it cannot tell
+// whether a path exists.
+var ErrPathSearchTruncated = errors.New("path search truncated")
+
+// ShortestPath finds a shortest chain of relations from one named entity to
+// another, up to opts.Depth hops. It returns nil if no path exists. Each
+// partial path carries its visited node list so it never revisits a node,
+// and the search is breadth-first with a hard cap on explored rows; hitting
+// the cap before reaching the target returns ErrPathSearchTruncated.
+func (db *DB) ShortestPath(ctx context.Context, fromName, toName string, opts GraphOptions) (*GraphPath, error) {
+	if opts.Depth <= 0 {
+		opts.Depth = MaxGraphDepth
+	}
+	o, err := opts.normalize()
+	if err != nil {
+		return nil, err
+	}
+	fromID, err := db.activeEntityID(ctx, fromName)
+	if err != nil {
+		return nil, err
+	}
+	toID, err := db.activeEntityID(ctx, toName)
+	if err != nil {
+		return nil, err
+	}
+
+	edges, args := edgesCTE(o)
+	query := `
+WITH RECURSIVE ` + edges + `,
+walk(id, depth, nodes, rels) AS (
+    SELECT ?, 0, ',' || ? || ',', ''
+    UNION ALL
+    SELECT e.dst, w.depth + 1, w.nodes || e.dst || ',', w.rels || e.rid || ','
+    FROM walk w
+    JOIN edges e ON e.src = w.id
+    JOIN entities n ON n.id = e.dst AND n.deleted_at IS NULL
+    WHERE w.depth < ? AND w.id != ? AND instr(w.nodes, ',' || e.dst || ',') = 0
+    ORDER BY 2
+    LIMIT ?
+)
+SELECT p.nodes, p.rels, c.explored
+FROM (SELECT COUNT(*) AS explored FROM walk) c
+LEFT JOIN (SELECT nodes, rels FROM walk WHERE id = ? ORDER BY depth ASC LIMIT 1) p`
+	args = append(args, fromID, fromID, o.Depth, toID, maxPathRows, toID)
+
+	// Breadth-first order means a path found before the cap is still a
+	// shortest one; only a miss at the cap is inconclusive.
+	var nodeList, relList sql.NullString
+	var explored int
+	if err := db.QueryRowContext(ctx, query, args...).Scan(&nodeList, &relList, &explored); err != nil {
+		return nil, fmt.Errorf("shortest path: %w", err)
+	}
+	if !nodeList.Valid {
+		if explored >= maxPathRows {
+			return nil, fmt.Errorf("%w: gave up after %d partial paths from %q without reaching %q; lower depth or filter relations",
+				ErrPathSearchTruncated, maxPathRows, fromName, toName)
+		}
+		return nil, nil
+	}
+
+	nodeIDs := splitIDs(nodeList.String)
+	relIDs := splitIDs(relList.String)
+	path := &GraphPath{Hops: len(relIDs)}
+
+	for i, id := range nodeIDs {
+		e, err := db.GetEntityByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, fmt.Errorf("entity %d vanished during traversal", id)
+		}
+		path.Nodes = append(path.Nodes, GraphNode{ID: e.ID, Name: e.Name, EntityType: e.EntityType, Depth: i})
+	}
+	for _, id := range relIDs {
+		rels, err := db.queryRelations(ctx, `
+			SELECT r.id, r.from_id, fe.name, r.to_id, te.name, r.relation, r.created_at
+			FROM relations r
+			JOIN entities fe ON r.from_id = fe.id
+			JOIN entities te ON r.to_id = te.id
+			WHERE r.id = ?`, id)
+		if err != nil {
+			return nil, err
+		}
+		path.Edges = append(path.Edges, rels...)
+	}
+	return path, nil
+}
+
+// splitIDs parses a comma-separated ID list like ",1,2,".
+func splitIDs(s string) []int64 {
+	var ids []int64
+	for _, part := range strings.Split(s, ",") {
+		if part == "" {
+			continue
+		}
+		if id, err := strconv.ParseInt(part, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -30,13 +30,22 @@ func (db *DB) AppendJournal(ctx context.Context, content string, tags []string)
 		return nil, err
 	}
 
-	res, err := db.ExecContext(ctx, `
-		INSERT INTO journal (content, tags) VALUES (?, ?)
-	`, content, string(tagsJSON))
+	var id int64
+	m := &mutation{op: "journal.add"}
+	err = db.mutate(ctx, m, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO journal (content, tags) VALUES (?, ?)
+		`, content, string(tagsJSON))
+		if err != nil {
+			return err
+		}
+		id, _ = res.LastInsertId()
+		m.scope.add("journal", "id = ?", id)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	id, _ := res.LastInsertId()
 
 	var entry JournalEntry
 	err = db.QueryRowContext(ctx, `SELECT id, content, tags, created_at FROM journal WHERE id = ?`, id).
@@ -50,7 +59,7 @@ func (db *DB) AppendJournal(ctx context.Context, content string, tags []string)
 	return &entry, nil
 }
 
-// ParseSince parses a duration string like "2h", "24h", "7d", or ISO date "2026-02-17".
+// ParseSince parses a duration string like "10m", "2h", "7d", or ISO date "2026-02-17".
 // Returns the Unix millisecond timestamp for the start of the window.
 func ParseSince(s string) (int64, error) {
 	s = strings.TrimSpace(s)
@@ -77,59 +86,168 @@ func ParseSince(s string) (int64, error) {
 			return time.Now().Add(-time.Duration(hours) * time.Hour).UnixMilli(), nil
 		}
 	}
+	// Any other Go duration: 10m, 90s, 1h30m
+	if d, err := time.ParseDuration(s); err == nil && d > 0 {
+		return time.Now().Add(-d).UnixMilli(), nil
+	}
 
-	return 0, fmt.Errorf("cannot parse since %q: use formats like '2h', '24h', '7d', or '2026-02-17'", s)
+	return 0, fmt.Errorf("cannot parse since %q: use formats like '10m', '2h', '7d', or '2026-02-17'", s)
 }
 
 // ListJournal returns journal entries optionally filtered by a time window.
 // sinceStr is a duration like "24h", "7d", ISO date, or empty for all.
 func (db *DB) ListJournal(ctx context.Context, sinceStr string, limit int) ([]JournalEntry, error) {
+	entries, _, err := db.ListJournalPage(ctx, sinceStr, limit, "")
+	return entries, err
+}
+
+// ListJournalPage is ListJournal with cursor pagination; see SearchPage.
+func (db *DB) ListJournalPage(ctx context.Context, sinceStr string, limit int, cursor string) ([]JournalEntry, string, error) {
 	if limit <= 0 {
 		limit = 50
 	}
+	pg, err := newPage(limit, cursor, "journal", sinceStr)
+	if err != nil {
+		return nil, "", err
+	}
 
-	query := `SELECT id, content, tags, created_at FROM journal`
+	query := `SELECT j.id, j.content, j.tags, j.created_at FROM journal j WHERE 1 = 1`
 	args := []interface{}{}
 
 	if sinceStr != "" {
+		// A cursor keeps the window of the first page, so "24h" does not
+		// slide forward while paging.
 		sinceMs, err := ParseSince(sinceStr)
 		if err != nil {
-			return nil, err
+			return nil, "", err
+		}
+		if pg.after != nil {
+			sinceMs = pg.after.Since
 		}
-		query += " WHERE created_at >= ?"
+		query += " AND j.created_at >= ?"
 		args = append(args, sinceMs)
+		pg.since = sinceMs
 	}
 
-	query += " ORDER BY created_at DESC LIMIT ?"
-	args = append(args, limit)
+	return db.journalPage(ctx, query, args, pg)
+}
 
-	rows, err := db.QueryContext(ctx, query, args...)
+// JournalDay returns the journal entries written on day, a local date like
+// "2026-02-17", oldest first.
+func (db *DB) JournalDay(ctx context.Context, day string) ([]JournalEntry, error) {
+	start, err := time.ParseInLocation("2006-01-02", day, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q: use YYYY-MM-DD", day)
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, content, tags, created_at FROM journal
+		WHERE created_at >= ? AND created_at < ?
+		ORDER BY created_at, id
+	`, start.UnixMilli(), start.AddDate(0, 0, 1).UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("journal query: %w", err)
+	}
+	defer rows.Close()
+	return scanJournalRows(rows)
+}
+
+// JournalDays returns the local dates with journal entries, newest first,
+// at most limit of them.
+func (db *DB) JournalDays(ctx context.Context, limit int) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `SELECT created_at FROM journal ORDER BY created_at DESC`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	return scanJournalRows(rows)
+	var days []string
+	for rows.Next() && len(days) < limit {
+		var ms int64
+		if err := rows.Scan(&ms); err != nil {
+			return nil, err
+		}
+		// Dates are computed in Go so they match JournalDay's time zone.
+		if day := time.UnixMilli(ms).Format("2006-01-02"); len(days) == 0 || days[len(days)-1] != day {
+			days = append(days, day)
+		}
+	}
+	return days, rows.Err()
 }
 
-// SearchJournal performs FTS5 full-text search on journal content.
+// SearchJournal performs FTS5 full-text search on journal content using the
+// ParseQuery syntax. tag: filters match entry tags; name: and type: filters
+// select entities, so no journal entries match them.
 func (db *DB) SearchJournal(ctx context.Context, query string, limit int) ([]JournalEntry, error) {
+	entries, _, err := db.SearchJournalPage(ctx, query, limit, "")
+	return entries, err
+}
+
+// SearchJournalPage is SearchJournal with cursor pagination; see SearchPage.
+func (db *DB) SearchJournalPage(ctx context.Context, query string, limit int, cursor string) ([]JournalEntry, string, error) {
 	if limit <= 0 {
 		limit = 10
 	}
-	escaped := ftsEscape(query)
-	rows, err := db.QueryContext(ctx, `
+	pq, err := ParseQuery(query)
+	if err != nil {
+		return nil, "", err
+	}
+	pg, err := newPage(limit, cursor, "journal-search", query)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sqlQuery := `
 		SELECT j.id, j.content, j.tags, j.created_at
 		FROM journal j
-		WHERE j.id IN (SELECT rowid FROM journal_fts WHERE journal_fts MATCH ?)
-		ORDER BY j.created_at DESC
-		LIMIT ?
-	`, escaped, limit)
+		WHERE 1 = 1`
+	var args []interface{}
+	if pq.Match != "" {
+		sqlQuery += " AND j.id IN (SELECT rowid FROM journal_fts WHERE journal_fts MATCH ?)"
+		args = append(args, pq.Match)
+	}
+	for _, f := range pq.Filters {
+		// Journal entries only have tags; name and type filters select entities.
+		if f.Field != FieldTag {
+			return nil, "", nil
+		}
+		cond, condArgs := likeOrEqual("value", f, nil)
+		cond = "EXISTS (SELECT 1 FROM json_each(j.tags) WHERE " + cond + ")"
+		if f.Negate {
+			cond = "NOT " + cond
+		}
+		sqlQuery += " AND " + cond
+		args = append(args, condArgs...)
+	}
+	return db.journalPage(ctx, sqlQuery, args, pg)
+}
+
+// journalPage runs a journal query, which ends inside its WHERE clause, for
+// one page of entries, newest first.
+func (db *DB) journalPage(ctx context.Context, query string, args []interface{}, pg page) ([]JournalEntry, string, error) {
+	if c := pg.after; c != nil {
+		query += " AND (j.created_at, j.id) < (?, ?)"
+		args = append(args, c.Num, c.ID)
+	}
+	query += " ORDER BY j.created_at DESC, j.id DESC LIMIT ?"
+	args = append(args, pg.limit+1)
+
+	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("journal search: %w", err)
+		return nil, "", fmt.Errorf("journal query: %w", err)
 	}
 	defer rows.Close()
-	return scanJournalRows(rows)
+
+	entries, err := scanJournalRows(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	var next string
+	if len(entries) > pg.limit {
+		entries = entries[:pg.limit]
+		last := entries[len(entries)-1]
+		next = pg.next(pageCursor{Num: last.CreatedAt, Since: pg.since, ID: last.ID})
+	}
+	return entries, next, nil
 }
 
 func scanJournalRows(rows *sql.Rows) ([]JournalEntry, error) {
@@ -0,0 +1,242 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RenameEntity gives the entity called oldName (a name or alias) a new name,
+// keeping its ID, observations and relations. The old name is kept as an
+// alias so existing references still resolve. newName must not belong to
+// another entity; merge into that entity instead.
+func (db *DB) RenameEntity(ctx context.Context, oldName, newName string) (*Entity, error) {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return nil, fmt.Errorf("entity name cannot be empty")
+	}
+	if len(newName) > 1024 {
+		return nil, fmt.Errorf("entity name exceeds 1KB limit")
+	}
+	id, current, err := resolveEntity(ctx, db, oldName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("entity %q not found", oldName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if current == newName {
+		return db.GetEntityByID(ctx, id)
+	}
+
+	m := &mutation{op: "entity.rename", entityID: id,
+		scope: scope{}.add("entities", "id = ?", id).add("entity_aliases", "entity_id = ?", id)}
+	err = db.mutate(ctx, m, func(tx *sql.Tx) error {
+		var other int64
+		var otherName string
+		var otherDeleted sql.NullInt64
+		err := tx.QueryRowContext(ctx, `
+			SELECT id, name, deleted_at FROM entities WHERE lower(name) = lower(?) AND id != ?
+		`, newName, id).Scan(&other, &otherName, &otherDeleted)
+		switch {
+		case err == nil && otherDeleted.Valid:
+			return fmt.Errorf("a deleted entity is still called %q; delete it permanently first", otherName)
+		case err == nil:
+			return fmt.Errorf("entity %q already exists; merge into it instead", otherName)
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+		err = tx.QueryRowContext(ctx, `
+			SELECT e.name FROM entity_aliases a JOIN entities e ON e.id = a.entity_id
+			WHERE a.alias = ? AND a.entity_id != ?
+		`, newName, id).Scan(&otherName)
+		if err == nil {
+			return fmt.Errorf("%q is an alias of entity %q", newName, otherName)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// The new name may have been one of the entity's own aliases.
+		if _, err := tx.ExecContext(ctx, `DELETE FROM entity_aliases WHERE alias = ?`, newName); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE entities SET name = ?, updated_at = unixepoch('now', 'subsec') * 1000 WHERE id = ?
+		`, newName, id)
+		if err != nil {
+			return fmt.Errorf("rename entity: %w", err)
+		}
+		if !strings.EqualFold(current, newName) {
+			return insertAlias(ctx, tx, id, current)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.GetEntityByID(ctx, id)
+}
+
+// MergeResult reports what MergeEntities moved into the surviving entity.
+type MergeResult struct {
+	Entity              string `json:"entity"`               // surviving entity
+	Merged              string `json:"merged"`               // entity merged into it, now an alias
+	Observations        int64  `json:"observations"`         // observations moved
+	DroppedObservations int64  `json:"dropped_observations"` // already present on the survivor
+	Relations           int64  `json:"relations"`            // relations re-pointed
+	DroppedRelations    int64  `json:"dropped_relations"`    // would have become self-loops or duplicates
+	Aliases             int64  `json:"aliases"`              // aliases moved
+}
+
+// MergeEntities folds the entity called sourceName into targetName (either
+// may be a name or alias) in one transaction, then deletes the source:
+//
+//   - observations move across; ones the target already has are dropped
+//   - relations are re-pointed in both directions; edges that would become
+//     self-loops or duplicate an existing edge are dropped
+//   - tags are unioned, access counts summed, and tasks and aliases moved
+//   - the source's name becomes an alias of the target
+func (db *DB) MergeEntities(ctx context.Context, sourceName, targetName string) (MergeResult, error) {
+	var res MergeResult
+	src, srcName, err := resolveEntity(ctx, db, sourceName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return res, fmt.Errorf("entity %q not found", sourceName)
+	}
+	if err != nil {
+		return res, err
+	}
+	dst, dstName, err := resolveEntity(ctx, db, targetName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return res, fmt.Errorf("entity %q not found", targetName)
+	}
+	if err != nil {
+		return res, err
+	}
+	if src == dst {
+		return res, fmt.Errorf("%q and %q are the same entity", sourceName, targetName)
+	}
+	res.Entity, res.Merged = dstName, srcName
+
+	m := &mutation{op: "entity.merge", entityID: dst, entity: dstName,
+		scope: entityScope("id IN (?, ?)", src, dst)}
+	err = db.mutate(ctx, m, func(tx *sql.Tx) error {
+		return mergeEntities(ctx, tx, &res, src, dst)
+	})
+	return res, err
+}
+
+// mergeEntities does the work of MergeEntities inside its transaction.
+func mergeEntities(ctx context.Context, tx *sql.Tx, res *MergeResult, src, dst int64) error {
+	var err error
+	exec := func(query string, args ...interface{}) (int64, error) {
+		r, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err
+		}
+		return r.RowsAffected()
+	}
+
+	// Observations: UPDATE OR IGNORE skips rows that would violate
+	// UNIQUE(entity_id, content); those are the duplicates left behind.
+	if res.Observations, err = exec(`UPDATE OR IGNORE observations SET entity_id = ? WHERE entity_id = ?`, dst, src); err != nil {
+		return fmt.Errorf("move observations: %w", err)
+	}
+	if res.DroppedObservations, err = exec(`DELETE FROM observations WHERE entity_id = ?`, src); err != nil {
+		return err
+	}
+
+	// Relations: edges between the two entities would become self-loops.
+	selfLoops, err := exec(`
+		DELETE FROM relations
+		WHERE (from_id = ? AND to_id IN (?, ?)) OR (from_id = ? AND to_id = ?)
+	`, src, src, dst, dst, src)
+	if err != nil {
+		return err
+	}
+	from, err := exec(`UPDATE OR IGNORE relations SET from_id = ? WHERE from_id = ?`, dst, src)
+	if err != nil {
+		return fmt.Errorf("move relations: %w", err)
+	}
+	to, err := exec(`UPDATE OR IGNORE relations SET to_id = ? WHERE to_id = ?`, dst, src)
+	if err != nil {
+		return fmt.Errorf("move relations: %w", err)
+	}
+	dupes, err := exec(`DELETE FROM relations WHERE from_id = ? OR to_id = ?`, src, src)
+	if err != nil {
+		return err
+	}
+	res.Relations, res.DroppedRelations = from+to, selfLoops+dupes
+
+	if _, err := exec(`UPDATE tasks SET entity_id = ? WHERE entity_id = ?`, dst, src); err != nil {
+		return err
+	}
+	if res.Aliases, err = exec(`UPDATE entity_aliases SET entity_id = ? WHERE entity_id = ?`, dst, src); err != nil {
+		return err
+	}
+
+	tags, err := unionTags(ctx, tx, dst, src)
+	if err != nil {
+		return err
+	}
+	_, err = exec(`
+		UPDATE entities SET
+			tags = ?,
+			access_count = entities.access_count + s.access_count,
+			last_accessed = NULLIF(MAX(COALESCE(entities.last_accessed, 0), COALESCE(s.last_accessed, 0)), 0),
+			created_at = MIN(entities.created_at, s.created_at),
+			updated_at = ?
+		FROM (SELECT access_count, last_accessed, created_at FROM entities WHERE id = ?) AS s
+		WHERE entities.id = ?
+	`, tags, time.Now().UnixMilli(), src, dst)
+	if err != nil {
+		return fmt.Errorf("update %q: %w", res.Entity, err)
+	}
+	if _, err := exec(`DELETE FROM entities WHERE id = ?`, src); err != nil {
+		return fmt.Errorf("delete %q: %w", res.Merged, err)
+	}
+	return insertAlias(ctx, tx, dst, res.Merged)
+}
+
+// unionTags returns the JSON tag list of entity a followed by the tags of b
+// that a lacks.
+func unionTags(ctx context.Context, tx *sql.Tx, a, b int64) (string, error) {
+	var union []string
+	seen := map[string]bool{}
+	for _, id := range []int64{a, b} {
+		var tagsJSON string
+		if err := tx.QueryRowContext(ctx, `SELECT tags FROM entities WHERE id = ?`, id).Scan(&tagsJSON); err != nil {
+			return "", err
+		}
+		var tags []string
+		_ = json.Unmarshal([]byte(tagsJSON), &tags)
+		for _, t := range tags {
+			if !seen[t] {
+				seen[t] = true
+				union = append(union, t)
+			}
+		}
+	}
+	if union == nil {
+		union = []string{}
+	}
+	out, err := json.Marshal(union)
+	return string(out), err
+}
+
+// insertAlias records alias for an entity inside a rename or merge. An alias
+// held by another entity is left alone.
+func insertAlias(ctx context.Context, tx *sql.Tx, entityID int64, alias string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO entity_aliases (entity_id, alias) VALUES (?, ?)
+		ON CONFLICT(alias) DO NOTHING
+	`, entityID, alias)
+	if err != nil {
+		return fmt.Errorf("add alias %q: %w", alias, err)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Migration is one numbered, forward step of the schema.
+// Up and Down are executed inside a single transaction. An empty Down marks
+// the migration as irreversible.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// migrations lists every schema migration in order. Versions must be
+// contiguous starting at 1; append new entries, never edit applied ones.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline schema",
+		// Schema uses IF NOT EXISTS throughout, so databases created before
+		// versioning existed are adopted as version 1 without changes. The
+		// journal_fts rebuild indexes journal rows written before journal_fts existed.
+		Up: Schema + `INSERT INTO journal_fts(journal_fts) VALUES('rebuild');`,
+	},
+	{
+		Version: 2,
+		Name:    "tasks",
+		Up:      tasksSchema,
+		Down:    `DROP INDEX IF EXISTS idx_tasks_open; DROP TABLE IF EXISTS tasks;`,
+	},
+	{
+		Version: 3,
+		Name:    "fuzzy search",
+		Up:      fuzzySchema,
+		Down: `
+DROP TRIGGER IF EXISTS entities_trigram_insert;
+DROP TRIGGER IF EXISTS entities_trigram_delete;
+DROP TRIGGER IF EXISTS entities_trigram_update;
+DROP TRIGGER IF EXISTS entities_trigram_soft_delete;
+DROP TRIGGER IF EXISTS entities_trigram_soft_restore;
+DROP TRIGGER IF EXISTS observations_trigram_insert;
+DROP TRIGGER IF EXISTS observations_trigram_delete;
+DROP TABLE IF EXISTS entities_trigram;
+DROP TABLE IF EXISTS observations_trigram;
+DROP TABLE IF EXISTS entities_vocab;
+DROP TABLE IF EXISTS observations_vocab;`,
+	},
+	{
+		Version: 4,
+		Name:    "embeddings",
+		Up:      embeddingsSchema,
+		Down:    `DROP INDEX IF EXISTS idx_observation_embeddings_model; DROP TABLE IF EXISTS observation_embeddings;`,
+	},
+	{
+		Version: 5,
+		Name:    "entity aliases",
+		Up:      aliasesSchema,
+		Down: `
+DROP TRIGGER IF EXISTS entity_aliases_fts_insert;
+DROP TRIGGER IF EXISTS entity_aliases_fts_delete;
+DROP TABLE IF EXISTS entity_aliases_fts;
+DROP INDEX IF EXISTS idx_entity_aliases_entity;
+DROP TABLE IF EXISTS entity_aliases;`,
+	},
+	{
+		Version: 6,
+		Name:    "trash",
+		Up:      trashSchema,
+		Down: `
+DROP INDEX IF EXISTS idx_entities_deleted;
+DROP TRIGGER IF EXISTS entities_fts_delete;
+CREATE TRIGGER entities_fts_delete AFTER DELETE ON entities BEGIN
+    INSERT INTO entities_fts(entities_fts, rowid, name, entity_type) VALUES('delete', old.id, old.name, old.entity_type);
+END;
+DROP TRIGGER IF EXISTS entities_trigram_delete;
+CREATE TRIGGER entities_trigram_delete AFTER DELETE ON entities BEGIN
+    INSERT INTO entities_trigram(entities_trigram, rowid, name, entity_type) VALUES('delete', old.id, old.name, old.entity_type);
+END;`,
+	},
+	{
+		Version: 7,
+		Name:    "change log",
+		Up:      changesSchema,
+		Down:    `DROP INDEX IF EXISTS idx_changes_entity; DROP INDEX IF EXISTS idx_changes_created; DROP TABLE IF EXISTS changes;`,
+	},
+	{
+		Version: 8,
+		Name:    "undo",
+		Up:      undoSchema,
+		Down:    `DROP INDEX IF EXISTS idx_changes_undoes; ALTER TABLE changes DROP COLUMN undoes;`,
+	},
+	{
+		Version: 9,
+		Name:    "api tokens",
+		Up:      tokensSchema,
+		Down:    `DROP TABLE IF EXISTS tokens;`,
+	},
+	{
+		Version: 10,
+		Name:    "change log entity index",
+		Up:      changesEntitySchema,
+		Down:    `DROP INDEX IF EXISTS idx_changes_entity_name;`,
+	},
+}
+
+// ErrSchemaTooNew is returned when a database was migrated by a newer aimemo binary.
+var ErrSchemaTooNew = errors.New("database schema is newer than this aimemo binary supports")
+
+// schemaVersionTable records each applied migration.
+const schemaVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+    version    INTEGER PRIMARY KEY,
+    name       TEXT    NOT NULL,
+    applied_at INTEGER NOT NULL DEFAULT (unixepoch('now', 'subsec') * 1000)
+);
+`
+
+// MigrationStatus describes whether a known migration has been applied.
+type MigrationStatus struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt *int64 `json:"applied_at,omitempty"`
+}
+
+// LatestSchemaVersion returns the highest migration version known to this binary.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the current schema version (0 for a new database).
+func (db *DB) SchemaVersion(ctx context.Context) (int, error) {
+	if _, err := db.ExecContext(ctx, schemaVersionTable); err != nil {
+		return 0, err
+	}
+	var v int
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&v)
+	return v, err
+}
+
+// MigrationStatus lists all migrations known to this binary and whether each is applied.
+func (db *DB) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	if _, err := db.ExecContext(ctx, schemaVersionTable); err != nil {
+		return nil, err
+	}
+	applied := map[int]int64{}
+	rows, err := db.QueryContext(ctx, `SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var v int
+		var at int64
+		if err := rows.Scan(&v, &at); err != nil {
+			return nil, err
+		}
+		applied[v] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		st := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = &at
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// Migrate applies all pending migrations.
+func (db *DB) Migrate(ctx context.Context) error {
+	return db.MigrateTo(ctx, LatestSchemaVersion())
+}
+
+// MigrateTo moves the schema up or down to the given version, one migration
+// per transaction. It refuses to touch databases newer than this binary.
+func (db *DB) MigrateTo(ctx context.Context, target int) error {
+	latest := LatestSchemaVersion()
+	if target < 0 || target > latest {
+		return fmt.Errorf("target version %d out of range 0..%d", target, latest)
+	}
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if current > latest {
+		return fmt.Errorf("%w (database version %d, supported %d): upgrade aimemo", ErrSchemaTooNew, current, latest)
+	}
+
+	for current < target {
+		m := migrations[current] // versions are contiguous from 1
+		if err := db.applyMigration(ctx, m, true); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		current = m.Version
+	}
+	for current > target {
+		m := migrations[current-1]
+		if m.Down == "" {
+			return fmt.Errorf("migration %d (%s) is not reversible", m.Version, m.Name)
+		}
+		if err := db.applyMigration(ctx, m, false); err != nil {
+			return fmt.Errorf("revert migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		current = m.Version - 1
+	}
+	return nil
+}
+
+// applyMigration runs one migration's Up (or Down) and records the result atomically.
+// The schema_version row is written first so that the transaction takes the
+// write lock up front; if another process already applied (or reverted) the
+// same migration, the row change affects nothing and the step is skipped.
+func (db *DB) applyMigration(ctx context.Context, m Migration, up bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var res sql.Result
+	if up {
+		res, err = tx.ExecContext(ctx, `INSERT OR IGNORE INTO schema_version (version, name) VALUES (?, ?)`, m.Version, m.Name)
+	} else {
+		res, err = tx.ExecContext(ctx, `DELETE FROM schema_version WHERE version = ?`, m.Version)
+	}
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+
+	stmts := m.Up
+	if !up {
+		stmts = m.Down
+	}
+	if _, err := tx.ExecContext(ctx, stmts); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -2,15 +2,31 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 )
 
-// AddObservation adds an observation to an entity, deduplicating via UNIQUE constraint.
+// AddObservation adds an observation to an entity, deduplicating via UNIQUE
+// constraint, and embeds it for semantic search.
 func (db *DB) AddObservation(ctx context.Context, entityID int64, content string) error {
+	m := &mutation{op: "observation.add", entityID: entityID,
+		scope: scope{}.add("observations", "entity_id = ? AND content = ?", entityID, content)}
+	err := db.mutate(ctx, m, func(tx *sql.Tx) error {
+		return addObservation(ctx, tx, entityID, content)
+	})
+	if err != nil {
+		return err
+	}
+	db.embedEntity(ctx, entityID)
+	return nil
+}
+
+// addObservation is AddObservation without the change log entry.
+func addObservation(ctx context.Context, q querier, entityID int64, content string) error {
 	if len(content) > 10*1024 {
 		return fmt.Errorf("observation content exceeds 10KB limit")
 	}
-	_, err := db.ExecContext(ctx, `
+	_, err := q.ExecContext(ctx, `
 		INSERT INTO observations (entity_id, content)
 		VALUES (?, ?)
 		ON CONFLICT(entity_id, content) DO NOTHING
@@ -21,7 +37,7 @@ func (db *DB) AddObservation(ctx context.Context, entityID int64, content string
 // ListObservationsByEntityID returns all observation contents for an entity.
 func (db *DB) ListObservationsByEntityID(ctx context.Context, entityID int64) ([]string, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT content FROM observations WHERE entity_id = ? ORDER BY created_at ASC
+		SELECT content FROM observations WHERE entity_id = ? ORDER BY created_at ASC, id ASC
 	`, entityID)
 	if err != nil {
 		return nil, err
@@ -39,28 +55,32 @@ func (db *DB) ListObservationsByEntityID(ctx context.Context, entityID int64) ([
 	return obs, rows.Err()
 }
 
-// RetractObservation removes a specific observation from an entity by exact content match.
-// Returns remaining observations after deletion.
+// RetractObservation removes a specific observation from an entity (by name
+// or alias) by exact content match. Returns remaining observations after deletion.
 func (db *DB) RetractObservation(ctx context.Context, entityName, content string) ([]string, error) {
-	// Get entity id
-	var entityID int64
-	err := db.QueryRowContext(ctx, `
-		SELECT id FROM entities WHERE lower(name) = lower(?) AND deleted_at IS NULL
-	`, entityName).Scan(&entityID)
+	entityID, _, err := resolveEntity(ctx, db, entityName)
 	if err != nil {
 		return nil, fmt.Errorf("entity %q not found", entityName)
 	}
 
-	res, err := db.ExecContext(ctx, `
-		DELETE FROM observations WHERE entity_id = ? AND content = ?
-	`, entityID, content)
+	m := &mutation{op: "observation.retract", entityID: entityID,
+		scope: scope{}.add("observations", "entity_id = ? AND content = ?", entityID, content)}
+	err = db.mutate(ctx, m, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			DELETE FROM observations WHERE entity_id = ? AND content = ?
+		`, entityID, content)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		if n == 0 {
+			return fmt.Errorf("observation not found in entity %q", entityName)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
-		return nil, fmt.Errorf("observation not found in entity %q", entityName)
-	}
 
 	return db.ListObservationsByEntityID(ctx, entityID)
 }
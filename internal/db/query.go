@@ -0,0 +1,610 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Search query syntax, compiled by ParseQuery:
+//
+//	redis cache          both words (implicit AND)
+//	redis OR memcached   either word
+//	jwt NOT refresh      first without the second
+//	"connection pool"    exact phrase
+//	auth*                prefix
+//	NEAR(redis pool, 5)  words within 5 tokens of each other (default 10)
+//	(a OR b) c           grouping
+//	name:auth*           entity name matches (word, phrase or prefix)
+//	type:decision        entity type equals (prefix allowed)
+//	tag:infra            entity has tag (prefix allowed)
+//
+// Operators must be upper case; lower-case "and", "or", "not" are words.
+// Field filters combine with the rest of the query by AND at the top level
+// and may be negated with NOT.
+
+// Field filter names accepted by ParseQuery.
+const (
+	FieldName = "name"
+	FieldType = "type"
+	FieldTag  = "tag"
+)
+
+// QuerySyntaxError reports malformed search input.
+type QuerySyntaxError struct {
+	Pos int // byte offset into the query
+	Msg string
+}
+
+func (e *QuerySyntaxError) Error() string {
+	return fmt.Sprintf("invalid search query at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// QueryFilter restricts results by an entity field.
+type QueryFilter struct {
+	Field  string // name, type or tag
+	Value  string
+	Prefix bool
+	Negate bool
+}
+
+// ParsedQuery is a search query compiled for FTS5.
+type ParsedQuery struct {
+	Match   string        // FTS5 MATCH expression; empty if the query only has filters
+	Filters []QueryFilter // SQL filters on entity fields
+	Terms   []string      // positive words and phrases, used for fuzzy fallback
+}
+
+// ParseQuery compiles user search syntax into an FTS5 MATCH expression and
+// entity filters. Every word is emitted quoted, so FTS5 never sees raw user
+// punctuation.
+func ParseQuery(q string) (*ParsedQuery, error) {
+	toks, err := lexQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{toks: toks, end: len(q)}
+	if len(toks) == 0 {
+		return &ParsedQuery{}, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		if t.kind == tokRParen {
+			return nil, p.errAt(t, "unmatched ')'")
+		}
+		return nil, p.errAt(t, fmt.Sprintf("unexpected %s", t.describe()))
+	}
+
+	pq := &ParsedQuery{}
+	root, err = extractFilters(root, pq)
+	if err != nil {
+		return nil, err
+	}
+	if root != nil {
+		if n := root.findLeadingNot(); n != nil {
+			return nil, &QuerySyntaxError{Pos: n.pos, Msg: "NOT needs a search term before it, e.g. jwt NOT refresh"}
+		}
+		pq.Match = root.fts()
+		root.collectTerms(&pq.Terms)
+	}
+	return pq, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokPhrase
+	tokField // "name:", "type:" or "tag:"
+	tokLParen
+	tokRParen
+	tokComma
+	tokAnd
+	tokOr
+	tokNot
+	tokNear
+)
+
+type queryToken struct {
+	kind   tokKind
+	text   string
+	prefix bool // word or phrase followed by '*'
+	pos    int
+}
+
+func (t queryToken) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokWord:
+		return fmt.Sprintf("word %q", t.text)
+	case tokPhrase:
+		return fmt.Sprintf("phrase %q", t.text)
+	case tokField:
+		return fmt.Sprintf("field %q", t.text+":")
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokComma:
+		return "','"
+	}
+	return t.text
+}
+
+// isWordRune reports whether r may appear in a bare word.
+func isWordRune(r rune) bool {
+	return !unicode.IsSpace(r) && !strings.ContainsRune(`()",*`, r)
+}
+
+func lexQuery(q string) ([]queryToken, error) {
+	var toks []queryToken
+	i := 0
+	for i < len(q) {
+		r, size := utf8.DecodeRuneInString(q[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			toks = append(toks, queryToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case r == ')':
+			toks = append(toks, queryToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case r == ',':
+			toks = append(toks, queryToken{kind: tokComma, text: ",", pos: i})
+			i++
+		case r == '*':
+			return nil, &QuerySyntaxError{Pos: i, Msg: "'*' must directly follow a word or phrase, e.g. auth*"}
+		case r == '"':
+			start := i
+			end := strings.IndexByte(q[i+1:], '"')
+			if end < 0 {
+				return nil, &QuerySyntaxError{Pos: start, Msg: "unterminated quoted phrase"}
+			}
+			text := q[i+1 : i+1+end]
+			i += end + 2
+			if strings.TrimSpace(text) == "" {
+				return nil, &QuerySyntaxError{Pos: start, Msg: "empty quoted phrase"}
+			}
+			t := queryToken{kind: tokPhrase, text: text, pos: start}
+			if i < len(q) && q[i] == '*' {
+				t.prefix = true
+				i++
+			}
+			toks = append(toks, t)
+		default:
+			start := i
+			for i < len(q) {
+				r, size := utf8.DecodeRuneInString(q[i:])
+				if !isWordRune(r) {
+					break
+				}
+				i += size
+			}
+			if i == start {
+				return nil, &QuerySyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected %q", r)}
+			}
+			word := q[start:i]
+			if field, rest, ok := strings.Cut(word, ":"); ok {
+				switch strings.ToLower(field) {
+				case FieldName, FieldType, FieldTag:
+					toks = append(toks, queryToken{kind: tokField, text: strings.ToLower(field), pos: start})
+					if rest == "" {
+						continue // value follows as a phrase, or is missing
+					}
+					start += len(field) + 1
+					word = rest
+				}
+			}
+			t := queryToken{kind: tokWord, text: word, pos: start}
+			switch word {
+			case "AND":
+				t.kind = tokAnd
+			case "OR":
+				t.kind = tokOr
+			case "NOT":
+				t.kind = tokNot
+			case "NEAR":
+				t.kind = tokNear
+			}
+			if i < len(q) && q[i] == '*' {
+				if t.kind != tokWord {
+					return nil, &QuerySyntaxError{Pos: i, Msg: fmt.Sprintf("'*' cannot follow %s", word)}
+				}
+				t.prefix = true
+				i++
+			}
+			toks = append(toks, t)
+		}
+	}
+	return toks, nil
+}
+
+type nodeKind int
+
+const (
+	nodeTerm nodeKind = iota
+	nodePhrase
+	nodeNear
+	nodeAnd
+	nodeOr
+	nodeNot    // left NOT right; left is nil for a leading NOT
+	nodeFilter // field filter, only valid at the top level
+)
+
+type queryNode struct {
+	kind     nodeKind
+	text     string
+	prefix   bool
+	kids     []*queryNode // and, or, near
+	left     *queryNode   // not
+	right    *queryNode   // not
+	distance int          // near
+	field    string       // filter
+	pos      int
+}
+
+type queryParser struct {
+	toks []queryToken
+	i    int
+	end  int
+}
+
+func (p *queryParser) peek() queryToken {
+	if p.i < len(p.toks) {
+		return p.toks[p.i]
+	}
+	return queryToken{kind: tokEOF, pos: p.end}
+}
+
+func (p *queryParser) next() queryToken {
+	t := p.peek()
+	if p.i < len(p.toks) {
+		p.i++
+	}
+	return t
+}
+
+func (p *queryParser) errAt(t queryToken, msg string) error {
+	return &QuerySyntaxError{Pos: t.pos, Msg: msg}
+}
+
+// parseOr: and (OR and)*
+func (p *queryParser) parseOr() (*queryNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	kids := []*queryNode{first}
+	for p.peek().kind == tokOr {
+		op := p.next()
+		if !p.startsOperand() {
+			return nil, p.errAt(op, "OR needs a search term on both sides")
+		}
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		kids = append(kids, n)
+	}
+	if len(kids) == 1 {
+		return first, nil
+	}
+	return &queryNode{kind: nodeOr, kids: kids, pos: first.pos}, nil
+}
+
+// startsOperand reports whether the next token can begin an operand.
+func (p *queryParser) startsOperand() bool {
+	switch p.peek().kind {
+	case tokWord, tokPhrase, tokField, tokLParen, tokNear, tokNot:
+		return true
+	}
+	return false
+}
+
+// parseAnd: primary ((AND)? primary | NOT primary)*
+func (p *queryParser) parseAnd() (*queryNode, error) {
+	var left *queryNode
+	if p.peek().kind == tokNot {
+		// Leading NOT: only meaningful for field filters, checked later.
+		op := p.next()
+		operand, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryNode{kind: nodeNot, right: operand, pos: op.pos}
+	} else {
+		n, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = n
+	}
+
+	for {
+		t := p.peek()
+		switch t.kind {
+		case tokAnd:
+			p.next()
+			if p.peek().kind == tokNot {
+				continue // "a AND NOT b" is "a NOT b"
+			}
+			if !p.startsOperand() {
+				return nil, p.errAt(t, "AND needs a search term on both sides")
+			}
+			right, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			left = andNode(left, right)
+		case tokNot:
+			p.next()
+			right, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			left = &queryNode{kind: nodeNot, left: left, right: right, pos: t.pos}
+		case tokWord, tokPhrase, tokField, tokLParen, tokNear:
+			right, err := p.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			left = andNode(left, right)
+		default:
+			return left, nil
+		}
+	}
+}
+
+func andNode(left, right *queryNode) *queryNode {
+	if left.kind == nodeAnd {
+		left.kids = append(left.kids, right)
+		return left
+	}
+	return &queryNode{kind: nodeAnd, kids: []*queryNode{left, right}, pos: left.pos}
+}
+
+// parsePrimary: word | phrase | field value | NEAR(...) | ( or )
+func (p *queryParser) parsePrimary() (*queryNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokWord:
+		return &queryNode{kind: nodeTerm, text: t.text, prefix: t.prefix, pos: t.pos}, nil
+	case tokPhrase:
+		return &queryNode{kind: nodePhrase, text: t.text, prefix: t.prefix, pos: t.pos}, nil
+	case tokField:
+		v := p.next()
+		if v.kind != tokWord && v.kind != tokPhrase {
+			return nil, p.errAt(t, fmt.Sprintf("%s: needs a value, e.g. %s:redis", t.text, t.text))
+		}
+		return &queryNode{kind: nodeFilter, field: t.text, text: v.text, prefix: v.prefix, pos: t.pos}, nil
+	case tokNear:
+		return p.parseNear(t)
+	case tokLParen:
+		if p.peek().kind == tokRParen {
+			return nil, p.errAt(t, "empty parentheses")
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.next(); c.kind != tokRParen {
+			return nil, p.errAt(t, "missing ')'")
+		}
+		return n, nil
+	case tokEOF:
+		return nil, p.errAt(t, "query ends where a search term was expected")
+	case tokAnd, tokOr, tokNot:
+		return nil, p.errAt(t, fmt.Sprintf("%s needs a search term on both sides", t.text))
+	}
+	return nil, p.errAt(t, fmt.Sprintf("unexpected %s", t.describe()))
+}
+
+// parseNear: NEAR ( (word|phrase)+ [, N] )
+func (p *queryParser) parseNear(near queryToken) (*queryNode, error) {
+	if p.next().kind != tokLParen {
+		return nil, p.errAt(near, "NEAR must be followed by a group, e.g. NEAR(redis pool, 5)")
+	}
+	n := &queryNode{kind: nodeNear, distance: 10, pos: near.pos}
+	for done := false; !done; {
+		t := p.next()
+		switch t.kind {
+		case tokWord:
+			n.kids = append(n.kids, &queryNode{kind: nodeTerm, text: t.text, prefix: t.prefix, pos: t.pos})
+		case tokPhrase:
+			n.kids = append(n.kids, &queryNode{kind: nodePhrase, text: t.text, prefix: t.prefix, pos: t.pos})
+		case tokComma:
+			d := p.next()
+			dist, err := strconv.Atoi(d.text)
+			if d.kind != tokWord || err != nil || dist < 0 {
+				return nil, p.errAt(d, "NEAR distance must be a non-negative number")
+			}
+			n.distance = dist
+			if c := p.next(); c.kind != tokRParen {
+				return nil, p.errAt(c, "expected ')' after NEAR distance")
+			}
+			done = true
+		case tokRParen:
+			done = true
+		default:
+			return nil, p.errAt(t, "NEAR groups may only contain words and phrases")
+		}
+	}
+	if len(n.kids) < 2 {
+		return nil, p.errAt(near, "NEAR needs at least two words or phrases")
+	}
+	return n, nil
+}
+
+// extractFilters moves field filters out of the top-level AND chain into pq.
+// It returns the remaining full-text expression, or nil if nothing remains.
+func extractFilters(root *queryNode, pq *ParsedQuery) (*queryNode, error) {
+	var rest []*queryNode
+	parts := []*queryNode{root}
+	for len(parts) > 0 {
+		n := parts[0]
+		parts = parts[1:]
+		switch {
+		case n.kind == nodeAnd:
+			parts = append(append([]*queryNode{}, n.kids...), parts...)
+		case n.kind == nodeFilter:
+			pq.Filters = append(pq.Filters, n.filter(false))
+		case n.kind == nodeNot && n.right.kind == nodeFilter:
+			pq.Filters = append(pq.Filters, n.right.filter(true))
+			if n.left != nil {
+				parts = append([]*queryNode{n.left}, parts...)
+			}
+		default:
+			rest = append(rest, n)
+		}
+	}
+	for _, n := range rest {
+		if f := n.findFilter(); f != nil {
+			return nil, &QuerySyntaxError{Pos: f.pos, Msg: fmt.Sprintf("%s: filters can only be combined with AND (or NOT) at the top level", f.field)}
+		}
+	}
+
+	switch len(rest) {
+	case 0:
+		return nil, nil
+	case 1:
+		return rest[0], nil
+	}
+	return &queryNode{kind: nodeAnd, kids: rest, pos: rest[0].pos}, nil
+}
+
+func (n *queryNode) filter(negate bool) QueryFilter {
+	return QueryFilter{Field: n.field, Value: n.text, Prefix: n.prefix, Negate: negate}
+}
+
+func (n *queryNode) findFilter() *queryNode {
+	if n == nil {
+		return nil
+	}
+	if n.kind == nodeFilter {
+		return n
+	}
+	for _, k := range n.kids {
+		if f := k.findFilter(); f != nil {
+			return f
+		}
+	}
+	if f := n.left.findFilter(); f != nil {
+		return f
+	}
+	return n.right.findFilter()
+}
+
+// findLeadingNot returns a NOT node with nothing on its left, if any.
+func (n *queryNode) findLeadingNot() *queryNode {
+	if n == nil {
+		return nil
+	}
+	if n.kind == nodeNot && n.left == nil {
+		return n
+	}
+	for _, k := range n.kids {
+		if f := k.findLeadingNot(); f != nil {
+			return f
+		}
+	}
+	if f := n.left.findLeadingNot(); f != nil {
+		return f
+	}
+	return n.right.findLeadingNot()
+}
+
+// fts renders the node as an FTS5 expression.
+func (n *queryNode) fts() string {
+	switch n.kind {
+	case nodeTerm, nodePhrase:
+		s := ftsEscape(n.text)
+		if n.prefix {
+			s += "*"
+		}
+		return s
+	case nodeNear:
+		parts := make([]string, len(n.kids))
+		for i, k := range n.kids {
+			parts[i] = k.fts()
+		}
+		return fmt.Sprintf("NEAR(%s, %d)", strings.Join(parts, " "), n.distance)
+	case nodeAnd, nodeOr:
+		op := " AND "
+		if n.kind == nodeOr {
+			op = " OR "
+		}
+		parts := make([]string, len(n.kids))
+		for i, k := range n.kids {
+			parts[i] = k.fts()
+		}
+		return "(" + strings.Join(parts, op) + ")"
+	case nodeNot:
+		return "(" + n.left.fts() + " NOT " + n.right.fts() + ")"
+	}
+	return ""
+}
+
+// collectTerms appends the words and phrases a match must contain or may
+// contain, skipping negated operands.
+func (n *queryNode) collectTerms(terms *[]string) {
+	switch n.kind {
+	case nodeTerm, nodePhrase:
+		*terms = append(*terms, n.text)
+	case nodeNear, nodeAnd, nodeOr:
+		for _, k := range n.kids {
+			k.collectTerms(terms)
+		}
+	case nodeNot:
+		n.left.collectTerms(terms)
+	}
+}
+
+// filterSQL returns SQL conditions (each prefixed with " AND ") applying the
+// filters to the entity aliased as e, with their bind arguments.
+func filterSQL(filters []QueryFilter) (string, []interface{}) {
+	var b strings.Builder
+	var args []interface{}
+	for _, f := range filters {
+		var cond string
+		switch f.Field {
+		case FieldName:
+			match := ftsEscape(f.Value)
+			if f.Prefix {
+				match += "*"
+			}
+			cond = `e.id IN (SELECT rowid FROM entities_fts WHERE entities_fts MATCH ?
+				UNION SELECT a.entity_id FROM entity_aliases a
+				WHERE a.id IN (SELECT rowid FROM entity_aliases_fts WHERE entity_aliases_fts MATCH ?))`
+			args = append(args, "name : "+match, match)
+		case FieldType:
+			cond, args = likeOrEqual("e.entity_type", f, args)
+		case FieldTag:
+			var c string
+			c, args = likeOrEqual("value", f, args)
+			cond = "EXISTS (SELECT 1 FROM json_each(e.tags) WHERE " + c + ")"
+		}
+		if f.Negate {
+			cond = "NOT (" + cond + ")"
+		}
+		b.WriteString(" AND " + cond)
+	}
+	return b.String(), args
+}
+
+// likeOrEqual compares col case-insensitively to the filter value, as a
+// prefix when the filter has one.
+func likeOrEqual(col string, f QueryFilter, args []interface{}) (string, []interface{}) {
+	if f.Prefix {
+		escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(f.Value)
+		return col + ` LIKE ? ESCAPE '\'`, append(args, escaped+"%")
+	}
+	return "lower(" + col + ") = lower(?)", append(args, f.Value)
+}
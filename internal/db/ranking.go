@@ -0,0 +1,164 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// Recency decay functions for the importance score.
+const (
+	DecayLog         = "log"         // 1 / log10(age_hours + 2): fast early drop, long tail
+	DecayExponential = "exponential" // 0.5 ^ (age / half-life)
+	DecayNone        = "none"        // recency ignored; rank by access frequency only
+)
+
+// BM25 weights. Within entities_fts a name hit counts more than a type hit.
+// BM25 saturates term frequency, so column weights alone cannot make a name
+// hit outrank an observation hit; the per-source weights scale each table's
+// score before they are summed per entity.
+const (
+	bm25NameWeight = 10.0
+	bm25TypeWeight = 2.0
+
+	entityMatchWeight      = 3.0
+	observationMatchWeight = 1.0
+)
+
+// RankingModel scores entities by recency and access frequency:
+//
+//	importance = RecencyWeight * decay(age since update) + AccessWeight * log10(access_count + 1)
+//
+// For text queries the importance is added to the BM25 relevance of the
+// match, scaled to 0..1 within the result set:
+//
+//	score = RelevanceWeight * relevance / max(relevance) + importance
+type RankingModel struct {
+	RecencyWeight   float64
+	AccessWeight    float64
+	RelevanceWeight float64
+	Decay           string
+	HalfLife        time.Duration // used by DecayExponential
+}
+
+// DefaultRankingModel returns the built-in weights (0.6 recency, 0.4 access,
+// 3.0 relevance, log decay).
+func DefaultRankingModel() RankingModel {
+	return RankingModel{
+		RecencyWeight:   0.6,
+		AccessWeight:    0.4,
+		RelevanceWeight: 3.0,
+		Decay:           DecayLog,
+		HalfLife:        7 * 24 * time.Hour,
+	}
+}
+
+// Validate reports whether the model can be turned into a ranking expression.
+func (m RankingModel) Validate() error {
+	if m.RecencyWeight < 0 || m.AccessWeight < 0 || m.RelevanceWeight < 0 {
+		return fmt.Errorf("ranking weights must be non-negative (recency %v, access %v, relevance %v)",
+			m.RecencyWeight, m.AccessWeight, m.RelevanceWeight)
+	}
+	switch m.Decay {
+	case DecayLog, DecayNone:
+	case DecayExponential:
+		if m.HalfLife <= 0 {
+			return fmt.Errorf("exponential decay needs a positive half-life")
+		}
+	default:
+		return fmt.Errorf("unknown decay %q: use log, exponential or none", m.Decay)
+	}
+	return nil
+}
+
+// String summarizes the model for display.
+func (m RankingModel) String() string {
+	s := fmt.Sprintf("%s decay, recency %.2f, access %.2f, relevance %.2f",
+		m.Decay, m.RecencyWeight, m.AccessWeight, m.RelevanceWeight)
+	if m.Decay == DecayExponential {
+		s += fmt.Sprintf(", half-life %s", m.HalfLife)
+	}
+	return s
+}
+
+// importanceSQL returns a SQL expression computing the importance score for
+// the entity aliased as e as of nowMs (Unix ms), along with its bind arguments.
+// The clock is bound rather than read in SQL so every page of a search scores
+// against the same instant.
+func (m RankingModel) importanceSQL(nowMs int64) (string, []interface{}) {
+	const ageHours = `((? - e.updated_at) / 3600000.0)`
+	const access = `LOG(e.access_count + 1)`
+
+	switch m.Decay {
+	case DecayExponential:
+		return `(? * POWER(0.5, ` + ageHours + ` / ?) + ? * ` + access + `)`,
+			[]interface{}{m.RecencyWeight, nowMs, m.HalfLife.Hours(), m.AccessWeight}
+	case DecayNone:
+		return `(? * ` + access + `)`, []interface{}{m.AccessWeight}
+	default:
+		return `(? / LOG(` + ageHours + ` + 2) + ? * ` + access + `)`,
+			[]interface{}{m.RecencyWeight, nowMs, m.AccessWeight}
+	}
+}
+
+// relevanceSQL returns a query selecting search result columns for entities
+// matching the FTS5 expression match in idx, scored by blended relevance and
+// importance as final_rank, with ages measured at nowMs. The query ends inside its WHERE clause so callers
+// can append filters.
+//
+// bm25() is negative with lower meaning better, so it is negated. The FTS
+// scans are materialized so bm25() is evaluated against its own MATCH cursor
+// before any joins are planned around it.
+func (m RankingModel) relevanceSQL(idx ftsIndex, match string, nowMs int64) (string, []interface{}) {
+	importance, importanceArgs := m.importanceSQL(nowMs)
+	args := []interface{}{bm25NameWeight, bm25TypeWeight, match, match}
+
+	// An alias hit counts as a name hit on the entity it refers to.
+	var aliasHits, aliasUnion string
+	if idx.aliases != "" {
+		aliasHits = `
+alias_hits AS MATERIALIZED (
+    SELECT rowid AS alias_id, -bm25(` + idx.aliases + `, ?) AS rel
+    FROM ` + idx.aliases + ` WHERE ` + idx.aliases + ` MATCH ?
+),`
+		aliasUnion = `
+        UNION ALL
+        SELECT a.entity_id, ? * MAX(h.rel)
+        FROM alias_hits h JOIN entity_aliases a ON a.id = h.alias_id
+        GROUP BY a.entity_id`
+		args = append(args, bm25NameWeight, match)
+	}
+	args = append(args, entityMatchWeight, observationMatchWeight)
+	if idx.aliases != "" {
+		args = append(args, entityMatchWeight)
+	}
+	args = append(args, m.RelevanceWeight)
+
+	query := `
+WITH
+entity_hits AS MATERIALIZED (
+    SELECT rowid AS entity_id, -bm25(` + idx.entities + `, ?, ?) AS rel
+    FROM ` + idx.entities + ` WHERE ` + idx.entities + ` MATCH ?
+),
+observation_hits AS MATERIALIZED (
+    SELECT rowid AS observation_id, -bm25(` + idx.observations + `) AS rel
+    FROM ` + idx.observations + ` WHERE ` + idx.observations + ` MATCH ?
+),` + aliasHits + `
+hits AS (
+    SELECT entity_id, SUM(rel) AS rel FROM (
+        SELECT entity_id, ? * rel AS rel FROM entity_hits
+        UNION ALL
+        SELECT o.entity_id, ? * MAX(h.rel)
+        FROM observation_hits h JOIN observations o ON o.id = h.observation_id
+        GROUP BY o.entity_id` + aliasUnion + `
+    )
+    GROUP BY entity_id
+)
+SELECT
+    e.id, e.name, e.entity_type, e.tags, e.created_at, e.updated_at, e.deleted_at,
+    e.access_count, e.last_accessed,
+    ? * COALESCE(h.rel / NULLIF(MAX(h.rel) OVER (), 0), 0) + ` + importance + ` AS final_rank
+FROM hits h
+JOIN entities e ON e.id = h.entity_id
+WHERE e.deleted_at IS NULL`
+	return query, append(args, importanceArgs...)
+}
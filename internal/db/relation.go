@@ -2,6 +2,8 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 )
 
@@ -19,7 +21,18 @@ type Relation struct {
 // UpsertRelation creates a typed relation between two entities (by ID).
 // It silently ignores duplicate (from, to, relation) triples.
 func (db *DB) UpsertRelation(ctx context.Context, fromID, toID int64, relation string) error {
-	_, err := db.ExecContext(ctx, `
+	m := &mutation{op: "relation.add", entityID: fromID}
+	return db.mutate(ctx, m, func(tx *sql.Tx) error {
+		return upsertRelation(ctx, m, fromID, toID, relation)
+	})
+}
+
+// upsertRelation inserts a relation within m's transaction.
+func upsertRelation(ctx context.Context, m *mutation, fromID, toID int64, relation string) error {
+	if err := m.track(ctx, "relations", "from_id = ? AND to_id = ? AND relation = ?", fromID, toID, relation); err != nil {
+		return err
+	}
+	_, err := m.tx.ExecContext(ctx, `
 		INSERT INTO relations (from_id, to_id, relation)
 		VALUES (?, ?, ?)
 		ON CONFLICT(from_id, to_id, relation) DO NOTHING
@@ -28,23 +41,36 @@ func (db *DB) UpsertRelation(ctx context.Context, fromID, toID int64, relation s
 }
 
 // UpsertRelationByName creates a relation between named entities, auto-creating them if needed.
+// Names are resolved case-insensitively and through aliases before a new entity is created.
 func (db *DB) UpsertRelationByName(ctx context.Context, fromName, toName, relation string) error {
-	fromID, err := db.ensureEntity(ctx, fromName)
-	if err != nil {
-		return fmt.Errorf("ensure entity %q: %w", fromName, err)
-	}
-	toID, err := db.ensureEntity(ctx, toName)
-	if err != nil {
-		return fmt.Errorf("ensure entity %q: %w", toName, err)
-	}
-	return db.UpsertRelation(ctx, fromID, toID, relation)
+	m := &mutation{op: "relation.add"}
+	return db.mutate(ctx, m, func(tx *sql.Tx) error {
+		fromID, err := ensureEntity(ctx, m, fromName)
+		if err != nil {
+			return fmt.Errorf("ensure entity %q: %w", fromName, err)
+		}
+		toID, err := ensureEntity(ctx, m, toName)
+		if err != nil {
+			return fmt.Errorf("ensure entity %q: %w", toName, err)
+		}
+		m.entityID = fromID
+		return upsertRelation(ctx, m, fromID, toID, relation)
+	})
 }
 
 // ensureEntity returns the entity ID for name, creating it if it doesn't exist.
 // Unlike UpsertEntity, this does NOT overwrite existing type/tags.
-func (db *DB) ensureEntity(ctx context.Context, name string) (int64, error) {
+func ensureEntity(ctx context.Context, m *mutation, name string) (int64, error) {
+	if id, _, err := resolveEntity(ctx, m.tx, name); err == nil {
+		return id, nil
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if err := m.track(ctx, "entities", "name = ?", name); err != nil {
+		return 0, err
+	}
 	// Try insert-ignore first
-	_, err := db.ExecContext(ctx, `
+	_, err := m.tx.ExecContext(ctx, `
 		INSERT OR IGNORE INTO entities (name, entity_type, tags) VALUES (?, 'concept', '[]')
 	`, name)
 	if err != nil {
@@ -52,12 +78,17 @@ func (db *DB) ensureEntity(ctx context.Context, name string) (int64, error) {
 	}
 	// Always get by name
 	var id int64
-	err = db.QueryRowContext(ctx, `SELECT id FROM entities WHERE name = ?`, name).Scan(&id)
+	err = m.tx.QueryRowContext(ctx, `SELECT id FROM entities WHERE name = ?`, name).Scan(&id)
 	return id, err
 }
 
-// ListRelationsByEntity returns all relations involving a given entity name.
+// ListRelationsByEntity returns all relations involving a given entity name
+// or alias.
 func (db *DB) ListRelationsByEntity(ctx context.Context, name string) ([]Relation, error) {
+	name, err := db.canonicalName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
 	rows, err := db.QueryContext(ctx, `
 		SELECT r.id, r.from_id, fe.name, r.to_id, te.name, r.relation, r.created_at
 		FROM relations r
@@ -82,3 +113,104 @@ func (db *DB) ListRelationsByEntity(ctx context.Context, name string) ([]Relatio
 	}
 	return rels, rows.Err()
 }
+
+// DeleteRelation removes relations from one named entity to another; either
+// may be given by alias. If relation is empty, every relation type
+// from -> to is removed. Returns the number of relations deleted.
+func (db *DB) DeleteRelation(ctx context.Context, fromName, toName, relation string) (int64, error) {
+	fromName, err := db.canonicalName(ctx, fromName)
+	if err != nil {
+		return 0, err
+	}
+	if toName, err = db.canonicalName(ctx, toName); err != nil {
+		return 0, err
+	}
+	cond := `
+		from_id IN (SELECT id FROM entities WHERE lower(name) = lower(?))
+		  AND to_id IN (SELECT id FROM entities WHERE lower(name) = lower(?))`
+	args := []interface{}{fromName, toName}
+	if relation != "" {
+		cond += " AND relation = ?"
+		args = append(args, relation)
+	}
+	var n int64
+	m := &mutation{op: "relation.delete", entity: fromName, scope: scope{}.add("relations", cond, args...)}
+	err = db.mutate(ctx, m, func(tx *sql.Tx) error {
+		_ = tx.QueryRowContext(ctx, `
+			SELECT id, name FROM entities WHERE lower(name) = lower(?) ORDER BY deleted_at IS NOT NULL LIMIT 1
+		`, fromName).Scan(&m.entityID, &m.entity)
+		res, err := tx.ExecContext(ctx, `DELETE FROM relations WHERE `+cond, args...)
+		if err != nil {
+			return fmt.Errorf("delete relation: %w", err)
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return n, err
+}
+
+// DeleteRelationByID removes a single relation by ID.
+func (db *DB) DeleteRelationByID(ctx context.Context, id int64) (int64, error) {
+	m := &mutation{op: "relation.delete", scope: scope{}.add("relations", "id = ?", id)}
+	err := db.mutate(ctx, m, func(tx *sql.Tx) error {
+		_ = tx.QueryRowContext(ctx, `SELECT from_id FROM relations WHERE id = ?`, id).Scan(&m.entityID)
+		res, err := tx.ExecContext(ctx, `DELETE FROM relations WHERE id = ?`, id)
+		if err != nil {
+			return fmt.Errorf("delete relation: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("relation %d not found", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+// RenameRelationType renames a relation type across the whole graph.
+// Edges that already exist under the new name are kept once (the old
+// duplicate is dropped). Returns the number of edges changed.
+func (db *DB) RenameRelationType(ctx context.Context, oldRelation, newRelation string) (int64, error) {
+	if oldRelation == "" || newRelation == "" {
+		return 0, fmt.Errorf("relation names cannot be empty")
+	}
+	if oldRelation == newRelation {
+		return 0, nil
+	}
+
+	var renamed, merged int64
+	m := &mutation{op: "relation.rename", scope: scope{}.add("relations", "relation = ?", oldRelation)}
+	err := db.mutate(ctx, m, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `UPDATE OR IGNORE relations SET relation = ? WHERE relation = ?`, newRelation, oldRelation)
+		if err != nil {
+			return fmt.Errorf("rename relation: %w", err)
+		}
+		renamed, _ = res.RowsAffected()
+
+		// Rows left under the old name collided with an existing edge.
+		res, err = tx.ExecContext(ctx, `DELETE FROM relations WHERE relation = ?`, oldRelation)
+		if err != nil {
+			return fmt.Errorf("drop duplicate relations: %w", err)
+		}
+		merged, _ = res.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return renamed + merged, nil
+}
+
+// ListRelations returns every relation between two active entities.
+func (db *DB) ListRelations(ctx context.Context) ([]Relation, error) {
+	return db.queryRelations(ctx, `
+		SELECT r.id, r.from_id, fe.name, r.to_id, te.name, r.relation, r.created_at
+		FROM relations r
+		JOIN entities fe ON r.from_id = fe.id
+		JOIN entities te ON r.to_id = te.id
+		WHERE fe.deleted_at IS NULL AND te.deleted_at IS NULL
+		ORDER BY r.id
+	`)
+}
@@ -1,6 +1,7 @@
 package db
 
-// Schema contains all CREATE TABLE/TRIGGER statements for aimemo.
+// Schema contains the baseline (version 1) CREATE TABLE/TRIGGER statements.
+// Later schema changes are appended to migrations in migrate.go.
 const Schema = `
 CREATE TABLE IF NOT EXISTS entities (
     id            INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -103,3 +104,190 @@ CREATE TRIGGER IF NOT EXISTS journal_fts_delete AFTER DELETE ON journal BEGIN
     INSERT INTO journal_fts(journal_fts, rowid, content) VALUES('delete', old.id, old.content);
 END;
 `
+
+// tasksSchema adds tracked tasks (migration 2).
+const tasksSchema = `
+CREATE TABLE IF NOT EXISTS tasks (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    title        TEXT    NOT NULL,
+    status       TEXT    NOT NULL DEFAULT 'open'
+                 CHECK (status IN ('open', 'in_progress', 'blocked', 'done', 'cancelled')),
+    priority     INTEGER NOT NULL DEFAULT 2,
+    notes        TEXT    NOT NULL DEFAULT '',
+    due_at       INTEGER,
+    entity_id    INTEGER REFERENCES entities(id) ON DELETE SET NULL,
+    created_at   INTEGER NOT NULL DEFAULT (unixepoch('now', 'subsec') * 1000),
+    updated_at   INTEGER NOT NULL DEFAULT (unixepoch('now', 'subsec') * 1000),
+    completed_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_tasks_open ON tasks(status, priority, created_at);
+`
+
+// fuzzySchema adds the indexes behind typo-tolerant search: trigram FTS5
+// tables mirroring entities_fts and observations_fts for substring matches,
+// and fts5vocab views over the word indexes for edit-distance candidates.
+const fuzzySchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS entities_trigram USING fts5(
+    name,
+    entity_type,
+    content='entities',
+    content_rowid='id',
+    tokenize='trigram'
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS observations_trigram USING fts5(
+    content,
+    content='observations',
+    content_rowid='id',
+    tokenize='trigram'
+);
+
+CREATE TRIGGER IF NOT EXISTS entities_trigram_insert AFTER INSERT ON entities BEGIN
+    INSERT INTO entities_trigram(rowid, name, entity_type) VALUES (new.id, new.name, new.entity_type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS entities_trigram_delete AFTER DELETE ON entities BEGIN
+    INSERT INTO entities_trigram(entities_trigram, rowid, name, entity_type) VALUES('delete', old.id, old.name, old.entity_type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS entities_trigram_update AFTER UPDATE OF name, entity_type ON entities
+WHEN old.deleted_at IS NULL AND new.deleted_at IS NULL BEGIN
+    INSERT INTO entities_trigram(entities_trigram, rowid, name, entity_type) VALUES('delete', old.id, old.name, old.entity_type);
+    INSERT INTO entities_trigram(rowid, name, entity_type) VALUES (new.id, new.name, new.entity_type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS entities_trigram_soft_delete AFTER UPDATE OF deleted_at ON entities
+WHEN old.deleted_at IS NULL AND new.deleted_at IS NOT NULL BEGIN
+    INSERT INTO entities_trigram(entities_trigram, rowid, name, entity_type) VALUES('delete', old.id, old.name, old.entity_type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS entities_trigram_soft_restore AFTER UPDATE OF deleted_at ON entities
+WHEN old.deleted_at IS NOT NULL AND new.deleted_at IS NULL BEGIN
+    INSERT INTO entities_trigram(rowid, name, entity_type) VALUES (new.id, new.name, new.entity_type);
+END;
+
+CREATE TRIGGER IF NOT EXISTS observations_trigram_insert AFTER INSERT ON observations BEGIN
+    INSERT INTO observations_trigram(rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS observations_trigram_delete AFTER DELETE ON observations BEGIN
+    INSERT INTO observations_trigram(observations_trigram, rowid, content) VALUES('delete', old.id, old.content);
+END;
+
+CREATE VIRTUAL TABLE IF NOT EXISTS entities_vocab USING fts5vocab(entities_fts, 'row');
+CREATE VIRTUAL TABLE IF NOT EXISTS observations_vocab USING fts5vocab(observations_fts, 'row');
+
+INSERT INTO entities_trigram(rowid, name, entity_type)
+    SELECT id, name, entity_type FROM entities WHERE deleted_at IS NULL;
+INSERT INTO observations_trigram(observations_trigram) VALUES('rebuild');
+`
+
+// embeddingsSchema stores one vector per observation for semantic search.
+// model names the embedder that produced the vector, so vectors from a
+// previously configured embedder are recognized as stale and replaced.
+const embeddingsSchema = `
+CREATE TABLE IF NOT EXISTS observation_embeddings (
+    observation_id INTEGER PRIMARY KEY REFERENCES observations(id) ON DELETE CASCADE,
+    model          TEXT    NOT NULL,
+    vector         BLOB    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_observation_embeddings_model ON observation_embeddings(model);
+`
+
+// aliasesSchema adds alternate names for entities (migration 5). Aliases are
+// unique regardless of case and indexed for full-text search alongside
+// entity names.
+const aliasesSchema = `
+CREATE TABLE IF NOT EXISTS entity_aliases (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity_id  INTEGER NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    alias      TEXT    NOT NULL COLLATE NOCASE UNIQUE,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch('now', 'subsec') * 1000)
+);
+
+CREATE INDEX IF NOT EXISTS idx_entity_aliases_entity ON entity_aliases(entity_id);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS entity_aliases_fts USING fts5(
+    alias,
+    content='entity_aliases',
+    content_rowid='id',
+    tokenize='porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS entity_aliases_fts_insert AFTER INSERT ON entity_aliases BEGIN
+    INSERT INTO entity_aliases_fts(rowid, alias) VALUES (new.id, new.alias);
+END;
+
+CREATE TRIGGER IF NOT EXISTS entity_aliases_fts_delete AFTER DELETE ON entity_aliases BEGIN
+    INSERT INTO entity_aliases_fts(entity_aliases_fts, rowid, alias) VALUES('delete', old.id, old.alias);
+END;
+`
+
+// trashSchema supports trash management (migration 6). Soft-deleted
+// entities are already gone from entities_fts and entities_trigram, so the
+// delete triggers now skip them; issuing an FTS5 'delete' for a row that is
+// not indexed corrupts the index totals that bm25() relies on. The partial
+// index serves trash listing and purging.
+const trashSchema = `
+DROP TRIGGER IF EXISTS entities_fts_delete;
+CREATE TRIGGER entities_fts_delete AFTER DELETE ON entities
+WHEN old.deleted_at IS NULL BEGIN
+    INSERT INTO entities_fts(entities_fts, rowid, name, entity_type) VALUES('delete', old.id, old.name, old.entity_type);
+END;
+
+DROP TRIGGER IF EXISTS entities_trigram_delete;
+CREATE TRIGGER entities_trigram_delete AFTER DELETE ON entities
+WHEN old.deleted_at IS NULL BEGIN
+    INSERT INTO entities_trigram(entities_trigram, rowid, name, entity_type) VALUES('delete', old.id, old.name, old.entity_type);
+END;
+
+CREATE INDEX IF NOT EXISTS idx_entities_deleted ON entities(deleted_at) WHERE deleted_at IS NOT NULL;
+`
+
+// changesSchema adds the append-only change log (migration 7). Each row is
+// one mutation with the affected rows before and after as JSON; entity_id
+// has no foreign key so history outlives the entity.
+const changesSchema = `
+CREATE TABLE IF NOT EXISTS changes (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    op         TEXT    NOT NULL,
+    entity_id  INTEGER,
+    entity     TEXT    NOT NULL DEFAULT '',
+    before     TEXT,
+    after      TEXT,
+    source     TEXT    NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL DEFAULT (unixepoch('now', 'subsec') * 1000)
+);
+
+CREATE INDEX IF NOT EXISTS idx_changes_created ON changes(created_at);
+CREATE INDEX IF NOT EXISTS idx_changes_entity ON changes(entity_id);
+`
+
+// undoSchema links undo entries to the change they reverse (migration 8).
+// The log stays append-only: a change is undone when an entry undoes it.
+const undoSchema = `
+ALTER TABLE changes ADD COLUMN undoes INTEGER;
+CREATE INDEX IF NOT EXISTS idx_changes_undoes ON changes(undoes) WHERE undoes IS NOT NULL;
+`
+
+// tokensSchema stores bearer tokens for the network transports (migration 9).
+// Only a SHA-256 hash of each token is kept.
+const tokensSchema = `
+CREATE TABLE IF NOT EXISTS tokens (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    name         TEXT    NOT NULL UNIQUE COLLATE NOCASE,
+    hash         TEXT    NOT NULL UNIQUE,
+    scopes       TEXT    NOT NULL DEFAULT '[]',
+    contexts     TEXT    NOT NULL DEFAULT '[]',
+    created_at   INTEGER NOT NULL DEFAULT (unixepoch('now', 'subsec') * 1000),
+    last_used_at INTEGER
+);
+`
+
+// changesEntitySchema indexes change log entries by lower-cased entity name
+// for ListChanges' name lookups (migration 10).
+const changesEntitySchema = `
+CREATE INDEX IF NOT EXISTS idx_changes_entity_name ON changes(lower(entity));
+`
@@ -8,10 +8,13 @@ import (
 	"strings"
 )
 
-// SearchResult extends Entity with a search score.
+// SearchResult extends Entity with a search score and, for text queries,
+// the observations that matched.
 type SearchResult struct {
 	Entity
-	Score float64 `json:"score"`
+	Score   float64            `json:"score"`
+	Fuzzy   bool               `json:"fuzzy,omitempty"` // found by FuzzySearch, not an exact match
+	Matches []ObservationMatch `json:"matches,omitempty"`
 }
 
 // ftsEscape wraps user query in double quotes for FTS5 safety.
@@ -25,63 +28,94 @@ func ftsEscape(q string) string {
 	return `"` + q + `"`
 }
 
-// Search performs FTS5 search across entities and observations.
-// If query is empty, lists all active entities.
+// Search performs FTS5 search across entities and observations. The query
+// uses the syntax accepted by ParseQuery; malformed input returns a
+// *QuerySyntaxError. If the query is empty, or only has field filters, lists
+// matching active entities in sort order.
 // limit=0 uses the default (10). Callers are responsible for enforcing max limits.
 func (db *DB) Search(ctx context.Context, query, entityType string, tags []string, sort string, limit int) ([]SearchResult, error) {
+	results, _, err := db.SearchPage(ctx, query, entityType, tags, sort, limit, "")
+	return results, err
+}
+
+// SearchPage is Search with cursor pagination. Pass "" for the first page and
+// the returned next cursor, with the same arguments, for the following pages.
+// next is "" after the last page.
+func (db *DB) SearchPage(ctx context.Context, query, entityType string, tags []string, sort string, limit int, cursor string) ([]SearchResult, string, error) {
 	if limit <= 0 {
 		limit = 10
 	}
 
-	if query == "" {
-		return db.listAll(ctx, entityType, tags, sort, limit)
+	pq, err := ParseQuery(query)
+	if err != nil {
+		return nil, "", err
+	}
+	pg, err := newPage(limit, cursor, "entities", query, entityType, strings.Join(tags, ","), sort)
+	if err != nil {
+		return nil, "", err
+	}
+	if pq.Match == "" {
+		return db.listAll(ctx, entityType, tags, pq.Filters, sort, pg)
 	}
 
-	escaped := ftsEscape(query)
-	// bm25() in CTEs is unreliable across SQLite versions. Use IN-subquery approach
-	// to find matching entity IDs, then rank by importance score.
-	sqlQuery := `
-SELECT DISTINCT
-    e.id, e.name, e.entity_type, e.tags, e.created_at, e.updated_at, e.deleted_at,
-    e.access_count, e.last_accessed,
-    (0.6 / LOG(((unixepoch('now') * 1000 - e.updated_at) / 3600000.0) + 2) + 0.4 * LOG(e.access_count + 1)) AS importance_rank
-FROM entities e
-WHERE e.deleted_at IS NULL
-  AND (
-    e.id IN (SELECT rowid FROM entities_fts WHERE entities_fts MATCH ?)
-    OR e.id IN (
-        SELECT o.entity_id FROM observations o
-        WHERE o.id IN (SELECT rowid FROM observations_fts WHERE observations_fts MATCH ?)
-    )
-  )`
+	return db.searchIndex(ctx, wordIndex, pq.Match, entityType, tags, pq.Filters, pg)
+}
 
-	args := []interface{}{escaped, escaped}
+// ftsIndex names the FTS5 tables over entity names/types, observations and,
+// if set, entity aliases.
+type ftsIndex struct {
+	entities     string
+	observations string
+	aliases      string
+}
 
-	if entityType != "" {
-		sqlQuery += " AND e.entity_type = ?"
-		args = append(args, entityType)
-	}
+// wordIndex is the primary porter-stemmed word index; trigramIndex matches
+// substrings and backs fuzzy search.
+var (
+	wordIndex    = ftsIndex{entities: "entities_fts", observations: "observations_fts", aliases: "entity_aliases_fts"}
+	trigramIndex = ftsIndex{entities: "entities_trigram", observations: "observations_trigram"}
+)
 
-	if len(tags) > 0 {
-		sqlQuery += fmt.Sprintf(
-			" AND (SELECT COUNT(*) FROM json_each(e.tags) WHERE value IN (%s)) = %d",
-			placeholders(len(tags)), len(tags),
-		)
-		for _, tag := range tags {
-			args = append(args, tag)
-		}
-	}
+// searchIndex ranks active entities matching the FTS5 expression match in idx
+// and attaches their matching observations. Pages are ordered by score, then
+// ID; every page is scored with the clock of the first so the order holds.
+func (db *DB) searchIndex(ctx context.Context, idx ftsIndex, match, entityType string, tags []string, filters []QueryFilter, pg page) ([]SearchResult, string, error) {
+	inner, args := db.ranking.relevanceSQL(idx, match, pg.clock)
+	where, whereArgs := entityFilterSQL(entityType, tags, filters)
+	inner += where
+	args = append(args, whereArgs...)
 
-	sqlQuery += " ORDER BY importance_rank DESC LIMIT ?"
-	args = append(args, limit)
+	sqlQuery := "SELECT * FROM (" + inner + ")"
+	if pg.after != nil {
+		sqlQuery += " WHERE final_rank < ? OR (final_rank = ? AND id > ?)"
+		args = append(args, pg.after.Score, pg.after.Score, pg.after.ID)
+	}
+	sqlQuery += " ORDER BY final_rank DESC, id ASC LIMIT ?"
+	args = append(args, pg.limit+1)
 
 	rows, err := db.QueryContext(ctx, sqlQuery, args...)
 	if err != nil {
-		return nil, fmt.Errorf("search query: %w", err)
+		return nil, "", fmt.Errorf("search query: %w", err)
 	}
 	defer rows.Close()
 
-	return db.scanSearchRows(ctx, rows)
+	results, err := scanSearchRows(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	var next string
+	if len(results) > pg.limit {
+		results = results[:pg.limit]
+		last := results[len(results)-1]
+		next = pg.next(pageCursor{Clock: pg.clock, Score: last.Score, ID: last.ID})
+	}
+	if err := db.loadObservations(ctx, results); err != nil {
+		return nil, "", err
+	}
+	if err := db.attachMatches(ctx, idx, match, results); err != nil {
+		return nil, "", fmt.Errorf("search matches: %w", err)
+	}
+	return results, next, nil
 }
 
 // SearchByName does an exact (case-insensitive) name lookup with observation loading.
@@ -89,30 +123,19 @@ func (db *DB) SearchByName(ctx context.Context, name string) (*Entity, error) {
 	return db.GetEntity(ctx, name)
 }
 
-// listAll returns entities sorted by sort order.
-func (db *DB) listAll(ctx context.Context, entityType string, tags []string, sort string, limit int) ([]SearchResult, error) {
-	orderBy := "e.updated_at DESC"
-	switch sort {
-	case "accessed":
-		orderBy = "COALESCE(e.last_accessed, 0) DESC"
-	case "name":
-		orderBy = "e.name ASC"
-	}
-
-	query := `
-		SELECT DISTINCT e.id, e.name, e.entity_type, e.tags, e.created_at, e.updated_at, e.deleted_at,
-		       e.access_count, e.last_accessed, 0.0 AS final_rank
-		FROM entities e
-		WHERE e.deleted_at IS NULL`
+// entityFilterSQL returns conditions (each prefixed with " AND ") restricting
+// the entity aliased as e by type, by tags (all required) and by query filters.
+func entityFilterSQL(entityType string, tags []string, filters []QueryFilter) (string, []interface{}) {
+	var where string
 	args := []interface{}{}
 
 	if entityType != "" {
-		query += " AND e.entity_type = ?"
+		where += " AND e.entity_type = ?"
 		args = append(args, entityType)
 	}
 
 	if len(tags) > 0 {
-		query += fmt.Sprintf(
+		where += fmt.Sprintf(
 			" AND (SELECT COUNT(*) FROM json_each(e.tags) WHERE value IN (%s)) = %d",
 			placeholders(len(tags)), len(tags),
 		)
@@ -121,22 +144,76 @@ func (db *DB) listAll(ctx context.Context, entityType string, tags []string, sor
 		}
 	}
 
+	filterWhere, filterArgs := filterSQL(filters)
+	return where + filterWhere, append(args, filterArgs...)
+}
+
+// listAll returns entities sorted by sort order, with ties broken by ID.
+func (db *DB) listAll(ctx context.Context, entityType string, tags []string, filters []QueryFilter, sort string, pg page) ([]SearchResult, string, error) {
+	key, orderBy, cmp := "e.updated_at", "e.updated_at DESC, e.id DESC", "<"
+	switch sort {
+	case "accessed":
+		key, orderBy = "COALESCE(e.last_accessed, 0)", "COALESCE(e.last_accessed, 0) DESC, e.id DESC"
+	case "name":
+		key, orderBy, cmp = "e.name", "e.name ASC, e.id ASC", ">"
+	}
+
+	query := `
+		SELECT DISTINCT e.id, e.name, e.entity_type, e.tags, e.created_at, e.updated_at, e.deleted_at,
+		       e.access_count, e.last_accessed, 0.0 AS final_rank
+		FROM entities e
+		WHERE e.deleted_at IS NULL`
+	where, args := entityFilterSQL(entityType, tags, filters)
+	query += where
+
+	if c := pg.after; c != nil {
+		query += " AND (" + key + ", e.id) " + cmp + " (?, ?)"
+		if sort == "name" {
+			args = append(args, c.Str, c.ID)
+		} else {
+			args = append(args, c.Num, c.ID)
+		}
+	}
+
 	query += " ORDER BY " + orderBy + " LIMIT ?"
-	args = append(args, limit)
+	args = append(args, pg.limit+1)
 
 	rows, err := db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
-	return db.scanSearchRows(ctx, rows)
+	results, err := scanSearchRows(rows)
+	if err != nil {
+		return nil, "", err
+	}
+	var next string
+	if len(results) > pg.limit {
+		results = results[:pg.limit]
+		last := results[len(results)-1]
+		c := pageCursor{Num: last.UpdatedAt, ID: last.ID}
+		switch sort {
+		case "accessed":
+			c.Num = 0
+			if last.LastAccessed != nil {
+				c.Num = *last.LastAccessed
+			}
+		case "name":
+			c = pageCursor{Str: last.Name, ID: last.ID}
+		}
+		next = pg.next(c)
+	}
+	if err := db.loadObservations(ctx, results); err != nil {
+		return nil, "", err
+	}
+	return results, next, nil
 }
 
-// scanSearchRows scans rows into SearchResult slice and loads observations.
-// Observations are loaded in a second pass after closing the search rows,
-// to avoid deadlock on single-connection DBs.
-func (db *DB) scanSearchRows(ctx context.Context, rows *sql.Rows) ([]SearchResult, error) {
+// scanSearchRows scans rows into a SearchResult slice and closes them.
+// Observations are loaded by loadObservations in a second pass after the
+// search rows are closed, to avoid deadlock on single-connection DBs.
+func scanSearchRows(rows *sql.Rows) ([]SearchResult, error) {
 	var results []SearchResult
 	for rows.Next() {
 		var e Entity
@@ -172,16 +249,19 @@ func (db *DB) scanSearchRows(ctx context.Context, rows *sql.Rows) ([]SearchResul
 		return nil, err
 	}
 	rows.Close() // release the connection before loading observations
+	return results, nil
+}
 
-	// Second pass: load observations (requires a new query)
+// loadObservations fills in the observations of each result.
+func (db *DB) loadObservations(ctx context.Context, results []SearchResult) error {
 	for i := range results {
 		obs, err := db.ListObservationsByEntityID(ctx, results[i].ID)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		results[i].Observations = obs
 	}
-	return results, nil
+	return nil
 }
 
 // Stats returns counts of entities, observations, and journal entries.
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"strings"
+)
+
+// Highlight markers wrapped around matched terms in ObservationMatch.Highlight
+// and ObservationMatch.Snippet, unless the database was opened with PlainSnippets.
+// They are brackets that markdown gives no meaning to, so they cannot be
+// confused with formatting already in stored text.
+const (
+	HighlightOpen  = "⟦"
+	HighlightClose = "⟧"
+)
+
+// snippetTokens is the approximate number of tokens in a snippet excerpt.
+const snippetTokens = 16
+
+// ObservationMatch is an observation that matched a search query.
+type ObservationMatch struct {
+	Content   string `json:"-"`
+	Highlight string `json:"highlight"`         // full observation with matched terms marked
+	Snippet   string `json:"snippet,omitempty"` // excerpt around the best match; only for observations longer than it
+}
+
+// HighlightSpans splits s at highlight markers. Even-indexed parts are plain
+// text and odd-indexed parts are matched terms.
+func HighlightSpans(s string) []string {
+	var parts []string
+	for {
+		i := strings.Index(s, HighlightOpen)
+		if i < 0 {
+			break
+		}
+		j := strings.Index(s[i+len(HighlightOpen):], HighlightClose)
+		if j < 0 {
+			break
+		}
+		parts = append(parts, s[:i], s[i+len(HighlightOpen):i+len(HighlightOpen)+j])
+		s = s[i+len(HighlightOpen)+j+len(HighlightClose):]
+	}
+	return append(parts, s)
+}
+
+// attachMatches fills Matches on each result with the observations matching
+// the FTS5 expression in idx, in observation order, using highlight() and snippet().
+func (db *DB) attachMatches(ctx context.Context, idx ftsIndex, match string, results []SearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	openMark, closeMark := HighlightOpen, HighlightClose
+	if db.plainSnippets {
+		openMark, closeMark = "", ""
+	}
+
+	index := make(map[int64]int, len(results))
+	args := []interface{}{openMark, closeMark, openMark, closeMark, snippetTokens, match}
+	for i, r := range results {
+		index[r.ID] = i
+		args = append(args, r.ID)
+	}
+
+	fts := idx.observations
+	rows, err := db.QueryContext(ctx, `
+		SELECT o.entity_id, o.content,
+		       highlight(`+fts+`, 0, ?, ?),
+		       snippet(`+fts+`, 0, ?, ?, '…', ?)
+		FROM `+fts+`
+		JOIN observations o ON o.id = `+fts+`.rowid
+		WHERE `+fts+` MATCH ?
+		  AND o.entity_id IN (`+placeholders(len(results))+`)
+		ORDER BY o.entity_id, o.created_at ASC, o.id ASC`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entityID int64
+		var m ObservationMatch
+		if err := rows.Scan(&entityID, &m.Content, &m.Highlight, &m.Snippet); err != nil {
+			return err
+		}
+		if m.Snippet == m.Highlight {
+			m.Snippet = "" // the excerpt is the whole observation
+		}
+		i := index[entityID]
+		results[i].Matches = append(results[i].Matches, m)
+	}
+	return rows.Err()
+}
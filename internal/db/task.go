@@ -0,0 +1,348 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Task statuses. Open, in-progress and blocked tasks count as incomplete.
+const (
+	TaskOpen       = "open"
+	TaskInProgress = "in_progress"
+	TaskBlocked    = "blocked"
+	TaskDone       = "done"
+	TaskCancelled  = "cancelled"
+)
+
+// incompleteStatuses are the statuses reported as unfinished work.
+var incompleteStatuses = []string{TaskOpen, TaskInProgress, TaskBlocked}
+
+// taskPriorities maps priority names to their stored rank (lower sorts first).
+var taskPriorities = map[string]int{"high": 1, "medium": 2, "low": 3}
+
+// Task is a tracked unit of work, optionally linked to an entity.
+type Task struct {
+	ID          int64  `json:"id"`
+	Title       string `json:"title"`
+	Status      string `json:"status"`
+	Priority    string `json:"priority"`
+	Notes       string `json:"notes,omitempty"`
+	DueAt       *int64 `json:"due_at,omitempty"`
+	Entity      string `json:"entity,omitempty"`
+	CreatedAt   int64  `json:"created_at"`
+	UpdatedAt   int64  `json:"updated_at"`
+	CompletedAt *int64 `json:"completed_at,omitempty"`
+}
+
+// TaskInput is used for creating tasks.
+type TaskInput struct {
+	Title    string
+	Priority string // high|medium|low; empty means medium
+	Notes    string
+	DueAt    *int64
+	Entity   string // entity name; created as a stub if missing
+}
+
+// TaskUpdate holds the fields to change on a task; nil fields are left as-is.
+// An empty Entity string unlinks the task; a zero DueAt clears the due date.
+type TaskUpdate struct {
+	Title    *string
+	Status   *string
+	Priority *string
+	Notes    *string
+	DueAt    *int64
+	Entity   *string
+}
+
+// TaskFilter selects tasks for ListTasks.
+type TaskFilter struct {
+	Statuses []string // empty means incomplete tasks only
+	Entity   string
+	Limit    int
+}
+
+// ValidTaskStatus reports whether s is a known task status.
+func ValidTaskStatus(s string) bool {
+	switch s {
+	case TaskOpen, TaskInProgress, TaskBlocked, TaskDone, TaskCancelled:
+		return true
+	}
+	return false
+}
+
+// priorityRank converts a priority name to its stored rank.
+func priorityRank(p string) (int, error) {
+	if p == "" {
+		return taskPriorities["medium"], nil
+	}
+	rank, ok := taskPriorities[strings.ToLower(p)]
+	if !ok {
+		return 0, fmt.Errorf("invalid priority %q: use high, medium or low", p)
+	}
+	return rank, nil
+}
+
+// priorityName converts a stored rank back to its name.
+func priorityName(rank int) string {
+	for name, r := range taskPriorities {
+		if r == rank {
+			return name
+		}
+	}
+	return "medium"
+}
+
+// ParseDue parses a due date: ISO date "2026-03-01", RFC 3339 timestamp, or a
+// relative offset into the future like "12h" or "3d". Returns Unix milliseconds.
+func ParseDue(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t.UnixMilli(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UnixMilli(), nil
+	}
+	var n int
+	if strings.HasSuffix(s, "d") {
+		if _, err := fmt.Sscanf(strings.TrimSuffix(s, "d"), "%d", &n); err == nil {
+			return time.Now().Add(time.Duration(n) * 24 * time.Hour).UnixMilli(), nil
+		}
+	}
+	if strings.HasSuffix(s, "h") {
+		if _, err := fmt.Sscanf(strings.TrimSuffix(s, "h"), "%d", &n); err == nil {
+			return time.Now().Add(time.Duration(n) * time.Hour).UnixMilli(), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot parse due %q: use formats like '2026-03-01', '3d' or '12h'", s)
+}
+
+const taskColumns = `
+	t.id, t.title, t.status, t.priority, t.notes, t.due_at,
+	COALESCE(e.name, ''), t.created_at, t.updated_at, t.completed_at`
+
+// scanTask scans a row selected with taskColumns.
+func scanTask(row interface {
+	Scan(...interface{}) error
+}) (*Task, error) {
+	var t Task
+	var rank int
+	var dueAt, completedAt sql.NullInt64
+	err := row.Scan(
+		&t.ID, &t.Title, &t.Status, &rank, &t.Notes, &dueAt,
+		&t.Entity, &t.CreatedAt, &t.UpdatedAt, &completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.Priority = priorityName(rank)
+	if dueAt.Valid {
+		t.DueAt = &dueAt.Int64
+	}
+	if completedAt.Valid {
+		t.CompletedAt = &completedAt.Int64
+	}
+	return &t, nil
+}
+
+// AddTask creates a new open task.
+func (db *DB) AddTask(ctx context.Context, in TaskInput) (*Task, error) {
+	title := strings.TrimSpace(in.Title)
+	if title == "" {
+		return nil, fmt.Errorf("task title cannot be empty")
+	}
+	if len(title) > 1024 {
+		return nil, fmt.Errorf("task title exceeds 1KB limit")
+	}
+	if len(in.Notes) > 10*1024 {
+		return nil, fmt.Errorf("task notes exceed 10KB limit")
+	}
+	rank, err := priorityRank(in.Priority)
+	if err != nil {
+		return nil, err
+	}
+
+	var dueAt sql.NullInt64
+	if in.DueAt != nil {
+		dueAt = sql.NullInt64{Int64: *in.DueAt, Valid: true}
+	}
+
+	// A task links to an existing entity; a misspelled name must not create one.
+	var entityID sql.NullInt64
+	if in.Entity != "" {
+		eid, err := db.activeEntityID(ctx, in.Entity)
+		if err != nil {
+			return nil, err
+		}
+		entityID = sql.NullInt64{Int64: eid, Valid: true}
+	}
+
+	var id int64
+	m := &mutation{op: "task.add", entityID: entityID.Int64}
+	err = db.mutate(ctx, m, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO tasks (title, priority, notes, due_at, entity_id) VALUES (?, ?, ?, ?, ?)
+		`, title, rank, in.Notes, dueAt, entityID)
+		if err != nil {
+			return fmt.Errorf("insert task: %w", err)
+		}
+		id, _ = res.LastInsertId()
+		m.scope.add("tasks", "id = ?", id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.GetTask(ctx, id)
+}
+
+// GetTask returns a task by ID, or nil if it does not exist.
+func (db *DB) GetTask(ctx context.Context, id int64) (*Task, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM tasks t LEFT JOIN entities e ON t.entity_id = e.id
+		WHERE t.id = ?
+	`, id)
+	t, err := scanTask(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return t, err
+}
+
+// UpdateTask applies the non-nil fields of u to the task and returns it.
+// Moving a task to done or cancelled stamps completed_at; reopening clears it.
+func (db *DB) UpdateTask(ctx context.Context, id int64, u TaskUpdate) (*Task, error) {
+	sets := []string{"updated_at = unixepoch('now', 'subsec') * 1000"}
+	args := []interface{}{}
+
+	if u.Title != nil {
+		title := strings.TrimSpace(*u.Title)
+		if title == "" {
+			return nil, fmt.Errorf("task title cannot be empty")
+		}
+		sets = append(sets, "title = ?")
+		args = append(args, title)
+	}
+	if u.Status != nil {
+		if !ValidTaskStatus(*u.Status) {
+			return nil, fmt.Errorf("invalid status %q: use open, in_progress, blocked, done or cancelled", *u.Status)
+		}
+		sets = append(sets, "status = ?")
+		args = append(args, *u.Status)
+		if *u.Status == TaskDone || *u.Status == TaskCancelled {
+			sets = append(sets, "completed_at = COALESCE(completed_at, unixepoch('now', 'subsec') * 1000)")
+		} else {
+			sets = append(sets, "completed_at = NULL")
+		}
+	}
+	if u.Priority != nil {
+		rank, err := priorityRank(*u.Priority)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, "priority = ?")
+		args = append(args, rank)
+	}
+	if u.Notes != nil {
+		sets = append(sets, "notes = ?")
+		args = append(args, *u.Notes)
+	}
+	if u.DueAt != nil {
+		var dueAt sql.NullInt64
+		if *u.DueAt != 0 {
+			dueAt = sql.NullInt64{Int64: *u.DueAt, Valid: true}
+		}
+		sets = append(sets, "due_at = ?")
+		args = append(args, dueAt)
+	}
+	var entityID sql.NullInt64
+	if u.Entity != nil && *u.Entity != "" {
+		eid, err := db.activeEntityID(ctx, *u.Entity)
+		if err != nil {
+			return nil, err
+		}
+		entityID = sql.NullInt64{Int64: eid, Valid: true}
+	}
+	m := &mutation{op: "task.update", scope: scope{}.add("tasks", "id = ?", id)}
+	err := db.mutate(ctx, m, func(tx *sql.Tx) error {
+		_ = tx.QueryRowContext(ctx, `SELECT entity_id FROM tasks WHERE id = ? AND entity_id IS NOT NULL`, id).Scan(&m.entityID)
+		if u.Entity != nil {
+			if entityID.Valid {
+				m.entityID = entityID.Int64
+			}
+			sets = append(sets, "entity_id = ?")
+			args = append(args, entityID)
+		}
+
+		args = append(args, id)
+		res, err := tx.ExecContext(ctx, `UPDATE tasks SET `+strings.Join(sets, ", ")+` WHERE id = ?`, args...)
+		if err != nil {
+			return fmt.Errorf("update task: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("task %d not found", id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.GetTask(ctx, id)
+}
+
+// CompleteTask marks a task as done.
+func (db *DB) CompleteTask(ctx context.Context, id int64) (*Task, error) {
+	done := TaskDone
+	return db.UpdateTask(ctx, id, TaskUpdate{Status: &done})
+}
+
+// ListTasks returns tasks sorted by priority (high first), then age (oldest first).
+func (db *DB) ListTasks(ctx context.Context, f TaskFilter) ([]Task, error) {
+	if f.Limit <= 0 {
+		f.Limit = 50
+	}
+	statuses := f.Statuses
+	if len(statuses) == 0 {
+		statuses = incompleteStatuses
+	}
+	for _, s := range statuses {
+		if !ValidTaskStatus(s) {
+			return nil, fmt.Errorf("invalid status %q", s)
+		}
+	}
+
+	query := `
+		SELECT ` + taskColumns + `
+		FROM tasks t LEFT JOIN entities e ON t.entity_id = e.id
+		WHERE t.status IN (` + placeholders(len(statuses)) + `)`
+	args := []interface{}{}
+	for _, s := range statuses {
+		args = append(args, s)
+	}
+	if f.Entity != "" {
+		query += " AND lower(e.name) = lower(?)"
+		args = append(args, f.Entity)
+	}
+	query += " ORDER BY t.priority ASC, t.created_at ASC, t.id ASC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []Task
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *t)
+	}
+	return tasks, rows.Err()
+}
@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Token scopes. Read covers searching and listing, write covers storing and
+// linking, and delete covers forgetting, unlinking and undo.
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+)
+
+// tokenPrefix marks aimemo bearer tokens so they are easy to spot in config
+// files and secret scanners.
+const tokenPrefix = "aimemo_"
+
+// Token is a bearer token for the network transports. The secret itself is
+// only returned by CreateToken; the database keeps a hash.
+type Token struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	Contexts   []string `json:"contexts"` // allowed contexts; empty allows all
+	CreatedAt  int64    `json:"created_at"`
+	LastUsedAt *int64   `json:"last_used_at,omitempty"`
+}
+
+// ValidScope reports whether s is a known token scope.
+func ValidScope(s string) bool {
+	switch s {
+	case ScopeRead, ScopeWrite, ScopeDelete:
+		return true
+	}
+	return false
+}
+
+// HasScope reports whether the token grants scope.
+func (t *Token) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// CreateToken stores a new token and returns its secret, which cannot be
+// recovered later.
+func (db *DB) CreateToken(ctx context.Context, name string, scopes, contexts []string) (string, *Token, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("token name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required: %s, %s or %s", ScopeRead, ScopeWrite, ScopeDelete)
+	}
+	for _, s := range scopes {
+		if !ValidScope(s) {
+			return "", nil, fmt.Errorf("invalid scope %q: use %s, %s or %s", s, ScopeRead, ScopeWrite, ScopeDelete)
+		}
+	}
+	if contexts == nil {
+		contexts = []string{}
+	}
+	scopesJSON, _ := json.Marshal(scopes)
+	contextsJSON, _ := json.Marshal(contexts)
+
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", nil, err
+	}
+	secret := tokenPrefix + hex.EncodeToString(b[:])
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM tokens WHERE name = ?)`, name).Scan(&exists); err != nil {
+		return "", nil, err
+	}
+	if exists {
+		return "", nil, fmt.Errorf("token %q already exists", name)
+	}
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO tokens (name, hash, scopes, contexts) VALUES (?, ?, ?, ?)
+	`, name, hashToken(secret), string(scopesJSON), string(contextsJSON))
+	if err != nil {
+		return "", nil, fmt.Errorf("create token: %w", err)
+	}
+	id, _ := res.LastInsertId()
+	t, err := db.token(ctx, `id = ?`, id)
+	return secret, t, err
+}
+
+// ListTokens returns all tokens, oldest first.
+func (db *DB) ListTokens(ctx context.Context) ([]Token, error) {
+	rows, err := db.QueryContext(ctx, `SELECT `+tokenColumns+` FROM tokens ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tokens []Token
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// RevokeToken deletes the named token.
+func (db *DB) RevokeToken(ctx context.Context, name string) error {
+	res, err := db.ExecContext(ctx, `DELETE FROM tokens WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("token %q not found", name)
+	}
+	return nil
+}
+
+// HasTokens reports whether any token has been created.
+func (db *DB) HasTokens(ctx context.Context) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM tokens)`).Scan(&exists)
+	return exists, err
+}
+
+// AuthenticateToken returns the token with the given secret, or nil if no
+// such token exists. Its last use is recorded to the minute.
+func (db *DB) AuthenticateToken(ctx context.Context, secret string) (*Token, error) {
+	t, err := db.token(ctx, `hash = ?`, hashToken(secret))
+	if err != nil || t == nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	if t.LastUsedAt == nil || now-*t.LastUsedAt >= time.Minute.Milliseconds() {
+		if _, err := db.ExecContext(ctx, `UPDATE tokens SET last_used_at = ? WHERE id = ?`, now, t.ID); err != nil {
+			return nil, err
+		}
+		t.LastUsedAt = &now
+	}
+	return t, nil
+}
+
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+const tokenColumns = `id, name, scopes, contexts, created_at, last_used_at`
+
+// token returns the token matching cond, or nil if there is none.
+func (db *DB) token(ctx context.Context, cond string, args ...interface{}) (*Token, error) {
+	t, err := scanToken(db.QueryRowContext(ctx, `SELECT `+tokenColumns+` FROM tokens WHERE `+cond, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return t, err
+}
+
+func scanToken(row interface{ Scan(...any) error }) (*Token, error) {
+	var t Token
+	var scopes, contexts string
+	if err := row.Scan(&t.ID, &t.Name, &scopes, &contexts, &t.CreatedAt, &t.LastUsedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &t.Scopes); err != nil {
+		t.Scopes = []string{}
+	}
+	if err := json.Unmarshal([]byte(contexts), &t.Contexts); err != nil {
+		t.Contexts = []string{}
+	}
+	return &t, nil
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// TrashedEntity is a soft-deleted entity awaiting restore or purge.
+type TrashedEntity struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	EntityType   string `json:"entity_type"`
+	DeletedAt    int64  `json:"deleted_at"`
+	Observations int    `json:"observations"`
+}
+
+// ListTrash returns soft-deleted entities, most recently deleted first.
+func (db *DB) ListTrash(ctx context.Context) ([]TrashedEntity, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT e.id, e.name, e.entity_type, e.deleted_at,
+			(SELECT COUNT(*) FROM observations o WHERE o.entity_id = e.id)
+		FROM entities e
+		WHERE e.deleted_at IS NOT NULL
+		ORDER BY e.deleted_at DESC, e.id DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var trash []TrashedEntity
+	for rows.Next() {
+		var t TrashedEntity
+		if err := rows.Scan(&t.ID, &t.Name, &t.EntityType, &t.DeletedAt, &t.Observations); err != nil {
+			return nil, err
+		}
+		trash = append(trash, t)
+	}
+	return trash, rows.Err()
+}
+
+// RestoreEntity brings a soft-deleted entity back with its observations,
+// relations and aliases. The entities_fts_soft_restore trigger re-indexes it.
+// Restoring fails if an active entity has since taken the name.
+func (db *DB) RestoreEntity(ctx context.Context, name string) (*Entity, error) {
+	var id int64
+	var trashedName string
+	err := db.QueryRowContext(ctx, `
+		SELECT id, name FROM entities
+		WHERE lower(name) = lower(?) AND deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC LIMIT 1
+	`, name).Scan(&id, &trashedName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("entity %q is not in the trash", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, active, err := resolveEntity(ctx, db, trashedName); err == nil {
+		return nil, fmt.Errorf("active entity %q already uses the name %q; rename it first", active, trashedName)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	m := &mutation{op: "entity.restore", entityID: id, scope: scope{}.add("entities", "id = ?", id)}
+	err = db.mutate(ctx, m, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE entities SET deleted_at = NULL, updated_at = ? WHERE id = ?
+		`, time.Now().UnixMilli(), id)
+		if err != nil {
+			return fmt.Errorf("restore entity: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db.GetEntityByID(ctx, id)
+}
+
+// PurgeTrash permanently deletes entities soft-deleted before the cutoff
+// (Unix ms), with their observations and relations. Each purged entity is a
+// separate change log entry. It returns the number of entities purged.
+func (db *DB) PurgeTrash(ctx context.Context, before int64) (int64, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id FROM entities WHERE deleted_at IS NOT NULL AND deleted_at < ? ORDER BY id
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("purge trash: %w", err)
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, id := range ids {
+		m := &mutation{op: "entity.purge", entityID: id, scope: entityScope("id = ?", id)}
+		err := db.mutate(ctx, m, func(tx *sql.Tx) error {
+			res, err := tx.ExecContext(ctx, `DELETE FROM entities WHERE id = ? AND deleted_at IS NOT NULL`, id)
+			if err != nil {
+				return err
+			}
+			n, _ := res.RowsAffected()
+			purged += n
+			return nil
+		})
+		if err != nil {
+			return purged, fmt.Errorf("purge trash: %w", err)
+		}
+	}
+	return purged, nil
+}
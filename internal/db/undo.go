@@ -0,0 +1,294 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// unverifiedColumns change on reads (see GetEntity) without a change log
+// entry, so undo does not treat a difference in them as a conflict.
+var unverifiedColumns = map[string]bool{"access_count": true, "last_accessed": true}
+
+// PlanUndo returns the changes an undo would reverse, newest first: the
+// latest steps changes, or every change since the given Unix ms time (both
+// may be combined). Changes already undone and undo entries themselves are
+// skipped. With neither limit set it plans the single latest change.
+func (db *DB) PlanUndo(ctx context.Context, steps int, since int64) ([]Change, error) {
+	if steps <= 0 && since <= 0 {
+		steps = 1
+	}
+	return db.ListChanges(ctx, ChangeFilter{Limit: steps, Since: since, Undoable: true})
+}
+
+// PlanUndoIDs returns the changes with the given IDs, newest first, for
+// applying an undo that was previewed earlier. It fails unless the IDs are
+// still exactly the newest undoable changes, so a change logged or undone
+// since the preview is never reversed, or skipped, unseen.
+func (db *DB) PlanUndoIDs(ctx context.Context, ids []int64) ([]Change, error) {
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no change IDs to undo")
+	}
+	want := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if want[id] {
+			return nil, fmt.Errorf("change #%d is listed twice", id)
+		}
+		want[id] = true
+	}
+	newest, err := db.ListChanges(ctx, ChangeFilter{Limit: len(ids), Undoable: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range newest {
+		delete(want, c.ID)
+	}
+	if len(want) > 0 {
+		return nil, fmt.Errorf("changes %s are no longer the newest undoable changes; preview again", formatIDs(sortedIDs(want)))
+	}
+	return newest, nil
+}
+
+func sortedIDs(set map[int64]bool) []int64 {
+	ids := make([]int64, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func formatIDs(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = fmt.Sprintf("#%d", id)
+	}
+	return strings.Join(s, ", ")
+}
+
+// Undo reverses the given changes, newest first, in one transaction: rows a
+// change created are deleted and rows it updated or deleted are put back as
+// they were, including hard-deleted entities with their observations,
+// relations and aliases. Each reversal is logged as an "undo" entry. It
+// fails without changing anything if a change was already undone or its
+// rows have been modified since by a change not being undone.
+func (db *DB) Undo(ctx context.Context, changes []Change) error {
+	changes = append([]Change(nil), changes...)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ID > changes[j].ID })
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, c := range changes {
+		var undone int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM changes WHERE undoes = ?`, c.ID).Scan(&undone); err != nil {
+			return err
+		}
+		if undone > 0 {
+			return fmt.Errorf("change #%d is already undone", c.ID)
+		}
+		if c.Undoes != nil {
+			return fmt.Errorf("change #%d is an undo and cannot be undone", c.ID)
+		}
+
+		before, after := decodeRowSet(c.Before), decodeRowSet(c.After)
+		sc := scope{}
+		for _, t := range loggedTables {
+			if ids := rowIDs(append(append([]map[string]any{}, before[t.name]...), after[t.name]...)); ids != "" {
+				sc.add(t.name, "id IN ("+ids+")")
+			}
+		}
+		m := &mutation{op: "undo", entity: c.Entity, undoes: c.ID, scope: sc}
+		if c.EntityID != nil {
+			m.entityID = *c.EntityID
+		}
+		err := db.mutateTx(ctx, tx, m, func(tx *sql.Tx) error {
+			return revert(ctx, tx, before, after)
+		})
+		if err != nil {
+			return fmt.Errorf("undo change #%d (%s %s): %w", c.ID, c.Op, c.Entity, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	// Observations put back lost their vectors when they were deleted.
+	for _, c := range changes {
+		if c.EntityID != nil {
+			db.embedEntity(ctx, *c.EntityID)
+		}
+	}
+	return nil
+}
+
+// revert restores the before image of a change whose after image is still
+// current: rows only in after are deleted (children first), and rows in
+// before are updated or re-inserted (parents first).
+func revert(ctx context.Context, tx *sql.Tx, before, after rowSet) error {
+	for _, t := range loggedTables {
+		for _, r := range after[t.name] {
+			cur, err := currentRow(ctx, tx, t, r["id"])
+			if err != nil {
+				return err
+			}
+			if cur == nil || !sameRow(t, cur, r) {
+				return fmt.Errorf("%s %s has changed since; undo the later changes first", t.singular, quoteShort(fmt.Sprint(r[t.label])))
+			}
+		}
+		for _, r := range before[t.name] {
+			if hasRow(after[t.name], r["id"]) {
+				continue
+			}
+			cur, err := currentRow(ctx, tx, t, r["id"])
+			if err != nil {
+				return err
+			}
+			if cur != nil {
+				return fmt.Errorf("%s %s already exists", t.singular, quoteShort(fmt.Sprint(r[t.label])))
+			}
+		}
+	}
+
+	for i := len(loggedTables) - 1; i >= 0; i-- {
+		t := loggedTables[i]
+		for _, r := range after[t.name] {
+			if hasRow(before[t.name], r["id"]) {
+				continue
+			}
+			id := sqlValue(r["id"])
+			if t.name == "entities" {
+				if err := checkNoDependents(ctx, tx, id, fmt.Sprint(r["name"])); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+t.name+` WHERE id = ?`, id); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, t := range loggedTables {
+		for _, r := range before[t.name] {
+			if err := restoreRow(ctx, tx, t, r, hasRow(after[t.name], r["id"])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasRow reports whether rows contains a row with the given id.
+func hasRow(rows []map[string]any, id any) bool {
+	for _, r := range rows {
+		if fmt.Sprint(r["id"]) == fmt.Sprint(id) {
+			return true
+		}
+	}
+	return false
+}
+
+// currentRow reads one row of t by id, or returns nil if it does not exist.
+func currentRow(ctx context.Context, tx *sql.Tx, t loggedTable, id any) (map[string]any, error) {
+	rs, err := snapshot(ctx, tx, scope{t.name: {"id = ?", []interface{}{sqlValue(id)}}}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rows := rs[t.name]; len(rows) > 0 {
+		return rows[0], nil
+	}
+	return nil, nil
+}
+
+// sameRow compares a live row with a logged one.
+func sameRow(t loggedTable, cur, logged map[string]any) bool {
+	for _, col := range t.columns {
+		if !unverifiedColumns[col] && fmt.Sprint(cur[col]) != fmt.Sprint(logged[col]) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkNoDependents refuses to delete an entity that later changes attached
+// observations, aliases, relations or tasks to.
+func checkNoDependents(ctx context.Context, tx *sql.Tx, id interface{}, name string) error {
+	var n int
+	err := tx.QueryRowContext(ctx, `
+		SELECT (SELECT COUNT(*) FROM observations WHERE entity_id = ?1)
+			+ (SELECT COUNT(*) FROM entity_aliases WHERE entity_id = ?1)
+			+ (SELECT COUNT(*) FROM relations WHERE from_id = ?1 OR to_id = ?1)
+			+ (SELECT COUNT(*) FROM tasks WHERE entity_id = ?1)
+	`, id).Scan(&n)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return fmt.Errorf("entity %s has been added to since; undo the later changes first", quoteShort(name))
+	}
+	return nil
+}
+
+// restoreRow writes a logged row back, updating it in place if it exists.
+// An entity is inserted active and then trashed if need be, so the FTS
+// triggers index it exactly when it is active.
+func restoreRow(ctx context.Context, tx *sql.Tx, t loggedTable, r map[string]any, exists bool) error {
+	var cols []string
+	var args []interface{}
+	for _, col := range t.columns {
+		if col == "id" {
+			continue
+		}
+		cols = append(cols, col)
+		args = append(args, sqlValue(r[col]))
+	}
+	id := sqlValue(r["id"])
+
+	if exists {
+		_, err := tx.ExecContext(ctx, `UPDATE `+t.name+` SET `+strings.Join(cols, " = ?, ")+` = ? WHERE id = ?`, append(args, id)...)
+		return restoreErr(t, r, err)
+	}
+
+	deletedAt := sqlValue(r["deleted_at"])
+	if t.name == "entities" {
+		for i, col := range cols {
+			if col == "deleted_at" {
+				args[i] = nil
+			}
+		}
+	}
+	_, err := tx.ExecContext(ctx, `INSERT INTO `+t.name+` (id, `+strings.Join(cols, ", ")+`) VALUES (?, `+placeholders(len(cols))+`)`, append([]interface{}{id}, args...)...)
+	if err != nil {
+		return restoreErr(t, r, err)
+	}
+	if t.name == "entities" && deletedAt != nil {
+		_, err = tx.ExecContext(ctx, `UPDATE entities SET deleted_at = ? WHERE id = ?`, deletedAt, id)
+	}
+	return err
+}
+
+func restoreErr(t loggedTable, r map[string]any, err error) error {
+	if err != nil {
+		return fmt.Errorf("restore %s %s: %w", t.singular, quoteShort(fmt.Sprint(r[t.label])), err)
+	}
+	return nil
+}
+
+// sqlValue converts a value decoded from the change log to a query argument.
+func sqlValue(v any) interface{} {
+	if n, ok := v.(json.Number); ok {
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+		return n.String()
+	}
+	return v
+}
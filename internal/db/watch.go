@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DataVersion returns SQLite's data_version for the database's connection.
+// It changes whenever another connection, such as another aimemo process,
+// commits to the file, but not on this connection's own writes.
+func (db *DB) DataVersion(ctx context.Context) (int64, error) {
+	var v int64
+	err := db.QueryRowContext(ctx, `PRAGMA data_version`).Scan(&v)
+	return v, err
+}
+
+// LastChangeID returns the ID of the newest change log entry, or 0.
+func (db *DB) LastChangeID(ctx context.Context) (int64, error) {
+	var id int64
+	err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM changes`).Scan(&id)
+	return id, err
+}
+
+// Touched summarizes what a run of change log entries affected.
+type Touched struct {
+	LastID   int64    // newest change summarized
+	Entities []string // entities whose row, observations, aliases or relations changed, by current and former name
+	Types    []string // entity types of those entities, current and former
+	Days     []string // local dates of journal entries added or removed
+	Listed   bool     // an entity or journal entry appeared, disappeared or was renamed
+}
+
+// TouchedSince summarizes the change log entries after afterID, whichever
+// process wrote them.
+func (db *DB) TouchedSince(ctx context.Context, afterID int64) (*Touched, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, before, after FROM changes WHERE id > ? ORDER BY id`, afterID)
+	if err != nil {
+		return nil, err
+	}
+	t := &Touched{LastID: afterID}
+	var before, after []rowSet
+	for rows.Next() {
+		var b, a sql.NullString
+		if err := rows.Scan(&t.LastID, &b, &a); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		before = append(before, decodeRowSet([]byte(b.String)))
+		after = append(after, decodeRowSet([]byte(a.String)))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	names, types, days := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	ids := map[int64]bool{} // entities touched through other tables
+	for i := range before {
+		b, a := rowsByID(before[i]["entities"]), rowsByID(after[i]["entities"])
+		for id, br := range b {
+			if ar, ok := a[id]; !ok || br["name"] != ar["name"] || br["deleted_at"] != ar["deleted_at"] {
+				t.Listed = true
+			}
+		}
+		for id := range a {
+			if _, ok := b[id]; !ok {
+				t.Listed = true
+			}
+		}
+		if len(before[i]["journal"]) != len(after[i]["journal"]) {
+			t.Listed = true
+		}
+
+		for _, rs := range []rowSet{before[i], after[i]} {
+			for _, r := range rs["entities"] {
+				names[fmt.Sprint(r["name"])] = true
+				types[fmt.Sprint(r["entity_type"])] = true
+			}
+			for _, col := range []struct{ table, column string }{
+				{"observations", "entity_id"},
+				{"entity_aliases", "entity_id"},
+				{"relations", "from_id"},
+				{"relations", "to_id"},
+			} {
+				for _, r := range rs[col.table] {
+					if id, ok := sqlValue(r[col.column]).(int64); ok {
+						ids[id] = true
+					}
+				}
+			}
+			for _, r := range rs["journal"] {
+				if ms, ok := sqlValue(r["created_at"]).(int64); ok {
+					days[time.UnixMilli(ms).Format("2006-01-02")] = true
+				}
+			}
+		}
+	}
+
+	if len(ids) > 0 {
+		list := make([]string, 0, len(ids))
+		for id := range ids {
+			list = append(list, fmt.Sprint(id))
+		}
+		rows, err := db.QueryContext(ctx, `SELECT name, entity_type FROM entities WHERE id IN (`+strings.Join(list, ",")+`)`)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var name, entityType string
+			if err := rows.Scan(&name, &entityType); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			names[name], types[entityType] = true, true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	t.Entities, t.Types, t.Days = sortedKeys(names), sortedKeys(types), sortedKeys(days)
+	return t, nil
+}
+
+// rowsByID indexes logged rows by their id column.
+func rowsByID(rows []map[string]any) map[int64]map[string]any {
+	m := make(map[int64]map[string]any, len(rows))
+	for _, r := range rows {
+		if id, ok := sqlValue(r["id"]).(int64); ok {
+			m[id] = r
+		}
+	}
+	return m
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+// Package embed turns text into vectors for semantic search.
+//
+// An Embedder is chosen by the [embeddings] config section: the built-in
+// hashed n-gram embedder works offline with no model files, while the
+// command and http providers delegate to a local model.
+package embed
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Provider names accepted by New.
+const (
+	ProviderHashed  = "hashed"
+	ProviderCommand = "command"
+	ProviderHTTP    = "http"
+)
+
+// DefaultTimeout bounds one call to an external embedder.
+const DefaultTimeout = 30 * time.Second
+
+// Embedder maps texts to vectors. Vectors from the same embedder are
+// comparable by cosine similarity; their length may differ between embedders.
+type Embedder interface {
+	// Name identifies the embedder and its model. Stored vectors whose name
+	// differs from the current embedder's are stale and get re-embedded.
+	Name() string
+
+	// Embed returns one vector per text, in order.
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Options selects and configures an Embedder.
+type Options struct {
+	Provider   string        // hashed (default) | command | http
+	Dimensions int           // hashed: vector length (default 512)
+	Command    []string      // command: argv of the embedding program
+	URL        string        // http: OpenAI-compatible embeddings endpoint
+	Model      string        // http: model name sent with each request
+	Timeout    time.Duration // command, http: per-call timeout (default 30s)
+}
+
+// New returns the embedder described by o.
+func New(o Options) (Embedder, error) {
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+	switch o.Provider {
+	case "", ProviderHashed:
+		return NewHashed(o.Dimensions), nil
+	case ProviderCommand:
+		if len(o.Command) == 0 {
+			return nil, fmt.Errorf("embeddings: provider %q needs command", o.Provider)
+		}
+		return &Command{Argv: o.Command, Timeout: o.Timeout}, nil
+	case ProviderHTTP:
+		if o.URL == "" {
+			return nil, fmt.Errorf("embeddings: provider %q needs url", o.Provider)
+		}
+		return &HTTP{URL: o.URL, Model: o.Model, Timeout: o.Timeout}, nil
+	default:
+		return nil, fmt.Errorf("embeddings: unknown provider %q (use hashed, command or http)", o.Provider)
+	}
+}
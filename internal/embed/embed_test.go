@@ -0,0 +1,90 @@
+package embed
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cosine(a, b []float32) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+func TestHashed(t *testing.T) {
+	h := NewHashed(0)
+	assert.Equal(t, "hashed-ngram-v1/512", h.Name())
+
+	vecs, err := h.Embed(context.Background(), []string{
+		"token expiry",
+		"access tokens expire after an hour",
+		"invoices are sent monthly",
+		"token expiry",
+		"",
+	})
+	require.NoError(t, err)
+	require.Len(t, vecs, 5)
+	assert.Len(t, vecs[0], DefaultDimensions)
+	assert.Equal(t, vecs[0], vecs[3], "deterministic")
+	assert.InDelta(t, 1, cosine(vecs[0], vecs[0]), 1e-6, "unit length")
+	assert.Greater(t, cosine(vecs[0], vecs[1]), cosine(vecs[0], vecs[2])+0.1)
+	assert.Equal(t, make([]float32, DefaultDimensions), vecs[4])
+}
+
+func TestNew(t *testing.T) {
+	e, err := New(Options{})
+	require.NoError(t, err)
+	assert.IsType(t, &Hashed{}, e)
+
+	_, err = New(Options{Provider: ProviderCommand})
+	assert.Error(t, err)
+	_, err = New(Options{Provider: ProviderHTTP})
+	assert.Error(t, err)
+	_, err = New(Options{Provider: "magic"})
+	assert.Error(t, err)
+}
+
+func TestCommand(t *testing.T) {
+	e, err := New(Options{Provider: ProviderCommand, Command: []string{
+		"sh", "-c", `grep -q '"input":\["a","b"\]' && echo '{"embeddings": [[1, 0], [0, 1]]}'`,
+	}})
+	require.NoError(t, err)
+	vecs, err := e.Embed(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vecs)
+
+	_, err = e.Embed(context.Background(), []string{"c"})
+	assert.Error(t, err, "non-zero exit")
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "nomic-embed-text", req.Model)
+		assert.Equal(t, []string{"a", "b"}, req.Input)
+		// Out of order on purpose: results are matched by index.
+		w.Write([]byte(`{"data": [{"index": 1, "embedding": [0, 1]}, {"index": 0, "embedding": [1, 0]}]}`))
+	}))
+	defer srv.Close()
+
+	e, err := New(Options{Provider: ProviderHTTP, URL: srv.URL, Model: "nomic-embed-text"})
+	require.NoError(t, err)
+	vecs, err := e.Embed(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vecs)
+}
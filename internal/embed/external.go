@@ -0,0 +1,114 @@
+package embed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Command runs an external program per batch. It writes
+// {"input": ["text", ...]} to the program's stdin and expects
+// {"embeddings": [[0.1, ...], ...]} on stdout, one vector per input.
+type Command struct {
+	Argv    []string
+	Timeout time.Duration
+}
+
+// Name implements Embedder.
+func (c *Command) Name() string {
+	return "command:" + strings.Join(c.Argv, " ")
+}
+
+// Embed implements Embedder.
+func (c *Command) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	in, err := json.Marshal(map[string]any{"input": texts})
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, c.Argv[0], c.Argv[1:]...)
+	cmd.Stdin = bytes.NewReader(in)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("embedding command %q: %w: %s", c.Argv[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil, fmt.Errorf("embedding command %q: invalid output: %w", c.Argv[0], err)
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("embedding command %q: got %d vectors for %d inputs", c.Argv[0], len(resp.Embeddings), len(texts))
+	}
+	return resp.Embeddings, nil
+}
+
+// HTTP calls an OpenAI-compatible embeddings endpoint, as served locally by
+// Ollama (/v1/embeddings), llama.cpp or LM Studio.
+type HTTP struct {
+	URL     string
+	Model   string
+	Timeout time.Duration
+}
+
+// Name implements Embedder.
+func (h *HTTP) Name() string {
+	return "http:" + h.URL + "#" + h.Model
+}
+
+// Embed implements Embedder.
+func (h *HTTP) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+
+	body, err := json.Marshal(map[string]any{"model": h.Model, "input": texts})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request: %w", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, fmt.Errorf("embedding request: %s: %s", res.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("embedding response: %w", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("embedding response: got %d vectors for %d inputs", len(resp.Data), len(texts))
+	}
+	sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i].Index < resp.Data[j].Index })
+	out := make([][]float32, len(resp.Data))
+	for i, d := range resp.Data {
+		out[i] = d.Embedding
+	}
+	return out, nil
+}
@@ -0,0 +1,116 @@
+package embed
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// DefaultDimensions is the vector length of the hashed embedder.
+const DefaultDimensions = 512
+
+// stopwords carry no topic and are left out of word features.
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "by": true, "for": true, "from": true, "in": true, "is": true,
+	"it": true, "of": true, "on": true, "or": true, "that": true, "the": true,
+	"this": true, "to": true, "was": true, "with": true,
+}
+
+// Hashed is a deterministic, offline embedder. It hashes words, adjacent
+// word pairs and character trigrams of each word into a fixed number of
+// signed buckets (the hashing trick), so texts sharing words or word stems
+// ("expire", "expired", "expiry") land close together. It does not know
+// synonyms; use an external model for that.
+type Hashed struct {
+	dims int
+}
+
+// NewHashed returns a hashed embedder producing vectors of length dims
+// (DefaultDimensions if dims <= 0).
+func NewHashed(dims int) *Hashed {
+	if dims <= 0 {
+		dims = DefaultDimensions
+	}
+	return &Hashed{dims: dims}
+}
+
+// Name implements Embedder.
+func (h *Hashed) Name() string {
+	return fmt.Sprintf("hashed-ngram-v1/%d", h.dims)
+}
+
+// Embed implements Embedder.
+func (h *Hashed) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = h.vector(t)
+	}
+	return out, nil
+}
+
+func (h *Hashed) vector(text string) []float32 {
+	v := make([]float32, h.dims)
+	words := words(text)
+	for i, w := range words {
+		if i > 0 {
+			h.add(v, "b:"+words[i-1]+" "+w, 0.5)
+		}
+		if stopwords[w] {
+			continue
+		}
+		h.add(v, "w:"+w, 1)
+		// Weight trigrams so each word's trigrams together count as much as
+		// the word itself, however long it is.
+		grams := trigrams(w)
+		for _, g := range grams {
+			h.add(v, "g:"+g, float32(1/math.Sqrt(float64(len(grams)))))
+		}
+	}
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range v {
+			v[i] *= scale
+		}
+	}
+	return v
+}
+
+// add hashes feature into a bucket of v, with a sign taken from the hash so
+// collisions cancel out on average instead of accumulating.
+func (h *Hashed) add(v []float32, feature string, weight float32) {
+	f := fnv.New64a()
+	f.Write([]byte(feature))
+	sum := f.Sum64()
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	v[sum%uint64(len(v))] += weight
+}
+
+// words lowercases text and splits it into runs of letters and digits.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// trigrams returns the character trigrams of w padded with word boundaries.
+func trigrams(w string) []string {
+	r := []rune("^" + w + "$")
+	if len(r) < 3 {
+		return nil
+	}
+	grams := make([]string, 0, len(r)-2)
+	for i := 0; i+3 <= len(r); i++ {
+		grams = append(grams, string(r[i:i+3]))
+	}
+	return grams
+}
@@ -64,6 +64,12 @@ func FindProjectDB(context string) (string, error) {
 	return globalDBPath(context)
 }
 
+// ContextDBPath returns the database for a context in the same directory as
+// the database at dbPath, as FindProjectDB would resolve it from there.
+func ContextDBPath(dbPath, context string) string {
+	return filepath.Join(filepath.Dir(dbPath), dbName(context))
+}
+
 // ConfigPath returns the path to the user's config file.
 func ConfigPath() (string, error) {
 	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
@@ -75,3 +81,16 @@ func ConfigPath() (string, error) {
 	}
 	return filepath.Join(home, ".aimemo", "config.toml"), nil
 }
+
+// DaemonSocket returns the per-user Unix socket of 'aimemo daemon':
+// $XDG_RUNTIME_DIR/aimemo/daemon.sock, or ~/.aimemo/daemon.sock.
+func DaemonSocket() (string, error) {
+	if xdg := os.Getenv("XDG_RUNTIME_DIR"); xdg != "" {
+		return filepath.Join(xdg, "aimemo", "daemon.sock"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine home directory: %w", err)
+	}
+	return filepath.Join(home, ".aimemo", "daemon.sock"), nil
+}
@@ -16,6 +16,12 @@ func TestDbName(t *testing.T) {
 	assert.Equal(t, "memory-my-context.db", dbName("my-context"))
 }
 
+func TestContextDBPath(t *testing.T) {
+	dir := filepath.Join("proj", ".aimemo")
+	assert.Equal(t, filepath.Join(dir, "memory.db"), ContextDBPath(filepath.Join(dir, "memory-ops.db"), ""))
+	assert.Equal(t, filepath.Join(dir, "memory-ops.db"), ContextDBPath(filepath.Join(dir, "memory.db"), "Ops"))
+}
+
 func TestSanitizeContext(t *testing.T) {
 	assert.Equal(t, "ops", SanitizeContext("ops"))
 	assert.Equal(t, "my-context", SanitizeContext("my-context"))
@@ -0,0 +1,97 @@
+package mcp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/MyAgentHubs/aimemo/internal/locate"
+)
+
+// errForbidden marks tool calls rejected by authorize.
+var errForbidden = errors.New("forbidden")
+
+// toolScopes is the token scope each tool needs. Some calls need another
+// scope depending on their arguments; see callScope.
+var toolScopes = map[string]string{
+	"memory_context": db.ScopeRead,
+	"memory_search":  db.ScopeRead,
+	"memory_graph":   db.ScopeRead,
+	"memory_store":   db.ScopeWrite,
+	"memory_link":    db.ScopeWrite,
+	"memory_task":    db.ScopeWrite,
+	"memory_restore": db.ScopeWrite,
+	"memory_merge":   db.ScopeDelete, // removes the merged entity
+	"memory_forget":  db.ScopeDelete,
+	"memory_unlink":  db.ScopeDelete, // including rename_to, which rewrites every edge of a type
+	"memory_undo":    db.ScopeDelete,
+}
+
+// callScope returns the scope a tool call needs: listing tasks or the trash
+// and previewing an undo only read.
+func callScope(name string, args json.RawMessage) string {
+	var p struct {
+		Name    string `json:"name"`
+		Action  string `json:"action"`
+		Confirm bool   `json:"confirm"`
+	}
+	_ = json.Unmarshal(args, &p)
+	switch {
+	case name == "memory_task" && p.Action == "list",
+		name == "memory_restore" && p.Name == "",
+		name == "memory_undo" && !p.Confirm:
+		return db.ScopeRead
+	}
+	return toolScopes[name]
+}
+
+// authorize checks a tool call against the client's token. A nil token,
+// as on stdio, permits everything.
+func authorize(token *db.Token, name string, args json.RawMessage) error {
+	if token == nil {
+		return nil
+	}
+	scope := callScope(name, args)
+	if !token.HasScope(scope) {
+		return fmt.Errorf("%w: token %q lacks the %s scope needed for %s", errForbidden, token.Name, scope, name)
+	}
+	if context := contextArg(args); !allowsContext(token, context) {
+		if context == "" {
+			context = "default"
+		}
+		return fmt.Errorf("%w: token %q may not use context %q", errForbidden, token.Name, context)
+	}
+	return nil
+}
+
+// authorizeResources checks a resources request against the client's token.
+// Resources are read from the default database.
+func authorizeResources(token *db.Token) error {
+	if token == nil {
+		return nil
+	}
+	if !token.HasScope(db.ScopeRead) {
+		return fmt.Errorf("%w: token %q lacks the %s scope needed for resources", errForbidden, token.Name, db.ScopeRead)
+	}
+	if !allowsContext(token, "") {
+		return fmt.Errorf("%w: token %q may not use context %q", errForbidden, token.Name, "default")
+	}
+	return nil
+}
+
+// allowsContext reports whether the token may use the named context. The
+// empty context and "default" both name the default database.
+func allowsContext(token *db.Token, context string) bool {
+	if len(token.Contexts) == 0 {
+		return true
+	}
+	key := func(c string) string {
+		if c = locate.SanitizeContext(c); c == "" {
+			return "default"
+		}
+		return c
+	}
+	return slices.ContainsFunc(token.Contexts, func(c string) bool { return key(c) == key(context) })
+}
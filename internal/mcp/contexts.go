@@ -0,0 +1,207 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+	"github.com/MyAgentHubs/aimemo/internal/locate"
+)
+
+// contextIdleTimeout is how long a per-context database may sit unused before
+// the pool closes it. The server's default database is never closed by the pool.
+const contextIdleTimeout = 5 * time.Minute
+
+// target is the database that serves a single tool call.
+type target struct {
+	db      *db.DB
+	path    string
+	context string
+}
+
+// pooledDB is a cached per-context database handle.
+type pooledDB struct {
+	db       *db.DB
+	path     string
+	refs     int // in-flight tool calls using this handle
+	lastUsed time.Time
+}
+
+// dbPool opens and caches one db.DB per named context, keyed by resolved path.
+// Handles that have been idle for longer than idle are closed by a janitor
+// goroutine that is started on first use and stopped by close.
+type dbPool struct {
+	resolve func(context string) (string, error)
+	open    func(path string) (*db.DB, error)
+	idle    time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*pooledDB
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newDBPool() *dbPool {
+	return &dbPool{
+		resolve: locate.FindProjectDB,
+		open:    db.Open,
+		idle:    contextIdleTimeout,
+		entries: map[string]*pooledDB{},
+	}
+}
+
+// acquire returns the database for path, opening it if needed.
+// The caller must call release(path) when the tool call is finished.
+func (p *dbPool) acquire(path string) (*db.DB, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if e, ok := p.entries[path]; ok {
+		e.refs++
+		e.lastUsed = time.Now()
+		return e.db, nil
+	}
+
+	database, err := p.open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open db %s: %w", path, err)
+	}
+	p.entries[path] = &pooledDB{db: database, path: path, refs: 1, lastUsed: time.Now()}
+	p.startJanitor()
+	return database, nil
+}
+
+// release marks one use of the database at path as finished.
+func (p *dbPool) release(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e, ok := p.entries[path]; ok {
+		e.refs--
+		e.lastUsed = time.Now()
+	}
+}
+
+// startJanitor launches the idle sweeper once. Must be called with p.mu held.
+func (p *dbPool) startJanitor() {
+	if p.stop != nil {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	p.stop, p.done = stop, done
+	interval := p.idle / 2
+	if interval <= 0 {
+		interval = time.Second
+	}
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.closeIdle(time.Now())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// closeIdle closes every handle with no in-flight calls that was last used before now-idle.
+func (p *dbPool) closeIdle(now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for path, e := range p.entries {
+		if e.refs > 0 || now.Sub(e.lastUsed) < p.idle {
+			continue
+		}
+		if err := e.db.Close(); err != nil {
+			slog.Warn("close idle context db", "path", path, "err", err)
+		}
+		delete(p.entries, path)
+		slog.Debug("closed idle context db", "path", path)
+	}
+}
+
+// close stops the janitor and closes all cached handles.
+func (p *dbPool) close() error {
+	p.mu.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var firstErr error
+	for path, e := range p.entries {
+		if err := e.db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(p.entries, path)
+	}
+	return firstErr
+}
+
+// len returns the number of cached handles (for tests and diagnostics).
+func (p *dbPool) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// contextArg extracts the optional "context" argument shared by all tools.
+func contextArg(args json.RawMessage) string {
+	var p struct {
+		Context string `json:"context"`
+	}
+	_ = json.Unmarshal(args, &p)
+	return p.Context
+}
+
+// resolveTarget picks the database for a tool call's context argument.
+// An empty context uses the database the server was started with; any other
+// context is resolved through the pool's resolver and opened on demand.
+// Daemon clients name their own default database, and their contexts are
+// resolved next to it; all of their databases come from the pool.
+// The returned release func must be called when the call completes.
+func (s *Server) resolveTarget(ss *session, context string) (target, func(), error) {
+	noop := func() {}
+	if context != "" && locate.SanitizeContext(context) == "" {
+		return target{}, noop, fmt.Errorf("invalid context %q: must contain at least one letter or digit", context)
+	}
+	if ss != nil && ss.dbPath != "" {
+		path := ss.dbPath
+		if context != "" {
+			path = locate.ContextDBPath(ss.dbPath, context)
+		}
+		database, err := s.pool.acquire(path)
+		if err != nil {
+			return target{}, noop, err
+		}
+		return target{db: database, path: path, context: context}, func() { s.pool.release(path) }, nil
+	}
+	if context == "" {
+		return target{db: s.db, path: s.dbPath}, noop, nil
+	}
+
+	path, err := s.pool.resolve(context)
+	if err != nil {
+		return target{}, noop, fmt.Errorf("resolve context %q: %w", context, err)
+	}
+	if path == s.dbPath {
+		return target{db: s.db, path: s.dbPath, context: context}, noop, nil
+	}
+
+	database, err := s.pool.acquire(path)
+	if err != nil {
+		return target{}, noop, err
+	}
+	return target{db: database, path: path, context: context}, func() { s.pool.release(path) }, nil
+}
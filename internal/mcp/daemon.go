@@ -0,0 +1,169 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// daemonHello is the first line a proxy sends on a daemon connection. It
+// names the database the proxy's client would have opened itself and the
+// configuration it would have opened it with.
+type daemonHello struct {
+	DB     string `json:"db"`
+	Config string `json:"config"`
+}
+
+// daemonWelcome is the daemon's reply to a hello: empty if the connection
+// is accepted, otherwise why it is refused.
+type daemonWelcome struct {
+	Error string `json:"error,omitempty"`
+}
+
+// ErrDaemonRefused is returned by DialDaemon when the daemon is running but
+// will not serve the client, e.g. because it was started with a different
+// configuration.
+var ErrDaemonRefused = errors.New("daemon refused connection")
+
+// DialDaemon connects to the daemon listening on socket as a client whose
+// default database is dbPath, opened with the configuration identified by
+// config (see ServeDaemon). The connection then carries newline-delimited
+// JSON-RPC, as on stdio.
+func DialDaemon(socket, dbPath, config string) (net.Conn, error) {
+	conn, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.NewEncoder(conn).Encode(daemonHello{DB: dbPath, Config: config}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Read the reply a byte at a time so nothing after it is buffered away.
+	var line []byte
+	for b := make([]byte, 1); ; {
+		if _, err := conn.Read(b); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("read daemon reply: %w", err)
+		}
+		if b[0] == '\n' {
+			break
+		}
+		line = append(line, b[0])
+	}
+	var welcome daemonWelcome
+	if err := json.Unmarshal(line, &welcome); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("bad daemon reply: %w", err)
+	}
+	if welcome.Error != "" {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %s", ErrDaemonRefused, welcome.Error)
+	}
+	return conn, nil
+}
+
+// ServeDaemon serves proxy connections accepted from ln until ctx is
+// cancelled or, if idle is positive, no client has been connected for that
+// long. Every database is opened once, through the server's pool, and shared
+// by all clients, so writes to each file are serialized by its handle. The
+// pool's options come from the daemon's configuration, identified by config;
+// clients whose hello names another configuration are refused rather than
+// silently served with the daemon's settings.
+func (s *Server) ServeDaemon(ctx context.Context, ln net.Listener, idle time.Duration, config string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex // protects conns and idleTimer
+	conns := map[net.Conn]bool{}
+	var idleTimer *time.Timer
+	if idle > 0 {
+		idleTimer = time.AfterFunc(idle, cancel)
+	}
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for conn := range conns {
+			conn.Close()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		mu.Lock()
+		conns[conn] = true
+		if idleTimer != nil {
+			idleTimer.Stop()
+		}
+		if ctx.Err() != nil {
+			conn.Close() // accepted while shutting down
+		}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.serveDaemonConn(conn, config); err != nil {
+				slog.Debug("daemon client", "err", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			delete(conns, conn)
+			if len(conns) == 0 && idleTimer != nil {
+				idleTimer.Reset(idle)
+			}
+		}()
+	}
+}
+
+// serveDaemonConn serves one proxy connection: a hello line naming the
+// client's database and configuration, the daemon's reply, then JSON-RPC
+// until the proxy closes its side.
+func (s *Server) serveDaemonConn(conn net.Conn, config string) error {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return err
+	}
+	var hello daemonHello
+	if err := json.Unmarshal(line, &hello); err != nil {
+		return fmt.Errorf("bad hello: %w", err)
+	}
+	var refusal string
+	switch {
+	case !filepath.IsAbs(hello.DB):
+		refusal = fmt.Sprintf("database path %q is not absolute", hello.DB)
+	case hello.Config != config:
+		refusal = fmt.Sprintf("the daemon runs with config %s but the client uses %s; stop the daemon to switch", config, hello.Config)
+	}
+	if err := json.NewEncoder(conn).Encode(daemonWelcome{Error: refusal}); err != nil {
+		return err
+	}
+	if refusal != "" {
+		return fmt.Errorf("refused client: %s", refusal)
+	}
+	err = s.serveConn(r, conn, &session{dbPath: hello.DB})
+	if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
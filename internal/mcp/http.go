@@ -0,0 +1,425 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+)
+
+// HTTPPath is the endpoint of the Streamable HTTP transport.
+const HTTPPath = "/mcp"
+
+const (
+	maxHTTPBody        = 1024 * 1024 // same limit as a stdio line
+	sessionIdleTimeout = 24 * time.Hour
+	maxHTTPSessions    = 1000 // default for HTTPOptions.MaxSessions
+	sseKeepAlive       = 30 * time.Second
+)
+
+// HTTPOptions configures the Streamable HTTP transport.
+type HTTPOptions struct {
+	// AllowedOrigins lists browser origins (e.g. "https://app.example.com")
+	// allowed besides localhost. Requests without an Origin header, such as
+	// those from editors and agents, are always allowed.
+	AllowedOrigins []string
+
+	// RequireToken refuses requests without a bearer token even before any
+	// token exists. Once one does, a token is always required.
+	RequireToken bool
+
+	// MaxSessions caps live sessions; an initialize request beyond it is
+	// refused with 503 until a session ends or idles out. 0 means 1000.
+	MaxSessions int
+}
+
+// httpTransport serves the MCP Streamable HTTP transport: clients POST
+// JSON-RPC messages and get JSON or SSE responses, and may hold a GET SSE
+// stream open for server notifications. Each client is an Mcp-Session-Id
+// session created by its initialize request.
+type httpTransport struct {
+	s    *Server
+	opts HTTPOptions
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// httpSession is a session plus the queue of notifications waiting for the
+// client's GET stream.
+type httpSession struct {
+	*session
+	events   chan Notification
+	done     chan struct{} // closed when the session is deleted
+	lastUsed time.Time
+}
+
+// HTTPHandler returns an http.Handler serving the Streamable HTTP transport
+// at HTTPPath.
+func (s *Server) HTTPHandler(opts HTTPOptions) http.Handler {
+	t := &httpTransport{s: s, opts: opts, sessions: map[string]*httpSession{}}
+	mux := http.NewServeMux()
+	mux.Handle(HTTPPath, t)
+	return mux
+}
+
+// ListenAndServeHTTP listens on addr and serves the Streamable HTTP
+// transport until ctx is cancelled.
+func (s *Server) ListenAndServeHTTP(ctx context.Context, addr string, opts HTTPOptions) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:           s.HTTPHandler(opts),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !t.allowedOrigin(r.Header.Get("Origin")) {
+		writeHTTPError(w, http.StatusForbidden, "origin not allowed")
+		return
+	}
+	token, ok := t.authenticate(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		t.post(w, r, token)
+	case http.MethodGet:
+		t.stream(w, r, token)
+	case http.MethodDelete:
+		t.delete(w, r, token)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeHTTPError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// allowedOrigin guards against DNS rebinding: browsers send an Origin
+// header, and only localhost pages and configured origins may use it.
+func (t *httpTransport) allowedOrigin(origin string) bool {
+	if origin == "" || slices.Contains(t.opts.AllowedOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+// authenticate checks the request's bearer token against the server's
+// database. Without one it succeeds with a nil token, unless tokens are
+// required; otherwise it writes a 401 and returns false.
+func (t *httpTransport) authenticate(w http.ResponseWriter, r *http.Request) (*db.Token, bool) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		required := t.opts.RequireToken
+		if !required {
+			var err error
+			if required, err = t.s.db.HasTokens(r.Context()); err != nil {
+				writeHTTPError(w, http.StatusInternalServerError, err.Error())
+				return nil, false
+			}
+		}
+		if required {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="aimemo"`)
+			writeHTTPError(w, http.StatusUnauthorized, "missing bearer token; create one with 'aimemo token create'")
+			return nil, false
+		}
+		return nil, true
+	}
+
+	secret, ok := strings.CutPrefix(auth, "Bearer ")
+	var token *db.Token
+	if ok {
+		var err error
+		if token, err = t.s.db.AuthenticateToken(r.Context(), strings.TrimSpace(secret)); err != nil {
+			writeHTTPError(w, http.StatusInternalServerError, err.Error())
+			return nil, false
+		}
+	}
+	if token == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="aimemo", error="invalid_token"`)
+		writeHTTPError(w, http.StatusUnauthorized, "invalid bearer token")
+		return nil, false
+	}
+	return token, true
+}
+
+// post handles one JSON-RPC message, or a batch of them.
+func (t *httpTransport) post(w http.ResponseWriter, r *http.Request, token *db.Token) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHTTPBody))
+	if err != nil {
+		writeHTTPError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	body = bytes.TrimSpace(body)
+	batch := len(body) > 0 && body[0] == '['
+	var raw []json.RawMessage
+	if batch {
+		err = json.Unmarshal(body, &raw)
+	} else {
+		raw = []json.RawMessage{body}
+	}
+	var reqs []Request
+	for _, m := range raw {
+		var req Request
+		if err == nil {
+			err = json.Unmarshal(m, &req)
+		}
+		reqs = append(reqs, req)
+	}
+	if err != nil || len(reqs) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(errorResponse(nil, -32700, "parse error"))
+		return
+	}
+
+	var ss *httpSession
+	if slices.ContainsFunc(reqs, func(r Request) bool { return r.Method == "initialize" }) {
+		if len(reqs) > 1 {
+			writeHTTPError(w, http.StatusBadRequest, "initialize must not be batched")
+			return
+		}
+		if ss = t.newSession(token); ss == nil {
+			w.Header().Set("Retry-After", "60")
+			writeHTTPError(w, http.StatusServiceUnavailable, "too many sessions; end unused sessions with DELETE or retry later")
+			return
+		}
+		w.Header().Set("Mcp-Session-Id", ss.id)
+	} else if ss = t.session(w, r, token); ss == nil {
+		return
+	}
+
+	var resps []Response
+	for _, req := range reqs {
+		if req.Method == "" {
+			continue // a response to a server request; none are sent
+		}
+		resp := t.s.handle(ss.session, req)
+		// Notifications have no id and produce no response
+		if resp.ID == nil && resp.Error == nil && resp.Result == nil {
+			continue
+		}
+		resps = append(resps, resp)
+	}
+	if len(resps) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.Contains(accept, "text/event-stream") && !strings.Contains(accept, "application/json") {
+		sse, ok := startSSE(w)
+		if !ok {
+			return
+		}
+		for _, resp := range resps {
+			sse.send(resp)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if batch {
+		json.NewEncoder(w).Encode(resps)
+	} else {
+		json.NewEncoder(w).Encode(resps[0])
+	}
+}
+
+// stream holds a GET SSE stream open and sends the session's notifications
+// on it until the client disconnects or the session is deleted.
+func (t *httpTransport) stream(w http.ResponseWriter, r *http.Request, token *db.Token) {
+	if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		w.Header().Set("Allow", "POST, DELETE")
+		writeHTTPError(w, http.StatusMethodNotAllowed, "GET requires Accept: text/event-stream")
+		return
+	}
+	ss := t.session(w, r, token)
+	if ss == nil {
+		return
+	}
+	sse, ok := startSSE(w)
+	if !ok {
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case n := <-ss.events:
+			sse.send(n)
+		case <-keepAlive.C:
+			sse.comment("ping")
+		case <-ss.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// delete ends a session at the client's request.
+func (t *httpTransport) delete(w http.ResponseWriter, r *http.Request, token *db.Token) {
+	ss := t.session(w, r, token)
+	if ss == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.sessions[ss.id] == ss {
+		delete(t.sessions, ss.id)
+		close(ss.done)
+	}
+	t.mu.Unlock()
+	t.s.forgetSession(ss.session)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newSession starts a session for the client holding token, dropping
+// sessions idle for longer than sessionIdleTimeout. It returns nil if the
+// session limit is still reached after that.
+func (t *httpTransport) newSession(token *db.Token) *httpSession {
+	var b [16]byte
+	rand.Read(b[:])
+	ss := &httpSession{
+		events:   make(chan Notification, 16),
+		done:     make(chan struct{}),
+		lastUsed: time.Now(),
+	}
+	ss.session = &session{id: hex.EncodeToString(b[:]), token: token, send: func(n Notification) error {
+		select {
+		case ss.events <- n:
+			return nil
+		default:
+			return fmt.Errorf("session %s: notification queue full", ss.id)
+		}
+	}}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, old := range t.sessions {
+		if time.Since(old.lastUsed) > sessionIdleTimeout {
+			delete(t.sessions, id)
+			close(old.done)
+			t.s.forgetSession(old.session)
+		}
+	}
+	limit := t.opts.MaxSessions
+	if limit <= 0 {
+		limit = maxHTTPSessions
+	}
+	if len(t.sessions) >= limit {
+		return nil
+	}
+	t.sessions[ss.id] = ss
+	return ss
+}
+
+// session returns the session named by the request's Mcp-Session-Id
+// header, or writes an error and returns nil. A session can only be used
+// with the token that started it.
+func (t *httpTransport) session(w http.ResponseWriter, r *http.Request, token *db.Token) *httpSession {
+	id := r.Header.Get("Mcp-Session-Id")
+	if id == "" {
+		writeHTTPError(w, http.StatusBadRequest, "missing Mcp-Session-Id header; send initialize first")
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ss := t.sessions[id]
+	if ss == nil {
+		writeHTTPError(w, http.StatusNotFound, "unknown or expired session")
+		return nil
+	}
+	if tokenID(ss.token) != tokenID(token) {
+		writeHTTPError(w, http.StatusForbidden, "session was started with a different token")
+		return nil
+	}
+	ss.lastUsed = time.Now()
+	return ss
+}
+
+// sseWriter writes Server-Sent Events.
+type sseWriter struct {
+	w http.ResponseWriter
+	f http.Flusher
+}
+
+func startSSE(w http.ResponseWriter) (sseWriter, bool) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		writeHTTPError(w, http.StatusInternalServerError, "streaming unsupported")
+		return sseWriter{}, false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	f.Flush()
+	return sseWriter{w, f}, true
+}
+
+// send writes msg as one "message" event.
+func (s sseWriter) send(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("encode event", "err", err)
+		return
+	}
+	fmt.Fprintf(s.w, "event: message\ndata: %s\n\n", data)
+	s.f.Flush()
+}
+
+// comment writes an SSE comment, which keeps idle connections open.
+func (s sseWriter) comment(text string) {
+	fmt.Fprintf(s.w, ": %s\n\n", text)
+	s.f.Flush()
+}
+
+// writeHTTPError writes a transport-level error as a JSON-RPC error body.
+func writeHTTPError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse(nil, -32000, message))
+}
+
+func tokenID(t *db.Token) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.ID
+}
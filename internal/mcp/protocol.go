@@ -51,6 +51,29 @@ type ContentItem struct {
 	Text string `json:"text"`
 }
 
+// Resource describes an MCP resource for the resources/list response.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType"`
+}
+
+// ResourceTemplate describes a family of resources by an RFC 6570 URI template.
+type ResourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+}
+
+// ResourceContents is the text of a resource in a resources/read response.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
 // InitializeParams is the params for the initialize handshake.
 type InitializeParams struct {
 	ProtocolVersion string         `json:"protocolVersion"`
@@ -58,6 +81,13 @@ type InitializeParams struct {
 	Capabilities    map[string]any `json:"capabilities,omitempty"`
 }
 
+// codeForbidden is the JSON-RPC error code for tool calls the client's
+// token does not permit.
+const codeForbidden = -32003
+
+// codeResourceNotFound is the MCP error code for reading an unknown resource.
+const codeResourceNotFound = -32002
+
 // errorResponse creates an error response for a given request ID.
 func errorResponse(id any, code int, message string) Response {
 	return Response{
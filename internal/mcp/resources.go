@@ -0,0 +1,337 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+)
+
+// Resource URIs have the form memory://<kind>/<key>, with the key
+// path-escaped. Resources render as markdown; ?format=json returns JSON.
+const (
+	resourceScheme = "memory://"
+	mimeMarkdown   = "text/markdown"
+	mimeJSON       = "application/json"
+)
+
+const (
+	resourcePageSize    = 100 // entities per resources/list page
+	resourceJournalDays = 31  // journal days listed on the first page
+	resourceTypeLimit   = 500 // entities rendered in a type resource
+)
+
+// errResourceNotFound marks resources/read requests for URIs that name
+// nothing in the database.
+var errResourceNotFound = errors.New("resource not found")
+
+// errInvalidResourceURI marks URIs that are not memory:// resource URIs.
+var errInvalidResourceURI = errors.New("invalid resource uri")
+
+var resourceTemplates = []ResourceTemplate{
+	{
+		URITemplate: "memory://entity/{name}",
+		Name:        "entity",
+		Description: "An entity with its observations and relations. Add ?format=json for JSON.",
+		MimeType:    mimeMarkdown,
+	},
+	{
+		URITemplate: "memory://journal/{date}",
+		Name:        "journal",
+		Description: "Journal entries written on a date (YYYY-MM-DD). Add ?format=json for JSON.",
+		MimeType:    mimeMarkdown,
+	},
+	{
+		URITemplate: "memory://type/{entity_type}",
+		Name:        "type",
+		Description: "All entities of an entity type. Add ?format=json for JSON.",
+		MimeType:    mimeMarkdown,
+	},
+}
+
+func entityURI(name string) string     { return resourceScheme + "entity/" + url.PathEscape(name) }
+func journalURI(day string) string     { return resourceScheme + "journal/" + url.PathEscape(day) }
+func typeURI(entityType string) string { return resourceScheme + "type/" + url.PathEscape(entityType) }
+
+// handleResourceTemplatesList returns the resource URI templates.
+func (s *Server) handleResourceTemplatesList(req Request) Response {
+	return successResponse(req.ID, map[string]any{
+		"resourceTemplates": resourceTemplates,
+	})
+}
+
+// handleResourcesList lists entities by name, a page at a time. The first
+// page also lists the entity types and the most recent journal days.
+func (s *Server) handleResourcesList(ss *session, req Request) Response {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p struct {
+		Cursor string `json:"cursor"`
+	}
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return errorResponse(req.ID, -32602, "invalid params: "+err.Error())
+		}
+	}
+	t, release, err := s.resourceTarget(ss)
+	if err != nil {
+		return resourceError(req.ID, err)
+	}
+	defer release()
+
+	resources := []Resource{}
+	if p.Cursor == "" {
+		types, err := t.db.EntityTypeCounts(ctx)
+		if err != nil {
+			return resourceError(req.ID, err)
+		}
+		for _, c := range types {
+			resources = append(resources, Resource{
+				URI:         typeURI(c.EntityType),
+				Name:        "type: " + c.EntityType,
+				Description: fmt.Sprintf("%d entities of type %s", c.Count, c.EntityType),
+				MimeType:    mimeMarkdown,
+			})
+		}
+		days, err := t.db.JournalDays(ctx, resourceJournalDays)
+		if err != nil {
+			return resourceError(req.ID, err)
+		}
+		for _, day := range days {
+			resources = append(resources, Resource{
+				URI:         journalURI(day),
+				Name:        "journal: " + day,
+				Description: "Journal entries written on " + day,
+				MimeType:    mimeMarkdown,
+			})
+		}
+	}
+
+	entities, next, err := t.db.SearchPage(ctx, "", "", nil, "name", resourcePageSize, p.Cursor)
+	if err != nil {
+		return resourceError(req.ID, err)
+	}
+	for _, e := range entities {
+		resources = append(resources, Resource{
+			URI:         entityURI(e.Name),
+			Name:        e.Name,
+			Description: fmt.Sprintf("%s, %d observations", e.EntityType, len(e.Observations)),
+			MimeType:    mimeMarkdown,
+		})
+	}
+
+	// Listing resources signs the session up for list_changed notifications.
+	if err := s.watch(ss, ""); err != nil {
+		slog.Warn("watch resources", "err", err)
+	}
+
+	result := map[string]any{"resources": resources}
+	if next != "" {
+		result["nextCursor"] = next
+	}
+	return successResponse(req.ID, result)
+}
+
+// handleResourcesRead renders the resource named by the uri param.
+func (s *Server) handleResourcesRead(ss *session, req Request) Response {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(req.Params, &p); err != nil {
+		return errorResponse(req.ID, -32602, "invalid params: "+err.Error())
+	}
+	kind, key, format, err := parseResourceURI(p.URI)
+	if err != nil {
+		return resourceError(req.ID, err)
+	}
+	t, release, err := s.resourceTarget(ss)
+	if err != nil {
+		return resourceError(req.ID, err)
+	}
+	defer release()
+
+	var data any
+	var text string
+	switch kind {
+	case "entity":
+		data, text, err = readEntityResource(ctx, t.db, key)
+	case "journal":
+		data, text, err = readJournalResource(ctx, t.db, key)
+	case "type":
+		data, text, err = readTypeResource(ctx, t.db, key)
+	default:
+		err = fmt.Errorf("%w: unknown resource kind %q", errResourceNotFound, kind)
+	}
+	if err != nil {
+		return resourceError(req.ID, err)
+	}
+
+	contents := ResourceContents{URI: p.URI, MimeType: mimeMarkdown, Text: text}
+	if format == "json" {
+		b, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return errorResponse(req.ID, -32603, "marshal error: "+err.Error())
+		}
+		contents.MimeType, contents.Text = mimeJSON, string(b)
+	}
+	return successResponse(req.ID, map[string]any{
+		"contents": []ResourceContents{contents},
+	})
+}
+
+// resourceTarget returns the database resources are read from, after
+// checking the client's token.
+func (s *Server) resourceTarget(ss *session) (target, func(), error) {
+	var token *db.Token
+	if ss != nil {
+		token = ss.token
+	}
+	if err := authorizeResources(token); err != nil {
+		return target{}, func() {}, err
+	}
+	return s.resolveTarget(ss, "")
+}
+
+// resourceError maps a resources request error to its JSON-RPC error.
+func resourceError(id any, err error) Response {
+	switch {
+	case errors.Is(err, errResourceNotFound):
+		return errorResponse(id, codeResourceNotFound, err.Error())
+	case errors.Is(err, errForbidden):
+		return errorResponse(id, codeForbidden, err.Error())
+	case errors.Is(err, db.ErrInvalidCursor), errors.Is(err, errInvalidResourceURI):
+		return errorResponse(id, -32602, err.Error())
+	}
+	return errorResponse(id, -32603, err.Error())
+}
+
+// parseResourceURI splits memory://<kind>/<key>[?format=json|markdown].
+func parseResourceURI(uri string) (kind, key, format string, err error) {
+	rest, ok := strings.CutPrefix(uri, resourceScheme)
+	if !ok {
+		return "", "", "", fmt.Errorf("%w %q: want %s<kind>/<key>", errInvalidResourceURI, uri, resourceScheme)
+	}
+	rest, rawQuery, _ := strings.Cut(rest, "?")
+	kind, rawKey, _ := strings.Cut(rest, "/")
+	if key, err = url.PathUnescape(rawKey); err != nil || key == "" {
+		return "", "", "", fmt.Errorf("%w %q: missing or malformed key", errInvalidResourceURI, uri)
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", "", "", fmt.Errorf("%w %q: %v", errInvalidResourceURI, uri, err)
+	}
+	switch format = query.Get("format"); format {
+	case "", "markdown":
+		format = "markdown"
+	case "json":
+	default:
+		return "", "", "", fmt.Errorf("%w %q: format must be markdown or json", errInvalidResourceURI, uri)
+	}
+	return kind, key, format, nil
+}
+
+func readEntityResource(ctx context.Context, database *db.DB, name string) (any, string, error) {
+	e, err := database.GetEntity(ctx, name)
+	if err != nil {
+		return nil, "", err
+	}
+	if e == nil {
+		return nil, "", fmt.Errorf("%w: no entity %q", errResourceNotFound, name)
+	}
+	rels, err := database.ListRelationsByEntity(ctx, e.Name)
+	if err != nil {
+		return nil, "", err
+	}
+	if rels == nil {
+		rels = []db.Relation{}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", e.Name)
+	fmt.Fprintf(&b, "- Type: %s\n", e.EntityType)
+	if len(e.Tags) > 0 {
+		fmt.Fprintf(&b, "- Tags: %s\n", strings.Join(e.Tags, ", "))
+	}
+	if len(e.Aliases) > 0 {
+		fmt.Fprintf(&b, "- Aliases: %s\n", strings.Join(e.Aliases, ", "))
+	}
+	fmt.Fprintf(&b, "- Updated: %s\n", time.UnixMilli(e.UpdatedAt).Format(time.RFC3339))
+	if len(e.Observations) > 0 {
+		b.WriteString("\n## Observations\n\n")
+		for _, o := range e.Observations {
+			fmt.Fprintf(&b, "- %s\n", o)
+		}
+	}
+	if len(rels) > 0 {
+		b.WriteString("\n## Relations\n\n")
+		for _, r := range rels {
+			fmt.Fprintf(&b, "- [%s](%s) %s [%s](%s)\n", r.FromName, entityURI(r.FromName), r.Relation, r.ToName, entityURI(r.ToName))
+		}
+	}
+	return map[string]any{"entity": e, "relations": rels}, b.String(), nil
+}
+
+func readJournalResource(ctx context.Context, database *db.DB, day string) (any, string, error) {
+	if _, err := time.Parse("2006-01-02", day); err != nil {
+		return nil, "", fmt.Errorf("%w: journal dates are YYYY-MM-DD, got %q", errResourceNotFound, day)
+	}
+	entries, err := database.JournalDay(ctx, day)
+	if err != nil {
+		return nil, "", err
+	}
+	if entries == nil {
+		entries = []db.JournalEntry{}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Journal %s\n", day)
+	if len(entries) == 0 {
+		b.WriteString("\nNo entries.\n")
+	}
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\n## %s", time.UnixMilli(e.CreatedAt).Format("15:04"))
+		if len(e.Tags) > 0 {
+			fmt.Fprintf(&b, " (%s)", strings.Join(e.Tags, ", "))
+		}
+		fmt.Fprintf(&b, "\n\n%s\n", e.Content)
+	}
+	return map[string]any{"date": day, "entries": entries, "count": len(entries)}, b.String(), nil
+}
+
+func readTypeResource(ctx context.Context, database *db.DB, entityType string) (any, string, error) {
+	results, next, err := database.SearchPage(ctx, "", entityType, nil, "name", resourceTypeLimit, "")
+	if err != nil {
+		return nil, "", err
+	}
+	if len(results) == 0 {
+		return nil, "", fmt.Errorf("%w: no entities of type %q", errResourceNotFound, entityType)
+	}
+
+	type entry struct {
+		Name         string   `json:"name"`
+		URI          string   `json:"uri"`
+		Tags         []string `json:"tags"`
+		Observations []string `json:"observations"`
+	}
+	entities := make([]entry, 0, len(results))
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Entities of type %s\n\n", entityType)
+	for _, r := range results {
+		entities = append(entities, entry{Name: r.Name, URI: entityURI(r.Name), Tags: r.Tags, Observations: r.Observations})
+		fmt.Fprintf(&b, "- [%s](%s): %d observations\n", r.Name, entityURI(r.Name), len(r.Observations))
+	}
+	if next != "" {
+		fmt.Fprintf(&b, "\nOnly the first %d are listed; use memory_search with type %q for the rest.\n", resourceTypeLimit, entityType)
+	}
+	return map[string]any{"entity_type": entityType, "entities": entities, "truncated": next != ""}, b.String(), nil
+}
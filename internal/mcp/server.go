@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
 	"os"
 	"sync"
@@ -16,21 +18,68 @@ import (
 type Server struct {
 	db     *db.DB
 	dbPath string
-	mu     sync.Mutex        // protects stdout encoder
-	enc    *json.Encoder     // set once in ServeStdio before the read loop
+	pool   *dbPool // per-call context databases, opened on demand
+
+	watches *watchSet // resource subscriptions and change notifications
+}
+
+// session is one connected client: the stdio peer, or one HTTP session.
+type session struct {
+	id     string                   // Mcp-Session-Id; empty for stdio
+	send   func(Notification) error // delivers a server notification to the client
+	token  *db.Token                // limits the session's tool calls; nil for stdio
+	dbPath string                   // daemon clients: their default database
+}
+
+// notify sends a notification to the client. A nil session (unit tests)
+// discards it.
+func (ss *session) notify(n Notification) {
+	if ss == nil || ss.send == nil {
+		return
+	}
+	if err := ss.send(n); err != nil {
+		slog.Error("send notification", "method", n.Method, "err", err)
+	}
 }
 
-// NewServer creates a new MCP server.
+// NewServer creates a new MCP server. database serves calls without a context
+// argument; calls naming a context are routed to that context's database.
 func NewServer(database *db.DB, dbPath string) *Server {
-	return &Server{db: database, dbPath: dbPath}
+	return &Server{db: database, dbPath: dbPath, pool: newDBPool(), watches: newWatchSet()}
+}
+
+// SetDBOptions sets the options used to open per-context databases, so they
+// are configured the same way as the default database.
+func (s *Server) SetDBOptions(opts db.Options) {
+	s.pool.open = func(path string) (*db.DB, error) {
+		return db.OpenWithOptions(path, opts)
+	}
+}
+
+// Close releases the per-context databases opened by the server.
+// The default database passed to NewServer is owned by the caller.
+func (s *Server) Close() error {
+	s.closeWatches()
+	return s.pool.close()
 }
 
 // ServeStdio reads JSON-RPC requests from stdin and writes responses to stdout.
 func (s *Server) ServeStdio() error {
-	scanner := bufio.NewScanner(os.Stdin)
+	return s.serveConn(os.Stdin, os.Stdout, &session{})
+}
+
+// serveConn reads newline-delimited JSON-RPC requests from r and writes
+// responses, and the notifications for peer, to w.
+func (s *Server) serveConn(r io.Reader, w io.Writer, peer *session) error {
+	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
-	enc := json.NewEncoder(os.Stdout)
-	s.enc = enc
+	enc := json.NewEncoder(w)
+	var mu sync.Mutex // protects enc
+	peer.send = func(n Notification) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return enc.Encode(n)
+	}
 
 	var wg sync.WaitGroup
 
@@ -49,34 +98,45 @@ func (s *Server) ServeStdio() error {
 		wg.Add(1)
 		go func(r Request) {
 			defer wg.Done()
-			resp := s.handle(r)
+			resp := s.handle(peer, r)
 			// Notifications have no id and produce no response
 			if resp.ID == nil && resp.Error == nil && resp.Result == nil {
 				return
 			}
-			s.mu.Lock()
-			defer s.mu.Unlock()
+			mu.Lock()
+			defer mu.Unlock()
 			if err := enc.Encode(resp); err != nil {
 				slog.Error("encode response", "err", err)
 			}
 		}(req)
 	}
 	wg.Wait() // ensure all responses are written before returning
+	s.forgetSession(peer)
 	return scanner.Err()
 }
 
-// handle dispatches a request to the appropriate handler.
-func (s *Server) handle(req Request) Response {
+// handle dispatches a request from the client ss to the appropriate handler.
+func (s *Server) handle(ss *session, req Request) Response {
 	switch req.Method {
 	case "initialize":
 		return s.handleInitialize(req)
 	case "notifications/initialized":
-		s.sendMemoryReadyNotification()
+		sendMemoryReadyNotification(ss)
 		return Response{} // no response for notifications
 	case "tools/list":
 		return s.handleToolsList(req)
 	case "tools/call":
-		return s.handleToolCall(req)
+		return s.handleToolCall(ss, req)
+	case "resources/list":
+		return s.handleResourcesList(ss, req)
+	case "resources/templates/list":
+		return s.handleResourceTemplatesList(req)
+	case "resources/read":
+		return s.handleResourcesRead(ss, req)
+	case "resources/subscribe":
+		return s.handleResourcesSubscribe(ss, req)
+	case "resources/unsubscribe":
+		return s.handleResourcesUnsubscribe(ss, req)
 	default:
 		return errorResponse(req.ID, -32601, "method not found")
 	}
@@ -91,7 +151,8 @@ func (s *Server) handleInitialize(req Request) Response {
 			"version": "1.0.0",
 		},
 		"capabilities": map[string]any{
-			"tools": map[string]any{},
+			"tools":     map[string]any{},
+			"resources": map[string]any{"subscribe": true, "listChanged": true},
 		},
 	})
 }
@@ -105,27 +166,20 @@ func (s *Server) handleToolsList(req Request) Response {
 
 // sendMemoryReadyNotification sends a notifications/message after the MCP handshake completes.
 // This prompts the client (Claude) to call memory_context immediately to restore prior session context.
-func (s *Server) sendMemoryReadyNotification() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if s.enc == nil {
-		return // not running in stdio mode (e.g. unit tests)
-	}
-	n := Notification{
+func sendMemoryReadyNotification(ss *session) {
+	ss.notify(Notification{
 		JSONRPC: "2.0",
 		Method:  "notifications/message",
 		Params: LogMessageParams{
 			Level: "info",
 			Data:  "Memory server ready. Call memory_context({}) NOW to restore prior session context before responding to the user. Do not skip this step.",
 		},
-	}
-	if err := s.enc.Encode(n); err != nil {
-		slog.Error("send memory ready notification", "err", err)
-	}
+	})
 }
 
 // handleToolCall dispatches to the named tool handler with a 5s timeout.
-func (s *Server) handleToolCall(req Request) Response {
+// Calls the session's token does not permit fail with a JSON-RPC error.
+func (s *Server) handleToolCall(ss *session, req Request) Response {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -138,7 +192,10 @@ func (s *Server) handleToolCall(req Request) Response {
 		p.Arguments = json.RawMessage("{}")
 	}
 
-	result, err := s.dispatch(ctx, p.Name, p.Arguments)
+	result, err := s.dispatch(ctx, ss, p.Name, p.Arguments)
+	if errors.Is(err, errForbidden) {
+		return errorResponse(req.ID, codeForbidden, err.Error())
+	}
 	if err != nil {
 		text := err.Error()
 		return successResponse(req.ID, ToolResult{
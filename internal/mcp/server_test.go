@@ -1,8 +1,18 @@
 package mcp
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/MyAgentHubs/aimemo/internal/db"
 	"github.com/stretchr/testify/assert"
@@ -22,7 +32,7 @@ func TestHandle_Initialize(t *testing.T) {
 		ID:      1,
 		Method:  "initialize",
 	}
-	resp := s.handle(req)
+	resp := s.handle(nil, req)
 	assert.Nil(t, resp.Error)
 	assert.NotNil(t, resp.Result)
 }
@@ -34,14 +44,17 @@ func TestHandle_ToolsList(t *testing.T) {
 		ID:      2,
 		Method:  "tools/list",
 	}
-	resp := s.handle(req)
+	resp := s.handle(nil, req)
 	assert.Nil(t, resp.Error)
 
 	result, ok := resp.Result.(map[string]any)
 	require.True(t, ok)
 	tools, ok := result["tools"].([]Tool)
 	require.True(t, ok)
-	assert.Len(t, tools, 5)
+	assert.Len(t, tools, len(toolHandlers))
+	for _, tool := range tools {
+		assert.Contains(t, toolHandlers, tool.Name)
+	}
 }
 
 func TestHandle_ToolsCall_MemoryStore(t *testing.T) {
@@ -60,7 +73,7 @@ func TestHandle_ToolsCall_MemoryStore(t *testing.T) {
 		Method:  "tools/call",
 		Params:  params,
 	}
-	resp := s.handle(req)
+	resp := s.handle(nil, req)
 	assert.Nil(t, resp.Error)
 
 	result, ok := resp.Result.(ToolResult)
@@ -83,7 +96,7 @@ func TestHandle_ToolsCall_MemorySearch_Empty(t *testing.T) {
 		Method:  "tools/call",
 		Params:  params,
 	}
-	resp := s.handle(req)
+	resp := s.handle(nil, req)
 	assert.Nil(t, resp.Error)
 
 	result, ok := resp.Result.(ToolResult)
@@ -105,7 +118,7 @@ func TestHandle_ToolsCall_MemoryContext_Empty(t *testing.T) {
 		Method:  "tools/call",
 		Params:  params,
 	}
-	resp := s.handle(req)
+	resp := s.handle(nil, req)
 	assert.Nil(t, resp.Error)
 
 	result, ok := resp.Result.(ToolResult)
@@ -130,7 +143,7 @@ func TestHandle_ToolsCall_StoreAndSearch(t *testing.T) {
 		}`),
 	})
 	storeReq := Request{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: storeParams}
-	storeResp := s.handle(storeReq)
+	storeResp := s.handle(nil, storeReq)
 	assert.Nil(t, storeResp.Error)
 
 	// Search by name
@@ -139,7 +152,7 @@ func TestHandle_ToolsCall_StoreAndSearch(t *testing.T) {
 		Arguments: json.RawMessage(`{"name": "PostgreSQL"}`),
 	})
 	searchReq := Request{JSONRPC: "2.0", ID: 2, Method: "tools/call", Params: searchParams}
-	searchResp := s.handle(searchReq)
+	searchResp := s.handle(nil, searchReq)
 	assert.Nil(t, searchResp.Error)
 
 	result, ok := searchResp.Result.(ToolResult)
@@ -159,14 +172,14 @@ func TestHandle_ToolsCall_MemoryForget(t *testing.T) {
 			"entities": [{"name": "Redis", "entityType": "system", "observations": ["Port 6379", "Version 7.2"]}]
 		}`),
 	})
-	s.handle(Request{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: storeParams})
+	s.handle(nil, Request{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: storeParams})
 
 	// Retract observation
 	forgetParams, _ := json.Marshal(ToolCallParams{
 		Name:      "memory_forget",
 		Arguments: json.RawMessage(`{"name": "Redis", "observation": "Port 6379"}`),
 	})
-	forgetResp := s.handle(Request{JSONRPC: "2.0", ID: 2, Method: "tools/call", Params: forgetParams})
+	forgetResp := s.handle(nil, Request{JSONRPC: "2.0", ID: 2, Method: "tools/call", Params: forgetParams})
 	assert.Nil(t, forgetResp.Error)
 
 	result, ok := forgetResp.Result.(ToolResult)
@@ -183,7 +196,7 @@ func TestHandle_ToolsCall_MemoryLink(t *testing.T) {
 		Name:      "memory_link",
 		Arguments: json.RawMessage(`{"from": "Redis", "to": "Gateway", "relation": "used-by"}`),
 	})
-	resp := s.handle(Request{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: linkParams})
+	resp := s.handle(nil, Request{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: linkParams})
 	assert.Nil(t, resp.Error)
 
 	result, ok := resp.Result.(ToolResult)
@@ -198,7 +211,7 @@ func TestHandle_Notification_Initialized(t *testing.T) {
 		Method:  "notifications/initialized",
 		// No ID — it's a notification
 	}
-	resp := s.handle(req)
+	resp := s.handle(nil, req)
 	// Should return empty response (no id, no result, no error)
 	assert.Nil(t, resp.ID)
 	assert.Nil(t, resp.Result)
@@ -208,11 +221,154 @@ func TestHandle_Notification_Initialized(t *testing.T) {
 func TestHandle_MethodNotFound(t *testing.T) {
 	s := newTestServer(t)
 	req := Request{JSONRPC: "2.0", ID: 1, Method: "unknown/method"}
-	resp := s.handle(req)
+	resp := s.handle(nil, req)
 	assert.NotNil(t, resp.Error)
 	assert.Equal(t, -32601, resp.Error.Code)
 }
 
+func TestHandle_Resources(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_store", `{"entities": [
+		{"name": "auth service", "entityType": "service", "observations": ["Issues JWTs"], "tags": ["core"]},
+		{"name": "Redis", "entityType": "system", "observations": ["Port 6379"]}
+	]}`)
+	callTool(t, s, "memory_link", `{"from": "auth service", "relation": "uses", "to": "Redis"}`)
+	callTool(t, s, "memory_store", `{"journal": "Moved sessions to Redis"}`)
+	request := func(method, params string) Response {
+		return s.handle(nil, Request{JSONRPC: "2.0", ID: 1, Method: method, Params: json.RawMessage(params)})
+	}
+	read := func(uri string) ResourceContents {
+		t.Helper()
+		params, _ := json.Marshal(map[string]string{"uri": uri})
+		resp := request("resources/read", string(params))
+		require.Nil(t, resp.Error, uri)
+		contents := resp.Result.(map[string]any)["contents"].([]ResourceContents)
+		require.Len(t, contents, 1)
+		assert.Equal(t, uri, contents[0].URI)
+		return contents[0]
+	}
+
+	init := request("initialize", `{}`).Result.(map[string]any)
+	assert.Contains(t, init["capabilities"], "resources")
+
+	templates := request("resources/templates/list", `{}`).Result.(map[string]any)["resourceTemplates"].([]ResourceTemplate)
+	assert.Len(t, templates, 3)
+
+	list := request("resources/list", `{}`).Result.(map[string]any)
+	var uris []string
+	for _, r := range list["resources"].([]Resource) {
+		uris = append(uris, r.URI)
+	}
+	today := time.Now().Format("2006-01-02")
+	assert.Equal(t, []string{"memory://type/service", "memory://type/system", "memory://journal/" + today,
+		"memory://entity/Redis", "memory://entity/auth%20service"}, uris)
+	assert.NotContains(t, list, "nextCursor")
+
+	md := read("memory://entity/auth%20service")
+	assert.Equal(t, "text/markdown", md.MimeType)
+	assert.Contains(t, md.Text, "# auth service")
+	assert.Contains(t, md.Text, "- Issues JWTs")
+	assert.Contains(t, md.Text, "[auth service](memory://entity/auth%20service) uses [Redis](memory://entity/Redis)")
+
+	js := read("memory://entity/redis?format=json")
+	assert.Equal(t, "application/json", js.MimeType)
+	var entity struct {
+		Entity    db.Entity     `json:"entity"`
+		Relations []db.Relation `json:"relations"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(js.Text), &entity))
+	assert.Equal(t, "Redis", entity.Entity.Name)
+	assert.Len(t, entity.Relations, 1)
+
+	assert.Contains(t, read("memory://journal/"+today).Text, "Moved sessions to Redis")
+	assert.Contains(t, read("memory://journal/2001-01-01").Text, "No entries.")
+	assert.Contains(t, read("memory://type/system").Text, "[Redis](memory://entity/Redis)")
+
+	assert.Equal(t, codeResourceNotFound, request("resources/read", `{"uri": "memory://entity/nope"}`).Error.Code)
+	assert.Equal(t, codeResourceNotFound, request("resources/read", `{"uri": "memory://graph/x"}`).Error.Code)
+	assert.Equal(t, -32602, request("resources/read", `{"uri": "file:///etc/passwd"}`).Error.Code)
+	assert.Equal(t, -32602, request("resources/list", `{"cursor": "bogus"}`).Error.Code)
+
+	// Entities page by name after the first page.
+	for i := range resourcePageSize {
+		_, err := s.db.UpsertEntity(context.Background(), fmt.Sprintf("bulk-%03d", i), "bulk", nil)
+		require.NoError(t, err)
+	}
+	first := request("resources/list", `{}`).Result.(map[string]any)
+	cursor, ok := first["nextCursor"].(string)
+	require.True(t, ok)
+	params, _ := json.Marshal(map[string]string{"cursor": cursor})
+	second := request("resources/list", string(params)).Result.(map[string]any)
+	assert.NotContains(t, second, "nextCursor")
+	var names []string
+	for _, r := range second["resources"].([]Resource) {
+		names = append(names, r.Name)
+	}
+	assert.Equal(t, []string{"bulk-098", "bulk-099"}, names)
+}
+
+func TestResourceSubscriptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.db")
+	database, err := db.Open(path)
+	require.NoError(t, err)
+	defer database.Close()
+	s := NewServer(database, path)
+	defer s.Close()
+
+	events := make(chan Notification, 16)
+	ss := &session{send: func(n Notification) error { events <- n; return nil }}
+	next := func() Notification {
+		t.Helper()
+		select {
+		case n := <-events:
+			return n
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification")
+			return Notification{}
+		}
+	}
+	request := func(method, params string) Response {
+		return s.handle(ss, Request{JSONRPC: "2.0", ID: 1, Method: method, Params: json.RawMessage(params)})
+	}
+
+	init := request("initialize", `{}`).Result.(map[string]any)["capabilities"].(map[string]any)
+	assert.Equal(t, map[string]any{"subscribe": true, "listChanged": true}, init["resources"])
+	require.Nil(t, request("resources/subscribe", `{"uri": "memory://entity/Redis"}`).Error)
+	assert.Equal(t, -32602, request("resources/subscribe", `{"uri": "redis"}`).Error.Code)
+
+	// The server's own writes: creating the entity changes the listing too.
+	callTool(t, s, "memory_store", `{"entities": [{"name": "Redis", "entityType": "system", "observations": ["Port 6379"]}]}`)
+	assert.Equal(t, "notifications/resources/list_changed", next().Method)
+	updated := next()
+	assert.Equal(t, "notifications/resources/updated", updated.Method)
+	assert.Equal(t, map[string]any{"uri": "memory://entity/Redis"}, updated.Params)
+
+	// Another process writing the same file is seen through data_version.
+	other, err := db.Open(path)
+	require.NoError(t, err)
+	defer other.Close()
+	e, err := other.GetEntity(context.Background(), "redis")
+	require.NoError(t, err)
+	require.NoError(t, other.AddObservation(context.Background(), e.ID, "Runs in Docker"))
+	assert.Equal(t, "notifications/resources/updated", next().Method)
+
+	// After unsubscribing only the listing is reported.
+	require.Nil(t, request("resources/unsubscribe", `{"uri": "memory://entity/Redis"}`).Error)
+	callTool(t, s, "memory_store", `{"entities": [
+		{"name": "Redis", "entityType": "system", "observations": ["Evicts with LRU"]},
+		{"name": "Memcached", "entityType": "system"}
+	]}`)
+	assert.Equal(t, "notifications/resources/list_changed", next().Method)
+	select {
+	case n := <-events:
+		t.Fatalf("unexpected notification %s", n.Method)
+	case <-time.After(2 * watchInterval):
+	}
+
+	s.forgetSession(ss)
+	assert.Empty(t, s.watches.byPath, "watches stop with their last session")
+}
+
 func TestHandle_Journal(t *testing.T) {
 	s := newTestServer(t)
 
@@ -222,7 +378,7 @@ func TestHandle_Journal(t *testing.T) {
 			Name:      "memory_store",
 			Arguments: json.RawMessage(`{"journal": "Fixed a bug", "tags": ["fix"]}`),
 		})
-		resp := s.handle(Request{JSONRPC: "2.0", ID: i, Method: "tools/call", Params: storeParams})
+		resp := s.handle(nil, Request{JSONRPC: "2.0", ID: i, Method: "tools/call", Params: storeParams})
 		assert.Nil(t, resp.Error)
 	}
 
@@ -231,7 +387,7 @@ func TestHandle_Journal(t *testing.T) {
 		Name:      "memory_search",
 		Arguments: json.RawMessage(`{"journal": true, "since": "24h"}`),
 	})
-	resp := s.handle(Request{JSONRPC: "2.0", ID: 99, Method: "tools/call", Params: searchParams})
+	resp := s.handle(nil, Request{JSONRPC: "2.0", ID: 99, Method: "tools/call", Params: searchParams})
 	assert.Nil(t, resp.Error)
 
 	result, ok := resp.Result.(ToolResult)
@@ -240,3 +396,626 @@ func TestHandle_Journal(t *testing.T) {
 	require.NoError(t, json.Unmarshal([]byte(result.Content[0].Text), &content))
 	assert.Equal(t, float64(2), content["count"])
 }
+
+// newContextTestServer returns a server whose named contexts resolve to
+// separate files in a temp directory.
+func newContextTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := newTestServer(t)
+	dir := t.TempDir()
+	s.pool.resolve = func(context string) (string, error) {
+		return filepath.Join(dir, "memory-"+context+".db"), nil
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func callTool(t *testing.T, s *Server, name, args string) map[string]any {
+	t.Helper()
+	params, _ := json.Marshal(ToolCallParams{Name: name, Arguments: json.RawMessage(args)})
+	resp := s.handle(nil, Request{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: params})
+	require.Nil(t, resp.Error)
+	result, ok := resp.Result.(ToolResult)
+	require.True(t, ok)
+	require.False(t, result.IsError, result.Content[0].Text)
+	var content map[string]any
+	require.NoError(t, json.Unmarshal([]byte(result.Content[0].Text), &content))
+	return content
+}
+
+func TestHandle_ToolsCall_ContextIsolation(t *testing.T) {
+	s := newContextTestServer(t)
+
+	stored := callTool(t, s, "memory_store", `{
+		"context": "skill-a",
+		"entities": [{"name": "Redis", "entityType": "system", "observations": ["Port 6379"]}]
+	}`)
+	assert.Equal(t, "skill-a", stored["context"])
+	assert.Equal(t, "memory-skill-a.db", filepath.Base(stored["storage_path"].(string)))
+
+	// Same context sees the entity.
+	found := callTool(t, s, "memory_search", `{"context": "skill-a", "name": "Redis"}`)
+	assert.Equal(t, float64(1), found["count"])
+
+	// Another context and the default database do not.
+	other := callTool(t, s, "memory_search", `{"context": "skill-b", "name": "Redis"}`)
+	assert.Equal(t, float64(0), other["count"])
+	def := callTool(t, s, "memory_search", `{"name": "Redis"}`)
+	assert.Equal(t, float64(0), def["count"])
+	assert.Equal(t, ":memory:", def["storage_path"])
+
+	ctxResult := callTool(t, s, "memory_context", `{"context": "skill-a"}`)
+	assert.Equal(t, float64(1), ctxResult["entity_count"])
+
+	assert.Equal(t, 2, s.pool.len(), "one cached handle per named context")
+}
+
+func TestDBPool_ClosesIdleHandles(t *testing.T) {
+	s := newContextTestServer(t)
+	callTool(t, s, "memory_store", `{"context": "idle", "journal": "hello"}`)
+	require.Equal(t, 1, s.pool.len())
+
+	s.pool.closeIdle(time.Now())
+	assert.Equal(t, 1, s.pool.len(), "recently used handle stays open")
+
+	s.pool.closeIdle(time.Now().Add(contextIdleTimeout + time.Second))
+	assert.Equal(t, 0, s.pool.len())
+
+	// Reopened transparently on next use, with data intact.
+	res := callTool(t, s, "memory_search", `{"context": "idle", "journal": true}`)
+	assert.Equal(t, float64(1), res["count"])
+}
+
+func TestHandle_ToolsCall_InvalidContext(t *testing.T) {
+	s := newContextTestServer(t)
+	params, _ := json.Marshal(ToolCallParams{Name: "memory_search", Arguments: json.RawMessage(`{"context": "!!!"}`)})
+	resp := s.handle(nil, Request{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: params})
+	result, ok := resp.Result.(ToolResult)
+	require.True(t, ok)
+	assert.True(t, result.IsError)
+}
+
+func TestHandle_ToolsCall_MemoryTask(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_store", `{"entities": [{"name": "auth-service", "entityType": "service"}]}`)
+
+	_, err := s.dispatch(context.Background(), nil, "memory_task", json.RawMessage(`{"action": "add", "title": "Add rate limiting", "entity": "auth-servce"}`))
+	assert.ErrorContains(t, err, "not found", "tasks do not create entities")
+
+	added := callTool(t, s, "memory_task", `{"action": "add", "title": "Add rate limiting", "priority": "high", "entity": "auth-service"}`)
+	task := added["task"].(map[string]any)
+	assert.Equal(t, "open", task["status"])
+	id := task["id"]
+
+	callTool(t, s, "memory_task", `{"action": "add", "title": "Write docs", "priority": "low"}`)
+
+	ctxResult := callTool(t, s, "memory_context", `{}`)
+	tasks := ctxResult["incomplete_tasks"].([]any)
+	require.Len(t, tasks, 2)
+	assert.Equal(t, "Add rate limiting", tasks[0].(map[string]any)["title"])
+
+	args, _ := json.Marshal(map[string]any{"action": "done", "id": id})
+	done := callTool(t, s, "memory_task", string(args))
+	assert.Equal(t, "done", done["task"].(map[string]any)["status"])
+
+	listed := callTool(t, s, "memory_task", `{"action": "list"}`)
+	assert.Equal(t, float64(1), listed["count"])
+	all := callTool(t, s, "memory_task", `{"action": "list", "status": "all"}`)
+	assert.Equal(t, float64(2), all["count"])
+}
+
+func TestHandle_ToolsCall_MemoryUnlink(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_link", `{"from": "API", "to": "Redis", "relation": "uses"}`)
+	callTool(t, s, "memory_link", `{"from": "API", "to": "PG", "relation": "uses"}`)
+
+	// rename_to with endpoints is refused rather than renaming every edge.
+	_, err := s.dispatch(context.Background(), nil, "memory_unlink",
+		json.RawMessage(`{"from": "API", "to": "Redis", "relation": "uses", "rename_to": "calls"}`))
+	assert.ErrorContains(t, err, "cannot be combined")
+	_, err = s.dispatch(context.Background(), nil, "memory_unlink", json.RawMessage(`{"id": 1, "relation": "uses", "rename_to": "calls"}`))
+	assert.Error(t, err)
+
+	renamed := callTool(t, s, "memory_unlink", `{"relation": "uses", "rename_to": "depends_on"}`)
+	assert.Equal(t, float64(2), renamed["changed"])
+
+	callTool(t, s, "memory_store", `{"entities": [{"name": "Redis", "entityType": "system", "aliases": ["cache"]}]}`)
+	deleted := callTool(t, s, "memory_unlink", `{"from": "API", "to": "cache", "relation": "depends_on"}`)
+	assert.Equal(t, float64(1), deleted["changed"], "endpoints may be aliases")
+
+	none := callTool(t, s, "memory_unlink", `{"from": "API", "to": "Redis"}`)
+	assert.Equal(t, float64(0), none["changed"])
+}
+
+func TestHandle_ToolsCall_MemoryGraph(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_link", `{"from": "checkout", "to": "payments", "relation": "calls"}`)
+	callTool(t, s, "memory_link", `{"from": "payments", "to": "Redis", "relation": "uses"}`)
+
+	g := callTool(t, s, "memory_graph", `{"name": "Redis", "direction": "in", "depth": 2}`)
+	assert.Equal(t, float64(3), g["node_count"])
+	assert.Equal(t, false, g["truncated"])
+
+	p := callTool(t, s, "memory_graph", `{"from": "checkout", "to": "Redis"}`)
+	assert.Equal(t, true, p["found"])
+	assert.Equal(t, float64(2), p["path"].(map[string]any)["hops"])
+}
+
+func TestHandle_ToolsCall_MemorySearch_MatchedOnly(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_store", `{"entities": [{"name": "cache", "entityType": "system",
+		"observations": ["uses redis for sessions", "deployed on three nodes"]}]}`)
+
+	full := callTool(t, s, "memory_search", `{"query": "redis"}`)
+	entity := full["entities"].([]any)[0].(map[string]any)
+	assert.Equal(t, []any{"uses redis for sessions", "deployed on three nodes"}, entity["observations"],
+		"default mode keeps every observation")
+	assert.Equal(t, []any{map[string]any{"index": float64(0), "terms": []any{"redis"}}}, entity["matches"])
+
+	matched := callTool(t, s, "memory_search", `{"query": "redis", "matched_only": true}`)
+	entity = matched["entities"].([]any)[0].(map[string]any)
+	assert.Equal(t, []any{"uses ⟦redis⟧ for sessions"}, entity["observations"])
+	assert.Nil(t, entity["matches"])
+}
+
+func TestHandle_ToolsCall_MemorySearch_Fuzzy(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_store", `{"entities": [{"name": "cluster", "entityType": "system",
+		"observations": ["runs on Kubernetes 1.29"]}]}`)
+
+	res := callTool(t, s, "memory_search", `{"query": "kubrenetes"}`)
+	assert.Equal(t, float64(1), res["count"])
+	assert.Equal(t, true, res["fuzzy"])
+	assert.Equal(t, "kubernetes", res["did_you_mean"])
+
+	exact := callTool(t, s, "memory_search", `{"query": "kubernetes"}`)
+	assert.Equal(t, float64(1), exact["count"])
+	assert.Nil(t, exact["fuzzy"])
+}
+
+func TestHandle_ToolsCall_MemorySearch_BadQuery(t *testing.T) {
+	s := newTestServer(t)
+	params, _ := json.Marshal(ToolCallParams{Name: "memory_search", Arguments: json.RawMessage(`{"query": "redis OR"}`)})
+	resp := s.handle(nil, Request{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: params})
+	result, ok := resp.Result.(ToolResult)
+	require.True(t, ok)
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.Content[0].Text, "OR needs a search term")
+}
+
+func TestHandle_ToolsCall_MemorySearch_Cursor(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_store", `{"entities": [
+		{"name": "a", "entityType": "system", "observations": ["redis"]},
+		{"name": "b", "entityType": "system", "observations": ["redis"]},
+		{"name": "c", "entityType": "system", "observations": ["redis"]}]}`)
+	callTool(t, s, "memory_store", `{"journal": "redis restarted"}`)
+
+	first := callTool(t, s, "memory_search", `{"query": "redis", "limit": 2}`)
+	assert.Equal(t, float64(2), first["count"])
+	assert.Equal(t, float64(1), first["journal_count"])
+	assert.Nil(t, first["journal_next_cursor"])
+	cursor, ok := first["next_cursor"].(string)
+	require.True(t, ok)
+
+	args, _ := json.Marshal(map[string]any{"query": "redis", "limit": 2, "cursor": cursor})
+	second := callTool(t, s, "memory_search", string(args))
+	assert.Equal(t, float64(1), second["count"])
+	assert.Nil(t, second["next_cursor"])
+	assert.Nil(t, second["journal"], "journal hits are not repeated on later pages")
+}
+
+func TestHandle_ToolsCall_MemorySearch_Mode(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_store", `{"entities": [{"name": "auth", "entityType": "service",
+		"observations": ["access tokens expire after an hour"]}]}`)
+
+	semantic := callTool(t, s, "memory_search", `{"query": "token expiry", "mode": "semantic"}`)
+	assert.Equal(t, float64(1), semantic["count"])
+	assert.Nil(t, semantic["fuzzy"])
+
+	hybrid := callTool(t, s, "memory_search", `{"query": "token expiry", "mode": "hybrid"}`)
+	assert.Equal(t, float64(1), hybrid["count"])
+}
+
+func TestHandle_ToolsCall_MemoryStore_Duplicates(t *testing.T) {
+	s := newTestServer(t)
+	first := callTool(t, s, "memory_store", `{"entities": [{"name": "auth", "entityType": "service",
+		"observations": ["Access tokens expire after 1 hour"]}]}`)
+	assert.Nil(t, first["duplicates"])
+
+	second := callTool(t, s, "memory_store", `{"entities": [{"name": "auth", "entityType": "service",
+		"observations": ["access token expires after 1 hour", "sessions live in redis"]}]}`)
+	dups, ok := second["duplicates"].([]any)
+	require.True(t, ok)
+	require.Len(t, dups, 1)
+	d := dups[0].(map[string]any)
+	assert.Equal(t, "auth", d["entity"])
+	assert.Equal(t, "access token expires after 1 hour", d["observation"])
+	assert.Equal(t, "Access tokens expire after 1 hour", d["existing"])
+}
+
+func TestHandle_ToolsCall_MemoryStore_Aliases(t *testing.T) {
+	s := newTestServer(t)
+	stored := callTool(t, s, "memory_store", `{"entities": [{"name": "auth-service", "entityType": "module",
+		"observations": ["uses JWT"], "aliases": ["AuthService"]}]}`)
+	entity := stored["entities"].([]any)[0].(map[string]any)
+	assert.Equal(t, []any{"AuthService"}, entity["aliases"])
+
+	callTool(t, s, "memory_store", `{"entities": [{"name": "AuthService", "entityType": "module",
+		"observations": ["rotates keys daily"]}]}`)
+	found := callTool(t, s, "memory_search", `{"name": "authservice"}`)
+	require.Equal(t, float64(1), found["count"])
+	e := found["entities"].([]any)[0].(map[string]any)
+	assert.Equal(t, "auth-service", e["name"])
+	assert.Len(t, e["observations"], 2)
+}
+
+func TestHandle_ToolsCall_MemoryMerge(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_store", `{"entities": [
+		{"name": "auth-service", "entityType": "module", "observations": ["uses JWT"]},
+		{"name": "AuthService", "entityType": "module", "observations": ["rotates keys daily"]}]}`)
+
+	merged := callTool(t, s, "memory_merge", `{"from": "AuthService", "into": "auth-service"}`)
+	assert.Equal(t, "merge", merged["action"])
+	result := merged["result"].(map[string]any)
+	assert.Equal(t, float64(1), result["observations"])
+
+	renamed := callTool(t, s, "memory_merge", `{"from": "auth-service", "into": "auth"}`)
+	assert.Equal(t, "rename", renamed["action"])
+	assert.Equal(t, "auth", renamed["entity"])
+
+	found := callTool(t, s, "memory_search", `{"name": "AuthService"}`)
+	e := found["entities"].([]any)[0].(map[string]any)
+	assert.Equal(t, "auth", e["name"])
+	assert.Len(t, e["observations"], 2)
+}
+
+func TestHandle_ToolsCall_MemoryUndo(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_store", `{"entities": [{"name": "old-feature", "entityType": "project", "observations": ["shipped in v1"]}]}`)
+	callTool(t, s, "memory_forget", `{"name": "old-feature", "permanent": true}`)
+
+	preview := callTool(t, s, "memory_undo", `{}`)
+	assert.Equal(t, true, preview["preview"])
+	require.Equal(t, float64(1), preview["count"])
+	change := preview["changes"].([]any)[0].(map[string]any)
+	assert.Equal(t, "entity.delete", change["op"])
+	assert.Equal(t, "old-feature", change["entity"])
+	assert.Equal(t, []any{change["id"]}, preview["ids"])
+
+	ctx := context.Background()
+	_, err := s.dispatch(ctx, nil, "memory_undo", json.RawMessage(`{"confirm": true}`))
+	assert.ErrorContains(t, err, "requires the ids")
+	_, err = s.dispatch(ctx, nil, "memory_undo", json.RawMessage(`{"confirm": true, "steps": 1}`))
+	assert.Error(t, err)
+
+	// A change logged after the preview makes its ids stale.
+	callTool(t, s, "memory_store", `{"journal": "cleaned up old features"}`)
+	ids := fmt.Sprintf(`{"confirm": true, "ids": [%v]}`, change["id"])
+	_, err = s.dispatch(ctx, nil, "memory_undo", json.RawMessage(ids))
+	assert.ErrorContains(t, err, "no longer the newest")
+	latest := callTool(t, s, "memory_undo", `{}`)
+	latestID := latest["ids"].([]any)[0]
+	callTool(t, s, "memory_undo", fmt.Sprintf(`{"confirm": true, "ids": [%v]}`, latestID))
+
+	undone := callTool(t, s, "memory_undo", ids)
+	assert.Equal(t, "undo", undone["action"])
+	assert.Equal(t, float64(1), undone["count"])
+
+	found := callTool(t, s, "memory_search", `{"name": "old-feature"}`)
+	require.Equal(t, float64(1), found["count"])
+	e := found["entities"].([]any)[0].(map[string]any)
+	assert.Len(t, e["observations"], 1)
+}
+
+func TestHandle_ToolsCall_MemoryRestore(t *testing.T) {
+	s := newTestServer(t)
+	callTool(t, s, "memory_store", `{"entities": [{"name": "old-feature", "entityType": "project", "observations": ["shipped in v1"]}]}`)
+	callTool(t, s, "memory_forget", `{"name": "old-feature"}`)
+
+	listed := callTool(t, s, "memory_restore", `{}`)
+	assert.Equal(t, float64(1), listed["count"])
+
+	restored := callTool(t, s, "memory_restore", `{"name": "old-feature"}`)
+	assert.Equal(t, "restore", restored["action"])
+	found := callTool(t, s, "memory_search", `{"query": "shipped"}`)
+	assert.Equal(t, float64(1), found["count"])
+}
+
+func postMCP(t *testing.T, url, session, body string, header ...string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	if session != "" {
+		req.Header.Set("Mcp-Session-Id", session)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestHTTP_Session(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(t).HTTPHandler(HTTPOptions{}))
+	defer srv.Close()
+	url := srv.URL + HTTPPath
+
+	resp := postMCP(t, url, "", `{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "requests before initialize need a session")
+
+	resp = postMCP(t, url, "", `{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2025-03-26"}}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	session := resp.Header.Get("Mcp-Session-Id")
+	require.NotEmpty(t, session)
+	var init Response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&init))
+	assert.Nil(t, init.Error)
+
+	// The GET stream delivers the notification sent after the handshake.
+	get, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	get.Header.Set("Accept", "text/event-stream")
+	get.Header.Set("Mcp-Session-Id", session)
+	stream, err := http.DefaultClient.Do(get)
+	require.NoError(t, err)
+	defer stream.Body.Close()
+	assert.Equal(t, "text/event-stream", stream.Header.Get("Content-Type"))
+
+	resp = postMCP(t, url, session, `{"jsonrpc": "2.0", "method": "notifications/initialized"}`)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+	events := bufio.NewReader(stream.Body)
+	var data string
+	for !strings.HasPrefix(data, "data: ") {
+		data, err = events.ReadString('\n')
+		require.NoError(t, err)
+	}
+	assert.Contains(t, data, "notifications/message")
+
+	resp = postMCP(t, url, session, `{"jsonrpc": "2.0", "id": 2, "method": "tools/call",
+		"params": {"name": "memory_store", "arguments": {"entities": [{"name": "Redis", "entityType": "system", "observations": ["Port 6379"]}]}}}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	var call struct {
+		ID     float64    `json:"id"`
+		Result ToolResult `json:"result"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&call))
+	assert.Equal(t, float64(2), call.ID)
+	assert.False(t, call.Result.IsError)
+
+	// Batches get an array of responses.
+	resp = postMCP(t, url, session, `[{"jsonrpc": "2.0", "id": 3, "method": "tools/list"}, {"jsonrpc": "2.0", "id": 4, "method": "bogus"}]`)
+	var batch []Response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&batch))
+	require.Len(t, batch, 2)
+	assert.Nil(t, batch[0].Error)
+	assert.Equal(t, -32601, batch[1].Error.Code)
+
+	del, err := http.NewRequest(http.MethodDelete, url, nil)
+	require.NoError(t, err)
+	del.Header.Set("Mcp-Session-Id", session)
+	resp, err = http.DefaultClient.Do(del)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp = postMCP(t, url, session, `{"jsonrpc": "2.0", "id": 5, "method": "tools/list"}`)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestHTTP_SessionLimit(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(t).HTTPHandler(HTTPOptions{MaxSessions: 2}))
+	defer srv.Close()
+	url := srv.URL + HTTPPath
+	initialize := `{"jsonrpc": "2.0", "id": 1, "method": "initialize"}`
+
+	first := postMCP(t, url, "", initialize).Header.Get("Mcp-Session-Id")
+	require.NotEmpty(t, first)
+	require.Equal(t, http.StatusOK, postMCP(t, url, "", initialize).StatusCode)
+	resp := postMCP(t, url, "", initialize)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Mcp-Session-Id"))
+
+	// Ending a session makes room for a new one.
+	del, err := http.NewRequest(http.MethodDelete, url, nil)
+	require.NoError(t, err)
+	del.Header.Set("Mcp-Session-Id", first)
+	resp, err = http.DefaultClient.Do(del)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, postMCP(t, url, "", initialize).StatusCode)
+}
+
+func TestHTTP_StreamedResponse(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(t).HTTPHandler(HTTPOptions{}))
+	defer srv.Close()
+	url := srv.URL + HTTPPath
+
+	resp := postMCP(t, url, "", `{"jsonrpc": "2.0", "id": 1, "method": "initialize"}`)
+	session := resp.Header.Get("Mcp-Session-Id")
+
+	resp = postMCP(t, url, session, `{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}`, "Accept", "text/event-stream")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(body), "event: message\ndata: {"), string(body))
+	assert.Contains(t, string(body), "memory_context")
+}
+
+func TestHTTP_Origin(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(t).HTTPHandler(HTTPOptions{AllowedOrigins: []string{"https://app.example.com"}}))
+	defer srv.Close()
+	url := srv.URL + HTTPPath
+	initialize := `{"jsonrpc": "2.0", "id": 1, "method": "initialize"}`
+
+	resp := postMCP(t, url, "", initialize, "Origin", "https://evil.example.com")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	for _, origin := range []string{"http://localhost:3000", "http://127.0.0.1:8080", "https://app.example.com"} {
+		resp = postMCP(t, url, "", initialize, "Origin", origin)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, origin)
+	}
+}
+
+func TestDispatch_TokenScopes(t *testing.T) {
+	assert.Len(t, toolScopes, len(toolHandlers), "every tool needs a scope")
+	for name := range toolHandlers {
+		assert.Contains(t, toolScopes, name)
+	}
+
+	s := newTestServer(t)
+	ctx := context.Background()
+	reader := &db.Token{Name: "ci", Scopes: []string{db.ScopeRead}}
+	writer := &db.Token{Name: "agent", Scopes: []string{db.ScopeRead, db.ScopeWrite}, Contexts: []string{"default"}}
+
+	_, err := s.dispatch(ctx, &session{token: reader}, "memory_search", json.RawMessage(`{}`))
+	assert.NoError(t, err)
+	_, err = s.dispatch(ctx, &session{token: reader}, "memory_store", json.RawMessage(`{"journal": "hi"}`))
+	assert.ErrorIs(t, err, errForbidden)
+	_, err = s.dispatch(ctx, &session{token: reader}, "memory_task", json.RawMessage(`{"action": "list"}`))
+	assert.NoError(t, err)
+	_, err = s.dispatch(ctx, &session{token: reader}, "memory_undo", json.RawMessage(`{}`))
+	assert.NoError(t, err, "previews only read")
+
+	_, err = s.dispatch(ctx, &session{token: writer}, "memory_store", json.RawMessage(`{"journal": "hi"}`))
+	assert.NoError(t, err)
+	_, err = s.dispatch(ctx, &session{token: writer}, "memory_forget", json.RawMessage(`{"name": "x"}`))
+	assert.ErrorIs(t, err, errForbidden)
+	_, err = s.dispatch(ctx, &session{token: writer}, "memory_search", json.RawMessage(`{"context": "work"}`))
+	assert.ErrorIs(t, err, errForbidden)
+
+	// Merging entities and renaming relation types need delete.
+	for i, call := range []ToolCallParams{
+		{Name: "memory_merge", Arguments: json.RawMessage(`{"from": "a", "into": "b"}`)},
+		{Name: "memory_unlink", Arguments: json.RawMessage(`{"relation": "a", "rename_to": "b"}`)},
+	} {
+		params, _ := json.Marshal(call)
+		resp := s.handle(&session{token: writer}, Request{JSONRPC: "2.0", ID: i, Method: "tools/call", Params: params})
+		require.NotNil(t, resp.Error, call.Name)
+		assert.Equal(t, codeForbidden, resp.Error.Code, call.Name)
+		assert.Contains(t, resp.Error.Message, "delete scope", call.Name)
+	}
+	deleter := &db.Token{Name: "admin", Scopes: []string{db.ScopeRead, db.ScopeWrite, db.ScopeDelete}}
+	_, err = s.dispatch(ctx, &session{token: deleter}, "memory_unlink", json.RawMessage(`{"relation": "a", "rename_to": "b"}`))
+	assert.NoError(t, err)
+
+	// Over JSON-RPC a rejected call is an error, not a tool result.
+	params, _ := json.Marshal(ToolCallParams{Name: "memory_store", Arguments: json.RawMessage(`{"journal": "hi"}`)})
+	resp := s.handle(&session{token: reader}, Request{JSONRPC: "2.0", ID: 1, Method: "tools/call", Params: params})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, codeForbidden, resp.Error.Code)
+	assert.Contains(t, resp.Error.Message, "write scope")
+
+	// Resources read the default database.
+	resp = s.handle(&session{token: reader}, Request{JSONRPC: "2.0", ID: 2, Method: "resources/list"})
+	assert.Nil(t, resp.Error)
+	scoped := &db.Token{Name: "work", Scopes: []string{db.ScopeRead}, Contexts: []string{"work"}}
+	resp = s.handle(&session{token: scoped}, Request{JSONRPC: "2.0", ID: 3, Method: "resources/list"})
+	require.NotNil(t, resp.Error)
+	assert.Equal(t, codeForbidden, resp.Error.Code)
+}
+
+func TestHTTP_BearerToken(t *testing.T) {
+	database := db.NewTestDB(t)
+	srv := httptest.NewServer(NewServer(database, ":memory:").HTTPHandler(HTTPOptions{}))
+	defer srv.Close()
+	url := srv.URL + HTTPPath
+	initialize := `{"jsonrpc": "2.0", "id": 1, "method": "initialize"}`
+
+	// Without any token, localhost clients need none.
+	resp := postMCP(t, url, "", initialize)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ctx := context.Background()
+	readSecret, _, err := database.CreateToken(ctx, "ci", []string{db.ScopeRead}, nil)
+	require.NoError(t, err)
+	writeSecret, _, err := database.CreateToken(ctx, "agent", []string{db.ScopeRead, db.ScopeWrite}, nil)
+	require.NoError(t, err)
+
+	resp = postMCP(t, url, "", initialize)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("WWW-Authenticate"), "Bearer")
+	resp = postMCP(t, url, "", initialize, "Authorization", "Bearer aimemo_bogus")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = postMCP(t, url, "", initialize, "Authorization", "Bearer "+readSecret)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	session := resp.Header.Get("Mcp-Session-Id")
+
+	resp = postMCP(t, url, session, `{"jsonrpc": "2.0", "id": 2, "method": "tools/call",
+		"params": {"name": "memory_store", "arguments": {"journal": "hi"}}}`, "Authorization", "Bearer "+readSecret)
+	var denied Response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&denied))
+	require.NotNil(t, denied.Error)
+	assert.Equal(t, codeForbidden, denied.Error.Code)
+
+	resp = postMCP(t, url, session, `{"jsonrpc": "2.0", "id": 3, "method": "tools/list"}`, "Authorization", "Bearer "+writeSecret)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "sessions are bound to their token")
+
+	require.NoError(t, database.RevokeToken(ctx, "ci"))
+	resp = postMCP(t, url, session, `{"jsonrpc": "2.0", "id": 4, "method": "tools/list"}`, "Authorization", "Bearer "+readSecret)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestDaemon(t *testing.T) {
+	dir := t.TempDir()
+	ln, err := net.Listen("unix", filepath.Join(dir, "d.sock"))
+	require.NoError(t, err)
+	s := NewServer(nil, "")
+	defer s.Close()
+	done := make(chan error, 1)
+	go func() { done <- s.ServeDaemon(context.Background(), ln, 100*time.Millisecond, "config.toml (1a2b)") }()
+
+	_, err = DialDaemon(filepath.Join(dir, "missing.sock"), filepath.Join(dir, "memory.db"), "config.toml (1a2b)")
+	assert.Error(t, err)
+
+	dbPath := filepath.Join(dir, "memory.db")
+	_, err = DialDaemon(ln.Addr().String(), dbPath, "other.toml (3c4d)")
+	assert.ErrorIs(t, err, ErrDaemonRefused, "clients with another config are refused")
+	_, err = DialDaemon(ln.Addr().String(), "memory.db", "config.toml (1a2b)")
+	assert.ErrorIs(t, err, ErrDaemonRefused)
+
+	conn, err := DialDaemon(ln.Addr().String(), dbPath, "config.toml (1a2b)")
+	require.NoError(t, err)
+	_, err = io.WriteString(conn, `{"jsonrpc": "2.0", "id": 1, "method": "initialize"}
+{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "memory_store", "arguments": {"entities": [{"name": "Redis", "entityType": "system"}]}}}
+`)
+	require.NoError(t, err)
+	conn.(*net.UnixConn).CloseWrite()
+
+	var ids []float64
+	dec := json.NewDecoder(conn)
+	for {
+		var resp Response
+		if err := dec.Decode(&resp); err != nil {
+			require.ErrorIs(t, err, io.EOF)
+			break
+		}
+		assert.Nil(t, resp.Error)
+		ids = append(ids, resp.ID.(float64))
+	}
+	conn.Close()
+	assert.ElementsMatch(t, []float64{1, 2}, ids, "requests are served concurrently")
+
+	// The store went to the client's database, which the daemon owns.
+	database, err := db.Open(dbPath)
+	require.NoError(t, err)
+	defer database.Close()
+	e, err := database.GetEntity(context.Background(), "Redis")
+	require.NoError(t, err)
+	assert.NotNil(t, e)
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon did not exit after its idle timeout")
+	}
+}
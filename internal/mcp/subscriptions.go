@@ -0,0 +1,264 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+)
+
+// watchInterval is how often a watched database is checked for writes by
+// other processes. The server's own writes are picked up immediately.
+const watchInterval = 500 * time.Millisecond
+
+// watchSet holds one resourceWatch per database that sessions have listed
+// or subscribed to resources of.
+type watchSet struct {
+	mu     sync.Mutex // protects byPath and every watch's sessions
+	byPath map[string]*resourceWatch
+}
+
+// resourceWatch follows the change log of one database and notifies its
+// sessions: list_changed for every session, updated for subscribed URIs.
+type resourceWatch struct {
+	db       *db.DB
+	release  func()
+	sessions map[*session]map[string]bool // subscribed URIs per session
+	poke     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newWatchSet() *watchSet {
+	return &watchSet{byPath: map[string]*resourceWatch{}}
+}
+
+// handleResourcesSubscribe subscribes the session to updates of a resource.
+func (s *Server) handleResourcesSubscribe(ss *session, req Request) Response {
+	uri, resp, ok := s.subscriptionURI(req)
+	if !ok {
+		return resp
+	}
+	if err := s.watch(ss, uri); err != nil {
+		return resourceError(req.ID, err)
+	}
+	return successResponse(req.ID, map[string]any{})
+}
+
+// handleResourcesUnsubscribe ends a subscription. Unsubscribing from a
+// resource the session is not subscribed to is not an error.
+func (s *Server) handleResourcesUnsubscribe(ss *session, req Request) Response {
+	uri, resp, ok := s.subscriptionURI(req)
+	if !ok {
+		return resp
+	}
+	s.watches.mu.Lock()
+	for _, w := range s.watches.byPath {
+		delete(w.sessions[ss], uri)
+	}
+	s.watches.mu.Unlock()
+	return successResponse(req.ID, map[string]any{})
+}
+
+// subscriptionURI returns the validated uri param of a subscription request,
+// or the error response to send.
+func (s *Server) subscriptionURI(req Request) (string, Response, bool) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(req.Params, &p); err != nil {
+		return "", errorResponse(req.ID, -32602, "invalid params: "+err.Error()), false
+	}
+	if _, _, _, err := parseResourceURI(p.URI); err != nil {
+		return "", resourceError(req.ID, err), false
+	}
+	return p.URI, Response{}, true
+}
+
+// watch registers the session, subscribed to uri unless it is empty, with
+// the watch on its default database, starting the watch if needed. A nil
+// session (unit tests) is not watched.
+func (s *Server) watch(ss *session, uri string) error {
+	if ss == nil {
+		return nil
+	}
+	t, release, err := s.resourceTarget(ss)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.watches.mu.Lock()
+	defer s.watches.mu.Unlock()
+	w, ok := s.watches.byPath[t.path]
+	if ok {
+		release() // the watch holds its own reference
+	} else {
+		// Start from the current state; only later changes are reported.
+		lastID, err := t.db.LastChangeID(ctx)
+		if err != nil {
+			release()
+			return err
+		}
+		version, err := t.db.DataVersion(ctx)
+		if err != nil {
+			release()
+			return err
+		}
+		w = &resourceWatch{
+			db:       t.db,
+			release:  release,
+			sessions: map[*session]map[string]bool{},
+			poke:     make(chan struct{}, 1),
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+		s.watches.byPath[t.path] = w
+		go s.runWatch(w, lastID, version)
+	}
+	if w.sessions[ss] == nil {
+		w.sessions[ss] = map[string]bool{}
+	}
+	if uri != "" {
+		w.sessions[ss][uri] = true
+	}
+	return nil
+}
+
+// forgetSession drops a closed session's subscriptions and stops watches
+// no session needs any more.
+func (s *Server) forgetSession(ss *session) {
+	s.watches.mu.Lock()
+	var idle []*resourceWatch
+	for path, w := range s.watches.byPath {
+		delete(w.sessions, ss)
+		if len(w.sessions) == 0 {
+			delete(s.watches.byPath, path)
+			idle = append(idle, w)
+		}
+	}
+	s.watches.mu.Unlock()
+	for _, w := range idle {
+		w.close()
+	}
+}
+
+// closeWatches stops every watch.
+func (s *Server) closeWatches() {
+	s.watches.mu.Lock()
+	watches := s.watches.byPath
+	s.watches.byPath = map[string]*resourceWatch{}
+	s.watches.mu.Unlock()
+	for _, w := range watches {
+		w.close()
+	}
+}
+
+// pokeWatch tells the watch on the database at path, if any, that the
+// server just wrote to it.
+func (s *Server) pokeWatch(path string) {
+	s.watches.mu.Lock()
+	w := s.watches.byPath[path]
+	s.watches.mu.Unlock()
+	if w == nil {
+		return
+	}
+	select {
+	case w.poke <- struct{}{}:
+	default: // a check is already pending
+	}
+}
+
+func (w *resourceWatch) close() {
+	close(w.stop)
+	<-w.done
+	w.release()
+}
+
+// runWatch checks the database for new changes whenever the server writes
+// to it or its data_version shows another process did, and notifies the
+// watch's sessions.
+func (s *Server) runWatch(w *resourceWatch, lastID, version int64) {
+	defer close(w.done)
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.poke:
+		case <-ticker.C:
+			v, err := w.db.DataVersion(context.Background())
+			if err != nil || v == version {
+				continue
+			}
+			version = v
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		touched, err := w.db.TouchedSince(ctx, lastID)
+		cancel()
+		if err != nil {
+			slog.Error("watch resources", "err", err)
+			continue
+		}
+		if touched.LastID == lastID {
+			continue
+		}
+		lastID = touched.LastID
+		s.notifyTouched(w, touched)
+	}
+}
+
+// notifyTouched sends list_changed to every session of the watch if the
+// listing changed, and updated for each subscribed URI the changes touched.
+func (s *Server) notifyTouched(w *resourceWatch, touched *db.Touched) {
+	type pending struct {
+		ss *session
+		n  Notification
+	}
+	var out []pending
+	s.watches.mu.Lock()
+	for ss, uris := range w.sessions {
+		if touched.Listed {
+			out = append(out, pending{ss, Notification{JSONRPC: "2.0", Method: "notifications/resources/list_changed"}})
+		}
+		for uri := range uris {
+			if resourceTouched(uri, touched) {
+				out = append(out, pending{ss, Notification{
+					JSONRPC: "2.0",
+					Method:  "notifications/resources/updated",
+					Params:  map[string]any{"uri": uri},
+				}})
+			}
+		}
+	}
+	s.watches.mu.Unlock()
+	for _, p := range out {
+		p.ss.notify(p.n)
+	}
+}
+
+// resourceTouched reports whether the resource at uri is affected by the
+// changes. Entity names match case-insensitively, as in lookups.
+func resourceTouched(uri string, touched *db.Touched) bool {
+	kind, key, _, err := parseResourceURI(uri)
+	if err != nil {
+		return false
+	}
+	switch kind {
+	case "entity":
+		return slices.ContainsFunc(touched.Entities, func(name string) bool { return strings.EqualFold(name, key) })
+	case "journal":
+		return slices.Contains(touched.Days, key)
+	case "type":
+		return slices.Contains(touched.Types, key)
+	}
+	return false
+}
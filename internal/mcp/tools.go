@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/MyAgentHubs/aimemo/internal/db"
@@ -25,8 +26,9 @@ EXAMPLE: memory_context({}) — call with no arguments to load default context.`
 		InputSchema: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
-				"since": map[string]any{"type": "string", "description": "Time window: 2h|24h|7d|ISO date (default 24h)"},
-				"limit": map[string]any{"type": "integer", "description": "Max recent observations (default 20)"},
+				"since":   map[string]any{"type": "string", "description": "Time window: 2h|24h|7d|ISO date (default 24h)"},
+				"limit":   map[string]any{"type": "integer", "description": "Max recent observations (default 20)"},
+				"context": map[string]any{"type": "string", "description": "Named memory context"},
 			},
 		},
 	},
@@ -42,6 +44,8 @@ WHEN TO CALL:
 
 ENTITY TYPES: project, module, bug, decision, person, concept, system — use whatever fits.
 JOURNAL: Use the journal field (not entities) for session logs. Journal entries are append-only and never deduplicated.
+ALIASES: Give alternate spellings in aliases (e.g. "AuthService" for "auth-service"). Any tool taking an entity name accepts an alias, and storing under an alias updates the original entity.
+DUPLICATES: If a new observation closely restates one the entity already has, the response lists both under duplicates. The new one is still stored; retract whichever is redundant with memory_forget.
 
 EXAMPLES:
 - Store a fact: memory_store({entities: [{name: "auth-service", entityType: "module", observations: ["Uses JWT with 1h expiry", "Refresh token stored in Redis"]}]})
@@ -59,6 +63,7 @@ EXAMPLES:
 							"entityType":   map[string]any{"type": "string"},
 							"observations": map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
 							"tags":         map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+							"aliases":      map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Alternate names that resolve to this entity"},
 						},
 						"required": []string{"name", "entityType", "observations"},
 					},
@@ -78,27 +83,38 @@ VS memory_context: memory_context gives you recent activity; memory_search finds
 
 EXAMPLES:
 - Keyword search: memory_search({query: "redis connection"})
+- Query syntax: memory_search({query: "(redis OR memcached) NOT legacy type:system"})
+- Only matching facts: memory_search({query: "redis connection", matched_only: true})
+- By meaning: memory_search({query: "session lifetime", mode: "hybrid"})
+- Typo tolerant: memory_search({query: "kubernets"})
 - Exact lookup: memory_search({name: "auth-service"})
 - List all: memory_search({query: ""})
-- Read journal: memory_search({journal: true, since: "7d"})`,
+- Read journal: memory_search({journal: true, since: "7d"})
+- Next page: memory_search({query: "redis", cursor: "<next_cursor from the previous call>"})
+Typos and partial identifiers ("kubernets", "authSvc") fall back to fuzzy matching when nothing matches exactly; the response then has fuzzy: true and may include did_you_mean.
+When more results exist the response has next_cursor (and journal_next_cursor for journal hits of a query); repeat the call with the same arguments plus cursor (or journal_cursor) to continue.`,
 		InputSchema: map[string]any{
 			"type": "object",
 			"properties": map[string]any{
-				"query":   map[string]any{"type": "string", "description": "FTS search query; empty string = list all"},
-				"name":    map[string]any{"type": "string", "description": "Exact entity name lookup (priority over query)"},
-				"journal": map[string]any{"type": "boolean", "description": "Read journal entries instead of entities"},
-				"since":   map[string]any{"type": "string", "description": "Time filter for journal: 2h|24h|7d|ISO date"},
-				"context": map[string]any{"type": "string", "description": "Named memory context"},
-				"type":    map[string]any{"type": "string", "description": "Filter by entity type"},
-				"tags":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "AND tag filter"},
-				"limit":   map[string]any{"type": "integer", "description": "Max results (default 10, max 50)"},
-				"sort":    map[string]any{"type": "string", "enum": []string{"recent", "accessed", "name"}, "description": "Sort order for list mode"},
+				"query":          map[string]any{"type": "string", "description": "Search query; empty string = list all. Words are ANDed; supports OR, NOT, \"phrases\", prefix*, NEAR(a b, 10), (groups) and name:/type:/tag: filters"},
+				"name":           map[string]any{"type": "string", "description": "Exact entity name lookup (priority over query)"},
+				"journal":        map[string]any{"type": "boolean", "description": "Read journal entries instead of entities"},
+				"since":          map[string]any{"type": "string", "description": "Time filter for journal: 2h|24h|7d|ISO date"},
+				"context":        map[string]any{"type": "string", "description": "Named memory context"},
+				"type":           map[string]any{"type": "string", "description": "Filter by entity type"},
+				"tags":           map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "AND tag filter"},
+				"limit":          map[string]any{"type": "integer", "description": "Max results (default 10, max 50)"},
+				"sort":           map[string]any{"type": "string", "enum": []string{"recent", "accessed", "name"}, "description": "Sort order for list mode"},
+				"matched_only":   map[string]any{"type": "boolean", "description": "Return only the observations that matched the query, with matched terms highlighted"},
+				"mode":           map[string]any{"type": "string", "enum": []string{"keyword", "semantic", "hybrid"}, "description": "keyword (default): full-text match; semantic: embedding similarity, finds paraphrases; hybrid: both, fused by reciprocal rank. Journal hits are always keyword"},
+				"cursor":         map[string]any{"type": "string", "description": "next_cursor from a previous call with the same arguments, to fetch the next page"},
+				"journal_cursor": map[string]any{"type": "string", "description": "journal_next_cursor from a previous query, to fetch the next page of journal hits"},
 			},
 		},
 	},
 	{
 		Name: "memory_forget",
-		Description: `Correct wrong information: retract a single bad observation, or soft-delete a whole entity. Soft-delete is reversible; use permanent:true only when sure.
+		Description: `Correct wrong information: retract a single bad observation, or soft-delete a whole entity. Soft-delete is reversible with memory_restore; use permanent:true only when sure (memory_undo can still bring it back).
 
 WHEN TO CALL: When you stored something incorrect, or a project/entity is no longer relevant.
 EXAMPLES:
@@ -115,6 +131,22 @@ EXAMPLES:
 			"required": []string{"name"},
 		},
 	},
+	{
+		Name: "memory_restore",
+		Description: `Bring back an entity that was soft-deleted with memory_forget, with its observations and relations. Call without name to list deleted entities.
+
+WHEN TO CALL: When something was forgotten by mistake, or a deleted project/entity becomes relevant again.
+EXAMPLES:
+- memory_restore({})
+- memory_restore({name: "old-feature"})`,
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name":    map[string]any{"type": "string", "description": "Deleted entity to restore; omit to list the trash"},
+				"context": map[string]any{"type": "string", "description": "Named memory context"},
+			},
+		},
+	},
 	{
 		Name: "memory_link",
 		Description: `Connect two entities with a named relationship. Use this to map dependencies, ownership, and associations between things you have stored.
@@ -135,28 +167,179 @@ EXAMPLES:
 			"required": []string{"from", "to", "relation"},
 		},
 	},
+	{
+		Name: "memory_unlink",
+		Description: `Remove a wrong relationship between entities, or rename a relation type across the whole graph.
+
+WHEN TO CALL: When a stored relation is wrong or obsolete, or relation types need to be made consistent.
+EXAMPLES:
+- Remove one edge: memory_unlink({from: "payment-service", to: "Redis", relation: "uses"})
+- Remove every edge from A to B: memory_unlink({from: "A", to: "B"})
+- Remove by ID: memory_unlink({id: 12})
+- Rename a relation type everywhere: memory_unlink({relation: "depends-on", rename_to: "depends_on"})`,
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"from":      map[string]any{"type": "string", "description": "Source entity name"},
+				"to":        map[string]any{"type": "string", "description": "Target entity name"},
+				"relation":  map[string]any{"type": "string", "description": "Relation type; omit with from/to to remove all types"},
+				"id":        map[string]any{"type": "integer", "description": "Relation ID to remove"},
+				"rename_to": map[string]any{"type": "string", "description": "Rename relation type across the graph instead of deleting; not combinable with id, from or to"},
+				"context":   map[string]any{"type": "string", "description": "Named memory context"},
+			},
+		},
+	},
+	{
+		Name: "memory_merge",
+		Description: `Merge two entities that describe the same thing, or rename an entity. Observations and relations move to the surviving entity and the old name becomes an alias.
+
+WHEN TO CALL: When the same thing was stored under two names (e.g. "AuthService" and "auth-service"), or an entity name is wrong.
+If into names an existing entity, from is merged into it; otherwise from is renamed to into.
+EXAMPLES:
+- memory_merge({from: "AuthService", into: "auth-service"})
+- memory_merge({from: "redis-cache", into: "Redis"})`,
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"from":    map[string]any{"type": "string", "description": "Entity to merge away or rename"},
+				"into":    map[string]any{"type": "string", "description": "Surviving entity, or the new name"},
+				"context": map[string]any{"type": "string", "description": "Named memory context"},
+			},
+			"required": []string{"from", "into"},
+		},
+	},
+	{
+		Name: "memory_undo",
+		Description: `Reverse the latest changes to memory, including permanent deletes: the entity comes back with its observations and relations. Without confirm:true it only previews what would be undone and returns the change ids; confirm with exactly those ids. Confirming fails if other changes were made since the preview.
+
+WHEN TO CALL: Right after a store, forget, merge or unlink turns out to be a mistake. Preview first, then call again with confirm:true and the previewed ids.
+EXAMPLES:
+- memory_undo({})
+- memory_undo({steps: 3})
+- memory_undo({since: "10m"})
+- memory_undo({confirm: true, ids: [42, 41, 40]})`,
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"steps":   map[string]any{"type": "integer", "description": "Number of changes to undo (default 1)"},
+				"since":   map[string]any{"type": "string", "description": "Undo every change in this window: 10m|2h|ISO date"},
+				"confirm": map[string]any{"type": "boolean", "description": "Apply the undo; default false only previews"},
+				"ids":     map[string]any{"type": "array", "items": map[string]any{"type": "integer"}, "description": "With confirm: the ids returned by the preview"},
+				"context": map[string]any{"type": "string", "description": "Named memory context"},
+			},
+		},
+	},
+	{
+		Name: "memory_graph",
+		Description: `Explore the relationship graph: everything within N hops of an entity, or the shortest chain of relations between two entities.
+
+WHEN TO CALL: To answer "what does changing X affect?", "what does X depend on?", or "how are X and Y connected?".
+EXAMPLES:
+- What depends on Redis: memory_graph({name: "Redis", direction: "in", depth: 2})
+- Dependencies only: memory_graph({name: "api", relations: ["depends_on"], direction: "out"})
+- Connection: memory_graph({from: "checkout", to: "Redis"})`,
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"name":      map[string]any{"type": "string", "description": "Root entity for neighborhood mode"},
+				"from":      map[string]any{"type": "string", "description": "Path mode: start entity"},
+				"to":        map[string]any{"type": "string", "description": "Path mode: end entity"},
+				"depth":     map[string]any{"type": "integer", "description": "Max hops (default 2 for neighborhoods, 6 for paths; max 6)"},
+				"relations": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Only follow these relation types"},
+				"direction": map[string]any{"type": "string", "enum": []string{"out", "in", "both"}, "description": "Edge direction to follow (default both)"},
+				"max_nodes": map[string]any{"type": "integer", "description": "Node cap (default 100, max 500)"},
+				"context":   map[string]any{"type": "string", "description": "Named memory context"},
+			},
+		},
+	},
+	{
+		Name: "memory_task",
+		Description: `Track work items across sessions: create, update, complete and list tasks. Open tasks are returned by memory_context as incomplete_tasks.
+
+WHEN TO CALL: When work is left unfinished, a follow-up is agreed, or a blocker appears — and when finishing something that was tracked.
+PRIORITY: high|medium|low. STATUS: open|in_progress|blocked|done|cancelled.
+EXAMPLES:
+- memory_task({action: "add", title: "Add rate limiting to /login", priority: "high", entity: "auth-service"})
+- memory_task({action: "update", id: 3, status: "blocked", notes: "Waiting on Redis upgrade"})
+- memory_task({action: "done", id: 3})
+- memory_task({action: "list"})`,
+		InputSchema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"action":   map[string]any{"type": "string", "enum": []string{"add", "update", "done", "list"}},
+				"id":       map[string]any{"type": "integer", "description": "Task ID (update, done)"},
+				"title":    map[string]any{"type": "string", "description": "Task title (add, update)"},
+				"status":   map[string]any{"type": "string", "description": "New status (update) or filter (list; 'all' for every status)"},
+				"priority": map[string]any{"type": "string", "enum": []string{"high", "medium", "low"}},
+				"due":      map[string]any{"type": "string", "description": "Due date: ISO date|3d|12h; empty string clears (update)"},
+				"entity":   map[string]any{"type": "string", "description": "Related existing entity, by name or alias"},
+				"notes":    map[string]any{"type": "string"},
+				"limit":    map[string]any{"type": "integer", "description": "Max tasks to list (default 20, max 50)"},
+				"context":  map[string]any{"type": "string", "description": "Named memory context"},
+			},
+			"required": []string{"action"},
+		},
+	},
 }
 
-// dispatch routes a tool call to the appropriate handler.
-func (s *Server) dispatch(ctx context.Context, name string, args json.RawMessage) (any, error) {
-	switch name {
-	case "memory_context":
-		return s.handleMemoryContext(ctx, args)
-	case "memory_store":
-		return s.handleMemoryStore(ctx, args)
-	case "memory_search":
-		return s.handleMemorySearch(ctx, args)
-	case "memory_forget":
-		return s.handleMemoryForget(ctx, args)
-	case "memory_link":
-		return s.handleMemoryLink(ctx, args)
-	default:
+// toolHandler handles one tool call against the database resolved for it.
+type toolHandler func(s *Server, ctx context.Context, t target, args json.RawMessage) (any, error)
+
+var toolHandlers = map[string]toolHandler{
+	"memory_context": (*Server).handleMemoryContext,
+	"memory_store":   (*Server).handleMemoryStore,
+	"memory_search":  (*Server).handleMemorySearch,
+	"memory_forget":  (*Server).handleMemoryForget,
+	"memory_restore": (*Server).handleMemoryRestore,
+	"memory_link":    (*Server).handleMemoryLink,
+	"memory_unlink":  (*Server).handleMemoryUnlink,
+	"memory_merge":   (*Server).handleMemoryMerge,
+	"memory_undo":    (*Server).handleMemoryUndo,
+	"memory_graph":   (*Server).handleMemoryGraph,
+	"memory_task":    (*Server).handleMemoryTask,
+}
+
+// dispatch routes a tool call to the appropriate handler, running it against
+// the database for the call's context argument. Map results are annotated with
+// the storage path (and context) that served the call. The client's token,
+// if any, must grant the call's scope and context (see authorize).
+func (s *Server) dispatch(ctx context.Context, ss *session, name string, args json.RawMessage) (any, error) {
+	h, ok := toolHandlers[name]
+	if !ok {
 		return nil, fmt.Errorf("unknown tool: %s", name)
 	}
+	var token *db.Token
+	if ss != nil {
+		token = ss.token
+	}
+	if err := authorize(token, name, args); err != nil {
+		return nil, err
+	}
+
+	t, release, err := s.resolveTarget(ss, contextArg(args))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	result, err := h(s, ctx, t, args)
+	if err != nil {
+		return nil, err
+	}
+	if callScope(name, args) != db.ScopeRead {
+		s.pokeWatch(t.path)
+	}
+	if m, ok := result.(map[string]any); ok {
+		m["storage_path"] = t.path
+		if t.context != "" {
+			m["context"] = t.context
+		}
+	}
+	return result, nil
 }
 
 // handleMemoryStore dispatches to journal or entity mode.
-func (s *Server) handleMemoryStore(ctx context.Context, args json.RawMessage) (any, error) {
+func (s *Server) handleMemoryStore(ctx context.Context, t target, args json.RawMessage) (any, error) {
 	var p struct {
 		Entities []db.EntityInput `json:"entities"`
 		Journal  string           `json:"journal"`
@@ -168,7 +351,7 @@ func (s *Server) handleMemoryStore(ctx context.Context, args json.RawMessage) (a
 	}
 
 	if p.Journal != "" {
-		entry, err := s.db.AppendJournal(ctx, p.Journal, p.Tags)
+		entry, err := t.db.AppendJournal(ctx, p.Journal, p.Tags)
 		if err != nil {
 			return nil, err
 		}
@@ -182,19 +365,23 @@ func (s *Server) handleMemoryStore(ctx context.Context, args json.RawMessage) (a
 		return nil, fmt.Errorf("entities or journal is required")
 	}
 
-	results, err := s.db.StoreEntities(ctx, p.Entities)
+	results, dups, err := t.db.StoreEntitiesWithDuplicates(ctx, p.Entities)
 	if err != nil {
 		return nil, err
 	}
-	return map[string]any{
+	resp := map[string]any{
 		"stored":   "entities",
 		"count":    len(results),
 		"entities": results,
-	}, nil
+	}
+	if len(dups) > 0 {
+		resp["duplicates"] = dups
+	}
+	return resp, nil
 }
 
 // handleMemorySearch dispatches to journal or entity search mode.
-func (s *Server) handleMemorySearch(ctx context.Context, args json.RawMessage) (any, error) {
+func (s *Server) handleMemorySearch(ctx context.Context, t target, args json.RawMessage) (any, error) {
 	var p struct {
 		Query   string   `json:"query"`
 		Name    string   `json:"name"`
@@ -205,6 +392,11 @@ func (s *Server) handleMemorySearch(ctx context.Context, args json.RawMessage) (
 		Tags    []string `json:"tags"`
 		Limit   int      `json:"limit"`
 		Sort    string   `json:"sort"`
+
+		MatchedOnly   bool   `json:"matched_only"`
+		Cursor        string `json:"cursor"`
+		JournalCursor string `json:"journal_cursor"`
+		Mode          string `json:"mode"`
 	}
 	if err := json.Unmarshal(args, &p); err != nil {
 		return nil, fmt.Errorf("invalid params: %w", err)
@@ -219,22 +411,26 @@ func (s *Server) handleMemorySearch(ctx context.Context, args json.RawMessage) (
 
 	// Journal mode
 	if p.Journal {
-		entries, err := s.db.ListJournal(ctx, p.Since, p.Limit)
+		entries, next, err := t.db.ListJournalPage(ctx, p.Since, p.Limit, p.Cursor)
 		if err != nil {
 			return nil, err
 		}
 		if entries == nil {
 			entries = []db.JournalEntry{}
 		}
-		return map[string]any{
+		resp := map[string]any{
 			"journal": entries,
 			"count":   len(entries),
-		}, nil
+		}
+		if next != "" {
+			resp["next_cursor"] = next
+		}
+		return resp, nil
 	}
 
 	// Exact name lookup
 	if p.Name != "" {
-		e, err := s.db.SearchByName(ctx, p.Name)
+		e, err := t.db.SearchByName(ctx, p.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -247,22 +443,55 @@ func (s *Server) handleMemorySearch(ctx context.Context, args json.RawMessage) (
 		}, nil
 	}
 
-	// FTS or list-all search
-	results, err := s.db.Search(ctx, p.Query, p.Type, p.Tags, p.Sort, p.Limit)
-	if err != nil {
-		return nil, err
-	}
-	if results == nil {
-		results = []db.SearchResult{}
-	}
-	resp := map[string]any{
-		"entities": results,
-		"count":    len(results),
+	// Entity and journal results of a query page independently: a request
+	// carrying only journal_cursor continues the journal and skips entities,
+	// and one carrying only cursor skips the journal.
+	resp := map[string]any{}
+	if p.Cursor != "" || p.JournalCursor == "" {
+		// FTS, semantic or list-all search; keyword search falls back to
+		// fuzzy matching when nothing matches.
+		results, next, err := t.db.SearchModePage(ctx, p.Mode, p.Query, p.Type, p.Tags, p.Sort, p.Limit, p.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		keyword := p.Mode == "" || p.Mode == db.ModeKeyword
+		var suggestion string
+		if len(results) == 0 && keyword && p.Query != "" && p.Cursor == "" && t.db.FuzzyEnabled() {
+			results, suggestion, err = t.db.FuzzySearch(ctx, p.Query, p.Type, p.Tags, p.Limit)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if results == nil {
+			results = []db.SearchResult{}
+		}
+		// Each matching observation's text is returned once: matched_only
+		// lists just the matches, highlighted, as the observations, and
+		// otherwise matches point into the full observation list.
+		if p.MatchedOnly && p.Query != "" {
+			for i := range results {
+				results[i].Observations = matchedObservations(results[i].Matches)
+				results[i].Matches = nil
+			}
+			resp["entities"] = results
+		} else {
+			resp["entities"] = searchHits(results)
+		}
+		resp["count"] = len(results)
+		if next != "" {
+			resp["next_cursor"] = next
+		}
+		if len(results) > 0 && results[0].Fuzzy {
+			resp["fuzzy"] = true
+		}
+		if suggestion != "" {
+			resp["did_you_mean"] = suggestion
+		}
 	}
 
 	// When a keyword query is given, also search journal entries via FTS.
-	if p.Query != "" {
-		journalResults, err := s.db.SearchJournal(ctx, p.Query, p.Limit)
+	if p.Query != "" && (p.JournalCursor != "" || p.Cursor == "") {
+		journalResults, next, err := t.db.SearchJournalPage(ctx, p.Query, p.Limit, p.JournalCursor)
 		if err != nil {
 			return nil, err
 		}
@@ -271,13 +500,62 @@ func (s *Server) handleMemorySearch(ctx context.Context, args json.RawMessage) (
 		}
 		resp["journal"] = journalResults
 		resp["journal_count"] = len(journalResults)
+		if next != "" {
+			resp["journal_next_cursor"] = next
+		}
 	}
 
 	return resp, nil
 }
 
+// matchedObservations returns the highlighted text of each match.
+// searchHit is a search result as memory_search returns it by default: the
+// entity with all its observations, and its matches as references into them.
+type searchHit struct {
+	db.SearchResult
+	Matches []matchRef `json:"matches,omitempty"`
+}
+
+// matchRef identifies a matching observation by its position in the
+// observations list instead of repeating its text.
+type matchRef struct {
+	Index   int      `json:"index"`
+	Terms   []string `json:"terms,omitempty"`   // distinct matched terms, as highlighted
+	Snippet string   `json:"snippet,omitempty"` // highlighted excerpt of a long observation
+}
+
+func searchHits(results []db.SearchResult) []searchHit {
+	hits := make([]searchHit, len(results))
+	for i, r := range results {
+		hits[i].SearchResult = r
+		for _, m := range r.Matches {
+			idx := slices.Index(r.Observations, m.Content)
+			if idx < 0 {
+				continue
+			}
+			ref := matchRef{Index: idx, Snippet: m.Snippet}
+			spans := db.HighlightSpans(m.Highlight)
+			for j := 1; j < len(spans); j += 2 {
+				if !slices.Contains(ref.Terms, spans[j]) {
+					ref.Terms = append(ref.Terms, spans[j])
+				}
+			}
+			hits[i].Matches = append(hits[i].Matches, ref)
+		}
+	}
+	return hits
+}
+
+func matchedObservations(matches []db.ObservationMatch) []string {
+	obs := make([]string, len(matches))
+	for i, m := range matches {
+		obs[i] = m.Highlight
+	}
+	return obs
+}
+
 // handleMemoryForget dispatches to retract or delete.
-func (s *Server) handleMemoryForget(ctx context.Context, args json.RawMessage) (any, error) {
+func (s *Server) handleMemoryForget(ctx context.Context, t target, args json.RawMessage) (any, error) {
 	var p struct {
 		Name        string `json:"name"`
 		Observation string `json:"observation"`
@@ -292,7 +570,7 @@ func (s *Server) handleMemoryForget(ctx context.Context, args json.RawMessage) (
 	}
 
 	if p.Observation != "" {
-		remaining, err := s.db.RetractObservation(ctx, p.Name, p.Observation)
+		remaining, err := t.db.RetractObservation(ctx, p.Name, p.Observation)
 		if err != nil {
 			return nil, err
 		}
@@ -300,15 +578,15 @@ func (s *Server) handleMemoryForget(ctx context.Context, args json.RawMessage) (
 			remaining = []string{}
 		}
 		return map[string]any{
-			"action":                  "retract_observation",
-			"entity":                  p.Name,
-			"deleted":                 p.Observation,
-			"remaining_observations":  remaining,
+			"action":                 "retract_observation",
+			"entity":                 p.Name,
+			"deleted":                p.Observation,
+			"remaining_observations": remaining,
 		}, nil
 	}
 
 	if p.Permanent {
-		if err := s.db.HardDeleteEntity(ctx, p.Name); err != nil {
+		if err := t.db.HardDeleteEntity(ctx, p.Name); err != nil {
 			return nil, err
 		}
 		return map[string]any{
@@ -317,7 +595,7 @@ func (s *Server) handleMemoryForget(ctx context.Context, args json.RawMessage) (
 		}, nil
 	}
 
-	if err := s.db.SoftDeleteEntity(ctx, p.Name); err != nil {
+	if err := t.db.SoftDeleteEntity(ctx, p.Name); err != nil {
 		return nil, err
 	}
 	return map[string]any{
@@ -326,8 +604,41 @@ func (s *Server) handleMemoryForget(ctx context.Context, args json.RawMessage) (
 	}, nil
 }
 
+// handleMemoryRestore restores a soft-deleted entity, or lists the trash.
+func (s *Server) handleMemoryRestore(ctx context.Context, t target, args json.RawMessage) (any, error) {
+	var p struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	if p.Name == "" {
+		trash, err := t.db.ListTrash(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if trash == nil {
+			trash = []db.TrashedEntity{}
+		}
+		return map[string]any{
+			"trash": trash,
+			"count": len(trash),
+		}, nil
+	}
+
+	e, err := t.db.RestoreEntity(ctx, p.Name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"action": "restore",
+		"entity": e,
+	}, nil
+}
+
 // handleMemoryLink creates a typed relation between two entities.
-func (s *Server) handleMemoryLink(ctx context.Context, args json.RawMessage) (any, error) {
+func (s *Server) handleMemoryLink(ctx context.Context, t target, args json.RawMessage) (any, error) {
 	var p struct {
 		From     string `json:"from"`
 		To       string `json:"to"`
@@ -341,7 +652,7 @@ func (s *Server) handleMemoryLink(ctx context.Context, args json.RawMessage) (an
 		return nil, fmt.Errorf("from, to, and relation are required")
 	}
 
-	if err := s.db.UpsertRelationByName(ctx, p.From, p.To, p.Relation); err != nil {
+	if err := t.db.UpsertRelationByName(ctx, p.From, p.To, p.Relation); err != nil {
 		return nil, err
 	}
 	return map[string]any{
@@ -351,3 +662,219 @@ func (s *Server) handleMemoryLink(ctx context.Context, args json.RawMessage) (an
 		"created":  time.Now().UnixMilli(),
 	}, nil
 }
+
+// handleMemoryUnlink deletes relations by ID or endpoints, or renames a relation type.
+func (s *Server) handleMemoryUnlink(ctx context.Context, t target, args json.RawMessage) (any, error) {
+	var p struct {
+		From     string `json:"from"`
+		To       string `json:"to"`
+		Relation string `json:"relation"`
+		ID       int64  `json:"id"`
+		RenameTo string `json:"rename_to"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	switch {
+	case p.RenameTo != "":
+		if p.Relation == "" {
+			return nil, fmt.Errorf("relation is required with rename_to")
+		}
+		// rename_to renames the type across the whole graph, so refuse calls
+		// that look like they meant a single edge.
+		if p.ID != 0 || p.From != "" || p.To != "" {
+			return nil, fmt.Errorf("rename_to renames a relation type on every edge and cannot be combined with id, from or to")
+		}
+		n, err := t.db.RenameRelationType(ctx, p.Relation, p.RenameTo)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"action":  "rename_relation",
+			"from":    p.Relation,
+			"to":      p.RenameTo,
+			"changed": n,
+		}, nil
+
+	case p.ID != 0:
+		n, err := t.db.DeleteRelationByID(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"action":  "delete_relation",
+			"id":      p.ID,
+			"changed": n,
+		}, nil
+
+	case p.From != "" && p.To != "":
+		n, err := t.db.DeleteRelation(ctx, p.From, p.To, p.Relation)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"action":   "delete_relation",
+			"from":     p.From,
+			"to":       p.To,
+			"relation": p.Relation,
+			"changed":  n,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("provide id, from and to, or relation and rename_to")
+	}
+}
+
+// handleMemoryMerge merges from into an existing entity, or renames it.
+func (s *Server) handleMemoryMerge(ctx context.Context, t target, args json.RawMessage) (any, error) {
+	var p struct {
+		From string `json:"from"`
+		Into string `json:"into"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	if p.From == "" || p.Into == "" {
+		return nil, fmt.Errorf("from and into are required")
+	}
+
+	into, err := t.db.EntityName(ctx, p.Into)
+	if err != nil {
+		return nil, err
+	}
+	if into == "" {
+		e, err := t.db.RenameEntity(ctx, p.From, p.Into)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{
+			"action": "rename",
+			"from":   p.From,
+			"entity": e.Name,
+		}, nil
+	}
+
+	r, err := t.db.MergeEntities(ctx, p.From, p.Into)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"action": "merge",
+		"result": r,
+	}, nil
+}
+
+// handleMemoryUndo previews or applies an undo of the latest logged changes.
+func (s *Server) handleMemoryUndo(ctx context.Context, t target, args json.RawMessage) (any, error) {
+	var p struct {
+		Steps   int     `json:"steps"`
+		Since   string  `json:"since"`
+		Confirm bool    `json:"confirm"`
+		IDs     []int64 `json:"ids"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	// Confirming applies exactly the changes a preview returned.
+	var plan []db.Change
+	var err error
+	switch {
+	case p.Confirm && (p.Steps != 0 || p.Since != ""):
+		return nil, fmt.Errorf("confirm takes the ids from a preview, not steps or since")
+	case p.Confirm && len(p.IDs) == 0:
+		return nil, fmt.Errorf("confirm requires the ids returned by a preview")
+	case p.Confirm:
+		plan, err = t.db.PlanUndoIDs(ctx, p.IDs)
+	case len(p.IDs) > 0:
+		return nil, fmt.Errorf("ids are only used with confirm")
+	default:
+		var since int64
+		if p.Since != "" {
+			if since, err = db.ParseSince(p.Since); err != nil {
+				return nil, err
+			}
+		}
+		plan, err = t.db.PlanUndo(ctx, p.Steps, since)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, len(plan))
+	changes := make([]map[string]any, len(plan))
+	for i, c := range plan {
+		ids[i] = c.ID
+		changes[i] = map[string]any{
+			"id":         c.ID,
+			"op":         c.Op,
+			"entity":     c.Entity,
+			"summary":    c.Summary(),
+			"source":     c.Source,
+			"created_at": c.CreatedAt,
+		}
+	}
+	if !p.Confirm {
+		return map[string]any{
+			"preview": true,
+			"ids":     ids,
+			"changes": changes,
+			"count":   len(changes),
+		}, nil
+	}
+
+	if err := t.db.Undo(ctx, plan); err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"action": "undo",
+		"undone": changes,
+		"count":  len(changes),
+	}, nil
+}
+
+// handleMemoryGraph returns an entity's neighborhood or the shortest path between two entities.
+func (s *Server) handleMemoryGraph(ctx context.Context, t target, args json.RawMessage) (any, error) {
+	var p struct {
+		Name      string   `json:"name"`
+		From      string   `json:"from"`
+		To        string   `json:"to"`
+		Depth     int      `json:"depth"`
+		Relations []string `json:"relations"`
+		Direction string   `json:"direction"`
+		MaxNodes  int      `json:"max_nodes"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+	opts := db.GraphOptions{Depth: p.Depth, Relations: p.Relations, Direction: p.Direction, MaxNodes: p.MaxNodes}
+
+	if p.From != "" || p.To != "" {
+		if p.From == "" || p.To == "" {
+			return nil, fmt.Errorf("from and to are both required for path mode")
+		}
+		path, err := t.db.ShortestPath(ctx, p.From, p.To, opts)
+		if err != nil {
+			return nil, err
+		}
+		if path == nil {
+			return map[string]any{"from": p.From, "to": p.To, "found": false}, nil
+		}
+		return map[string]any{"from": p.From, "to": p.To, "found": true, "path": path}, nil
+	}
+
+	if p.Name == "" {
+		return nil, fmt.Errorf("name (neighborhood) or from and to (path) is required")
+	}
+	g, err := t.db.Neighbors(ctx, p.Name, opts)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"root":       g.Root,
+		"nodes":      g.Nodes,
+		"edges":      g.Edges,
+		"node_count": len(g.Nodes),
+		"truncated":  g.Truncated,
+	}, nil
+}
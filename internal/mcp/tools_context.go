@@ -11,7 +11,7 @@ import (
 
 // handleMemoryContext returns session orientation data.
 // Runs sub-queries in parallel for <50ms with 10k entities.
-func (s *Server) handleMemoryContext(ctx context.Context, args json.RawMessage) (any, error) {
+func (s *Server) handleMemoryContext(ctx context.Context, t target, args json.RawMessage) (any, error) {
 	var p struct {
 		Since string `json:"since"`
 		Limit int    `json:"limit"`
@@ -32,6 +32,7 @@ func (s *Server) handleMemoryContext(ctx context.Context, args json.RawMessage)
 		stats         db.Stats
 		lastSession   int64
 		recentJournal []db.JournalEntry
+		openTasks     []db.Task
 		err           error
 	}
 
@@ -42,12 +43,12 @@ func (s *Server) handleMemoryContext(ctx context.Context, args json.RawMessage)
 		var wg sync.WaitGroup
 		var mu sync.Mutex
 
-		wg.Add(4)
+		wg.Add(5)
 
 		// Recent observations
 		go func() {
 			defer wg.Done()
-			obs, err := recentObservations(ctx, s.db, p.Since, p.Limit)
+			obs, err := recentObservations(ctx, t.db, p.Since, p.Limit)
 			mu.Lock()
 			if err != nil && r.err == nil {
 				r.err = err
@@ -60,7 +61,7 @@ func (s *Server) handleMemoryContext(ctx context.Context, args json.RawMessage)
 		// Top entities by importance (list-all, sorted by recent, limit 10)
 		go func() {
 			defer wg.Done()
-			entities, err := s.db.Search(ctx, "", "", nil, "recent", 10)
+			entities, err := t.db.Search(ctx, "", "", nil, "recent", 10)
 			mu.Lock()
 			if err != nil && r.err == nil {
 				r.err = err
@@ -73,7 +74,7 @@ func (s *Server) handleMemoryContext(ctx context.Context, args json.RawMessage)
 		// Stats
 		go func() {
 			defer wg.Done()
-			stats, err := s.db.GetStats(ctx)
+			stats, err := t.db.GetStats(ctx)
 			mu.Lock()
 			if err != nil && r.err == nil {
 				r.err = err
@@ -86,7 +87,7 @@ func (s *Server) handleMemoryContext(ctx context.Context, args json.RawMessage)
 		// Recent journal entries
 		go func() {
 			defer wg.Done()
-			entries, err := s.db.ListJournal(ctx, p.Since, 5)
+			entries, err := t.db.ListJournal(ctx, p.Since, 5)
 			mu.Lock()
 			if err != nil && r.err == nil {
 				r.err = err
@@ -96,6 +97,19 @@ func (s *Server) handleMemoryContext(ctx context.Context, args json.RawMessage)
 			mu.Unlock()
 		}()
 
+		// Incomplete tasks, highest priority and oldest first
+		go func() {
+			defer wg.Done()
+			tasks, err := t.db.ListTasks(ctx, db.TaskFilter{Limit: 10})
+			mu.Lock()
+			if err != nil && r.err == nil {
+				r.err = err
+			} else {
+				r.openTasks = tasks
+			}
+			mu.Unlock()
+		}()
+
 		wg.Wait()
 		ch <- r
 	}()
@@ -114,14 +128,17 @@ func (s *Server) handleMemoryContext(ctx context.Context, args json.RawMessage)
 		if r.recentJournal == nil {
 			r.recentJournal = []db.JournalEntry{}
 		}
+		if r.openTasks == nil {
+			r.openTasks = []db.Task{}
+		}
 		return map[string]any{
-			"storage_path":        s.dbPath,
+			"storage_path":        t.path,
 			"entity_count":        r.stats.EntityCount,
 			"observation_count":   r.stats.ObservationCount,
 			"recent_observations": r.recentObs,
 			"top_entities":        r.topEntities,
 			"recent_journal":      r.recentJournal,
-			"incomplete_tasks":    []any{},
+			"incomplete_tasks":    r.openTasks,
 			"generated_at":        time.Now().UnixMilli(),
 		}, nil
 	case <-ctx.Done():
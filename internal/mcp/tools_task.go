@@ -0,0 +1,117 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/MyAgentHubs/aimemo/internal/db"
+)
+
+// handleMemoryTask dispatches to task add, update, done or list.
+func (s *Server) handleMemoryTask(ctx context.Context, t target, args json.RawMessage) (any, error) {
+	var p struct {
+		Action   string  `json:"action"`
+		ID       int64   `json:"id"`
+		Title    *string `json:"title"`
+		Status   *string `json:"status"`
+		Priority *string `json:"priority"`
+		Due      *string `json:"due"`
+		Entity   *string `json:"entity"`
+		Notes    *string `json:"notes"`
+		Limit    int     `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &p); err != nil {
+		return nil, fmt.Errorf("invalid params: %w", err)
+	}
+
+	switch p.Action {
+	case "add":
+		if p.Title == nil || *p.Title == "" {
+			return nil, fmt.Errorf("title is required")
+		}
+		in := db.TaskInput{Title: *p.Title}
+		if p.Priority != nil {
+			in.Priority = *p.Priority
+		}
+		if p.Notes != nil {
+			in.Notes = *p.Notes
+		}
+		if p.Entity != nil {
+			in.Entity = *p.Entity
+		}
+		if p.Due != nil && *p.Due != "" {
+			due, err := db.ParseDue(*p.Due)
+			if err != nil {
+				return nil, err
+			}
+			in.DueAt = &due
+		}
+		task, err := t.db.AddTask(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"action": "add", "task": task}, nil
+
+	case "update":
+		if p.ID == 0 {
+			return nil, fmt.Errorf("id is required")
+		}
+		u := db.TaskUpdate{Title: p.Title, Status: p.Status, Priority: p.Priority, Notes: p.Notes, Entity: p.Entity}
+		if p.Due != nil {
+			var due int64
+			if *p.Due != "" {
+				var err error
+				if due, err = db.ParseDue(*p.Due); err != nil {
+					return nil, err
+				}
+			}
+			u.DueAt = &due
+		}
+		task, err := t.db.UpdateTask(ctx, p.ID, u)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"action": "update", "task": task}, nil
+
+	case "done":
+		if p.ID == 0 {
+			return nil, fmt.Errorf("id is required")
+		}
+		task, err := t.db.CompleteTask(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"action": "done", "task": task}, nil
+
+	case "list":
+		if p.Limit <= 0 {
+			p.Limit = 20
+		}
+		if p.Limit > 50 {
+			p.Limit = 50
+		}
+		f := db.TaskFilter{Limit: p.Limit}
+		if p.Entity != nil {
+			f.Entity = *p.Entity
+		}
+		if p.Status != nil && *p.Status != "" {
+			if *p.Status == "all" {
+				f.Statuses = []string{db.TaskOpen, db.TaskInProgress, db.TaskBlocked, db.TaskDone, db.TaskCancelled}
+			} else {
+				f.Statuses = []string{*p.Status}
+			}
+		}
+		tasks, err := t.db.ListTasks(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		if tasks == nil {
+			tasks = []db.Task{}
+		}
+		return map[string]any{"tasks": tasks, "count": len(tasks)}, nil
+
+	default:
+		return nil, fmt.Errorf("action must be one of add, update, done, list")
+	}
+}